@@ -1,9 +1,11 @@
 package main
 
 import (
+	"encoding/json"
 	"log"
 	"net"
 	"net/http"
+	"strconv"
 	
 	"github.com/baijianruoli/bot_chat/backend/internal/conf"
 	"github.com/baijianruoli/bot_chat/backend/internal/dao"
@@ -14,25 +16,46 @@ import (
 
 func main() {
 	// 加载配置
-	config := conf.LoadConfig()
+	config, err := conf.LoadConfig()
+	if err != nil {
+		log.Fatalf("Invalid config: %v", err)
+	}
 	log.Printf("Bot Chat Server starting on %s:%d", config.Server.Host, config.Server.Port)
 	
 	// 初始化数据库
-	db, err := dao.InitDB()
+	_, err = dao.InitDB()
 	if err != nil {
 		log.Fatalf("Failed to init database: %v", err)
 	}
 	log.Println("Database initialized")
 	
 	// 创建服务实例
-	svc := service.NewChatService()
+	tokens := service.NewTokenService(config.Auth)
+	svc := service.NewChatService(tokens, config.Message)
 	
-	// 启动 WebSocket 管理器
+	// 启动 WebSocket 管理器，多副本部署时通过 Redis 转发房间消息
+	service.GlobalWSManager.Configure(config.WebSocket)
+	if config.WebSocket.Broker == conf.BrokerRedis {
+		broker, err := service.NewRedisBroker(config.Redis, 3*config.WebSocket.OnlineSync)
+		if err != nil {
+			log.Fatalf("Failed to connect redis broker: %v", err)
+		}
+		defer broker.Close()
+		service.GlobalWSManager.UseBroker(broker)
+		log.Println("WebSocket redis broker enabled")
+	}
 	go service.GlobalWSManager.Run()
 	log.Println("WebSocket manager started")
 	
+	// 启动进程内机器人宿主，机器人通过 botHost.Register 绑定到机器人账号
+	botHost := service.NewBotHost(svc)
+	go botHost.Run()
+	
+	// 启动外发 Webhook 投递
+	go service.NewWebhookDispatcher(svc, config.Webhook).Run()
+	
 	// 启动 HTTP 服务器（用于 WebSocket 和 API）
-	go startHTTPServer(config.Server.Host, config.Server.Port + 1)
+	go startHTTPServer(config.Server.Host, config.Server.HTTPPort, svc)
 	
 	// 创建 Kitex gRPC 服务
 	svr := server.NewServer(
@@ -40,6 +63,7 @@ func main() {
 			IP:   net.ParseIP(config.Server.Host),
 			Port: config.Server.Port,
 		}),
+		server.WithMiddleware(service.AuthMiddleware(tokens)),
 	)
 	
 	// 注册服务
@@ -47,7 +71,7 @@ func main() {
 	
 	log.Println("Server started successfully!")
 	log.Printf("gRPC server listening on port %d", config.Server.Port)
-	log.Printf("HTTP/WebSocket server listening on port %d", config.Server.Port + 1)
+	log.Printf("HTTP/WebSocket server listening on port %d", config.Server.HTTPPort)
 	
 	// 启动服务
 	if err := svr.Run(); err != nil {
@@ -56,22 +80,31 @@ func main() {
 }
 
 // startHTTPServer 启动 HTTP 服务器
-func startHTTPServer(host string, port int) {
+func startHTTPServer(host string, port int, svc *service.ChatServiceImpl) {
+	mux := http.NewServeMux()
+	
+	// REST API 路由
+	mux.Handle("/api/", service.NewHTTPGateway(svc))
+	
 	// WebSocket 路由
-	http.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
-		service.GlobalWSManager.HandleWebSocket(w, r, r.URL.Query().Get("user_id"))
-	})
+	mux.HandleFunc("/ws", service.NewWSRouter(svc).HandleConnection)
 	
 	// 健康检查
-	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("OK"))
 	})
 	
-	addr := net.JoinHostPort(host, string(rune('0' + port - 8888)))
+	// WebSocket 慢消费者统计
+	mux.HandleFunc("/health/ws", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(service.GlobalWSManager.Stats())
+	})
+	
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
 	log.Printf("HTTP server starting on %s", addr)
 	
-	if err := http.ListenAndServe(addr, nil); err != nil {
+	if err := http.ListenAndServe(addr, mux); err != nil {
 		log.Fatalf("HTTP server error: %v", err)
 	}
 }
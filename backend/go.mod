@@ -6,6 +6,8 @@ require (
 	github.com/cloudwego/kitex v0.9.0
 	github.com/google/uuid v1.5.0
 	github.com/gorilla/websocket v1.5.1
+	github.com/redis/go-redis/v9 v9.3.0
+	golang.org/x/crypto v0.14.0
 	gorm.io/driver/mysql v1.5.2
 	gorm.io/gorm v1.25.5
 )
@@ -13,6 +15,7 @@ require (
 require (
 	github.com/bytedance/gopkg v0.0.0-20240124074249-3f0016e75954 // indirect
 	github.com/bytedance/sonic v1.11.0 // indirect
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
 	github.com/chenzhuoyu/base64x v0.0.0-20230717121745-296ad89f973d // indirect
 	github.com/chenzhuoyu/iasm v0.9.1 // indirect
 	github.com/choleraehyq/pid v0.0.18 // indirect
@@ -23,6 +26,7 @@ require (
 	github.com/cloudwego/gopkg v0.0.0-20240124074249-3f0016e75954 // indirect
 	github.com/cloudwego/netpoll v0.6.0 // indirect
 	github.com/cloudwego/thriftgo v0.3.6 // indirect
+	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
 	github.com/go-sql-driver/mysql v1.7.1 // indirect
 	github.com/golang/protobuf v1.5.3 // indirect
 	github.com/google/pprof v0.0.0-20220608213341-c488b8fa1db3 // indirect
@@ -1,13 +1,19 @@
 package conf
 
 import (
+	"errors"
+	"fmt"
+	"log"
 	"os"
+	"strconv"
+	"time"
 )
 
 // ServerConfig 服务器配置
 type ServerConfig struct {
-	Host string
-	Port int
+	Host     string
+	Port     int // Kitex gRPC 端口
+	HTTPPort int // HTTP API / WebSocket 端口
 }
 
 // DatabaseConfig 数据库配置
@@ -27,23 +33,94 @@ type RedisConfig struct {
 	DB       int
 }
 
+// AuthConfig 认证配置
+type AuthConfig struct {
+	TokenSecret     string
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+}
+
+// WebhookConfig 外发 Webhook 投递配置
+type WebhookConfig struct {
+	Timeout        time.Duration
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// MessageConfig 消息配置
+type MessageConfig struct {
+	EditWindow   time.Duration // 发送后允许作者编辑的时长，0 表示不限制
+	RecallWindow time.Duration // 发送后允许作者撤回的时长，0 表示不限制
+}
+
+// WebSocketConfig WebSocket 心跳与超时配置
+type WebSocketConfig struct {
+	PingInterval time.Duration // 服务端发送 ping 的间隔，需小于 PongWait
+	PongWait     time.Duration // 等待 pong 或任意客户端帧的最长时间
+	WriteTimeout time.Duration // 单次写入超时
+	MaxIdle      time.Duration // 客户端不发送应用帧（pong 不计入）的最长时间，0 表示不限制
+	BotMaxIdle   time.Duration // 机器人连接的 MaxIdle，0 表示不限制
+	SendBuffer   int           // 每个连接的发送队列长度
+	SlowConsumer string        // 发送队列满时的策略：drop_oldest 或 disconnect
+	Broker       string        // 跨节点消息总线：memory（单节点）或 redis
+	OnlineSync   time.Duration // 向消息总线上报房间在线用户的间隔
+
+	TypingThrottle time.Duration // 同一连接广播 typing_start 的最小间隔
+	TypingTTL      time.Duration // 未收到 typing_stop 时输入状态的过期时间
+}
+
+// 跨节点消息总线
+const (
+	BrokerMemory = "memory"
+	BrokerRedis  = "redis"
+)
+
+// 慢消费者策略
+const (
+	SlowConsumerDropOldest = "drop_oldest" // 丢弃队列中最旧的帧
+	SlowConsumerDisconnect = "disconnect"  // 断开连接，客户端重连后从历史消息补齐
+)
+
+// DefaultWebSocketConfig WebSocket 默认配置
+func DefaultWebSocketConfig() WebSocketConfig {
+	return WebSocketConfig{
+		PingInterval: 30 * time.Second,
+		PongWait:     60 * time.Second,
+		WriteTimeout: 10 * time.Second,
+		MaxIdle:      30 * time.Minute,
+		SendBuffer:   256,
+		SlowConsumer: SlowConsumerDisconnect,
+		Broker:       BrokerMemory,
+		OnlineSync:   30 * time.Second,
+
+		TypingThrottle: 2 * time.Second,
+		TypingTTL:      6 * time.Second,
+	}
+}
+
 // Config 全局配置
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	Redis    RedisConfig
+	Server    ServerConfig
+	Database  DatabaseConfig
+	Redis     RedisConfig
+	Auth      AuthConfig
+	Webhook   WebhookConfig
+	WebSocket WebSocketConfig
+	Message   MessageConfig
 }
 
 // GlobalConfig 全局配置实例
 var GlobalConfig *Config
 
-// LoadConfig 加载配置
-func LoadConfig() *Config {
+// LoadConfig 加载配置，配置无法正常运行时返回错误
+func LoadConfig() (*Config, error) {
 	// 从环境变量读取，或使用默认值
 	GlobalConfig = &Config{
 		Server: ServerConfig{
-			Host: getEnv("SERVER_HOST", "0.0.0.0"),
-			Port: getEnvAsInt("SERVER_PORT", 8888),
+			Host:     getEnv("SERVER_HOST", "0.0.0.0"),
+			Port:     getEnvAsInt("SERVER_PORT", 8888),
+			HTTPPort: getEnvAsInt("HTTP_PORT", 8889),
 		},
 		Database: DatabaseConfig{
 			Host:     getEnv("DB_HOST", "localhost"),
@@ -58,8 +135,70 @@ func LoadConfig() *Config {
 			Password: getEnv("REDIS_PASS", ""),
 			DB:       getEnvAsInt("REDIS_DB", 0),
 		},
+		Auth: AuthConfig{
+			TokenSecret:     getEnv("JWT_SECRET", ""),
+			AccessTokenTTL:  getEnvAsDuration("ACCESS_TOKEN_TTL", 2*time.Hour),
+			RefreshTokenTTL: getEnvAsDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+		},
+		Webhook: WebhookConfig{
+			Timeout:        getEnvAsDuration("WEBHOOK_TIMEOUT", 5*time.Second),
+			MaxAttempts:    getEnvAsInt("WEBHOOK_MAX_ATTEMPTS", 5),
+			InitialBackoff: getEnvAsDuration("WEBHOOK_INITIAL_BACKOFF", time.Second),
+			MaxBackoff:     getEnvAsDuration("WEBHOOK_MAX_BACKOFF", time.Minute),
+		},
+		Message: MessageConfig{
+			EditWindow:   getEnvAsDuration("MESSAGE_EDIT_WINDOW", 15*time.Minute),
+			RecallWindow: getEnvAsDuration("MESSAGE_RECALL_WINDOW", 2*time.Minute),
+		},
+	}
+
+	ws := DefaultWebSocketConfig()
+	GlobalConfig.WebSocket = WebSocketConfig{
+		PingInterval: getEnvAsDuration("WS_PING_INTERVAL", ws.PingInterval),
+		PongWait:     getEnvAsDuration("WS_PONG_WAIT", ws.PongWait),
+		WriteTimeout: getEnvAsDuration("WS_WRITE_TIMEOUT", ws.WriteTimeout),
+		MaxIdle:      getEnvAsDuration("WS_MAX_IDLE", ws.MaxIdle),
+		BotMaxIdle:   getEnvAsDuration("WS_BOT_MAX_IDLE", ws.BotMaxIdle),
+		SendBuffer:   getEnvAsInt("WS_SEND_BUFFER", ws.SendBuffer),
+		SlowConsumer: getEnv("WS_SLOW_CONSUMER", ws.SlowConsumer),
+		Broker:       getEnv("WS_BROKER", ws.Broker),
+		OnlineSync:   getEnvAsDuration("WS_ONLINE_SYNC", ws.OnlineSync),
+
+		TypingThrottle: getEnvAsDuration("WS_TYPING_THROTTLE", ws.TypingThrottle),
+		TypingTTL:      getEnvAsDuration("WS_TYPING_TTL", ws.TypingTTL),
+	}
+	if GlobalConfig.WebSocket.PingInterval >= GlobalConfig.WebSocket.PongWait {
+		GlobalConfig.WebSocket.PingInterval = GlobalConfig.WebSocket.PongWait * 9 / 10
+		log.Printf("WS_PING_INTERVAL must be less than WS_PONG_WAIT, using %s", GlobalConfig.WebSocket.PingInterval)
+	}
+	if GlobalConfig.WebSocket.SendBuffer <= 0 {
+		GlobalConfig.WebSocket.SendBuffer = ws.SendBuffer
+	}
+	switch GlobalConfig.WebSocket.SlowConsumer {
+	case SlowConsumerDropOldest, SlowConsumerDisconnect:
+	default:
+		log.Printf("Unknown WS_SLOW_CONSUMER %q, using %s", GlobalConfig.WebSocket.SlowConsumer, ws.SlowConsumer)
+		GlobalConfig.WebSocket.SlowConsumer = ws.SlowConsumer
+	}
+	if GlobalConfig.WebSocket.OnlineSync <= 0 {
+		GlobalConfig.WebSocket.OnlineSync = ws.OnlineSync
+	}
+	if GlobalConfig.WebSocket.TypingTTL <= 0 {
+		GlobalConfig.WebSocket.TypingTTL = ws.TypingTTL
 	}
-	return GlobalConfig
+	switch GlobalConfig.WebSocket.Broker {
+	case BrokerMemory, BrokerRedis:
+	default:
+		return nil, fmt.Errorf("unknown WS_BROKER %q", GlobalConfig.WebSocket.Broker)
+	}
+	if GlobalConfig.Auth.TokenSecret == "" {
+		// 多节点各自生成随机密钥时，一个节点签发的令牌在其他节点无法通过校验
+		if GlobalConfig.WebSocket.Broker == BrokerRedis {
+			return nil, errors.New("JWT_SECRET is required when WS_BROKER=redis")
+		}
+		log.Println("JWT_SECRET not set, tokens will not survive a restart")
+	}
+	return GlobalConfig, nil
 }
 
 func getEnv(key, defaultVal string) string {
@@ -70,6 +209,19 @@ func getEnv(key, defaultVal string) string {
 }
 
 func getEnvAsInt(key string, defaultVal int) int {
-	// 简化处理，实际应转换
+	if val := os.Getenv(key); val != "" {
+		if n, err := strconv.Atoi(val); err == nil {
+			return n
+		}
+	}
+	return defaultVal
+}
+
+func getEnvAsDuration(key string, defaultVal time.Duration) time.Duration {
+	if val := os.Getenv(key); val != "" {
+		if d, err := time.ParseDuration(val); err == nil {
+			return d
+		}
+	}
 	return defaultVal
 }
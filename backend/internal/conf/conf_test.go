@@ -0,0 +1,32 @@
+package conf
+
+import "testing"
+
+func TestLoadConfigBrokerSecret(t *testing.T) {
+	tests := []struct {
+		name    string
+		broker  string
+		secret  string
+		wantErr bool
+	}{
+		{"memory without secret", "", "", false},
+		{"memory with secret", BrokerMemory, "s3cret", false},
+		{"redis with secret", BrokerRedis, "s3cret", false},
+		{"redis without secret", BrokerRedis, "", true},
+		{"unknown broker", "kafka", "s3cret", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("WS_BROKER", tt.broker)
+			t.Setenv("JWT_SECRET", tt.secret)
+
+			config, err := LoadConfig()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadConfig error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && config.Auth.TokenSecret != tt.secret {
+				t.Fatalf("TokenSecret = %q, want %q", config.Auth.TokenSecret, tt.secret)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package dao
+
+import (
+	"github.com/baijianruoli/bot_chat/backend/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RoomBanDAO 房间封禁名单数据访问对象
+type RoomBanDAO struct {
+	db *gorm.DB
+}
+
+// NewRoomBanDAO 创建 RoomBanDAO
+func NewRoomBanDAO(db *gorm.DB) *RoomBanDAO {
+	return &RoomBanDAO{db: db}
+}
+
+// Ban 将用户加入封禁名单，已封禁时忽略
+func (d *RoomBanDAO) Ban(roomID, userID, operatorID string) error {
+	ban := &model.RoomBan{
+		RoomID:     roomID,
+		UserID:     userID,
+		OperatorID: operatorID,
+	}
+	return d.db.Clauses(clause.OnConflict{DoNothing: true}).Create(ban).Error
+}
+
+// Unban 将用户移出封禁名单，返回是否曾被封禁
+func (d *RoomBanDAO) Unban(roomID, userID string) (bool, error) {
+	result := d.db.Where("room_id = ? AND user_id = ?", roomID, userID).Delete(&model.RoomBan{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// IsBanned 用户是否被房间封禁
+func (d *RoomBanDAO) IsBanned(roomID, userID string) (bool, error) {
+	var count int64
+	err := d.db.Model(&model.RoomBan{}).
+		Where("room_id = ? AND user_id = ?", roomID, userID).
+		Count(&count).Error
+	return count > 0, err
+}
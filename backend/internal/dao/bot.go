@@ -0,0 +1,39 @@
+package dao
+
+import (
+	"github.com/baijianruoli/bot_chat/backend/internal/model"
+	"github.com/baijianruoli/bot_chat/backend/internal/utils"
+	"gorm.io/gorm"
+)
+
+// BotTokenDAO 机器人令牌数据访问对象
+type BotTokenDAO struct {
+	db *gorm.DB
+}
+
+// NewBotTokenDAO 创建 BotTokenDAO
+func NewBotTokenDAO(db *gorm.DB) *BotTokenDAO {
+	return &BotTokenDAO{db: db}
+}
+
+// Create 创建令牌
+func (d *BotTokenDAO) Create(token *model.BotToken) error {
+	return d.db.Create(token).Error
+}
+
+// GetByID 根据ID获取令牌
+func (d *BotTokenDAO) GetByID(tokenID string) (*model.BotToken, error) {
+	var token model.BotToken
+	err := d.db.Where("token_id = ?", tokenID).First(&token).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	return &token, err
+}
+
+// Revoke 吊销令牌
+func (d *BotTokenDAO) Revoke(tokenID string) error {
+	return d.db.Model(&model.BotToken{}).
+		Where("token_id = ? AND revoked_at = 0", tokenID).
+		Update("revoked_at", utils.GetCurrentTimestamp()).Error
+}
@@ -33,18 +33,63 @@ func InitDB() (*gorm.DB, error) {
 		return nil, fmt.Errorf("failed to connect database: %v", err)
 	}
 	
+	// 建唯一索引前清理升级前产生的重复行
+	if err := NewRoomMemberDAO(db).Dedupe(); err != nil {
+		return nil, fmt.Errorf("failed to dedupe room members: %v", err)
+	}
+	if err := NewReactionDAO(db).Dedupe(); err != nil {
+		return nil, fmt.Errorf("failed to dedupe reactions: %v", err)
+	}
+	
 	// 自动迁移
 	err = db.AutoMigrate(
 		&model.User{},
 		&model.Room{},
 		&model.RoomMember{},
+		&model.RoomInvite{},
+		&model.JoinRequest{},
+		&model.RoomBan{},
 		&model.Message{},
+		&model.MessageRevision{},
+		&model.MessageAudit{},
+		&model.Reaction{},
+		&model.Session{},
+		&model.BotToken{},
+		&model.OutgoingWebhook{},
+		&model.IncomingWebhook{},
+		&model.WebhookDeadLetter{},
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to migrate database: %v", err)
 	}
 	
+	// 旧消息新增列的 NULL 补为默认值
+	if err := NewMessageDAO(db).BackfillDefaults(); err != nil {
+		return nil, fmt.Errorf("failed to backfill message columns: %v", err)
+	}
+	
+	// 旧消息补齐房间序号
+	if err := NewMessageDAO(db).BackfillSeq(); err != nil {
+		return nil, fmt.Errorf("failed to backfill message seq: %v", err)
+	}
+	
+	// 引入成员角色前创建的房间，创建者补为房主
+	if err := NewRoomMemberDAO(db).BackfillOwners(); err != nil {
+		return nil, fmt.Errorf("failed to backfill room owners: %v", err)
+	}
+	
 	DB = db
 	log.Println("Database connected successfully")
 	return db, nil
 }
+
+// dedupe 删除 columns 相同的重复行，只保留 id 最小的一行；表尚未创建时跳过
+func dedupe(db *gorm.DB, table interface{}, columns string) error {
+	if !db.Migrator().HasTable(table) {
+		return nil
+	}
+	
+	keep := db.Model(table).Select("MIN(id)").Group(columns)
+	// MySQL 不允许 DELETE 的子查询直接读取同一张表，包一层派生表
+	return db.Where("id NOT IN (?)", db.Table("(?) AS keep", keep).Select("*")).Delete(table).Error
+}
@@ -0,0 +1,133 @@
+package dao
+
+import (
+	"errors"
+
+	"github.com/baijianruoli/bot_chat/backend/internal/model"
+	"gorm.io/gorm"
+)
+
+// ErrRequestReviewed 申请已被处理
+var ErrRequestReviewed = errors.New("join request already reviewed")
+
+// RoomInviteDAO 房间邀请链接数据访问对象
+type RoomInviteDAO struct {
+	db *gorm.DB
+}
+
+// NewRoomInviteDAO 创建 RoomInviteDAO
+func NewRoomInviteDAO(db *gorm.DB) *RoomInviteDAO {
+	return &RoomInviteDAO{db: db}
+}
+
+// Create 创建邀请链接
+func (d *RoomInviteDAO) Create(invite *model.RoomInvite) error {
+	return d.db.Create(invite).Error
+}
+
+// GetByCode 根据邀请码获取邀请链接
+func (d *RoomInviteDAO) GetByCode(code string) (*model.RoomInvite, error) {
+	var invite model.RoomInvite
+	err := d.db.Where("code = ?", code).First(&invite).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	return &invite, err
+}
+
+// Use 使用一次邀请链接，已撤销、过期或次数用尽时返回 false
+func (d *RoomInviteDAO) Use(code string, now int64) (bool, error) {
+	result := d.db.Model(&model.RoomInvite{}).
+		Where("code = ? AND revoked_at = 0", code).
+		Where("max_uses = 0 OR uses < max_uses").
+		Where("expires_at = 0 OR expires_at > ?", now).
+		Update("uses", gorm.Expr("uses + 1"))
+	return result.RowsAffected > 0, result.Error
+}
+
+// JoinWithInvite 使用一次邀请链接并添加成员，两者在同一事务中完成，添加失败时不消耗次数。
+// 链接已撤销、过期或次数用尽时返回 false
+func (d *RoomInviteDAO) JoinWithInvite(code, roomID, userID string, now int64) (bool, error) {
+	joined := false
+	err := d.db.Transaction(func(tx *gorm.DB) error {
+		ok, err := NewRoomInviteDAO(tx).Use(code, now)
+		if err != nil || !ok {
+			return err
+		}
+		if err := NewRoomMemberDAO(tx).AddMember(roomID, userID); err != nil {
+			return err
+		}
+		joined = true
+		return nil
+	})
+	return joined, err
+}
+
+// Revoke 撤销邀请链接
+func (d *RoomInviteDAO) Revoke(code string, now int64) error {
+	return d.db.Model(&model.RoomInvite{}).
+		Where("code = ? AND revoked_at = 0", code).
+		Update("revoked_at", now).Error
+}
+
+// JoinRequestDAO 入群申请数据访问对象
+type JoinRequestDAO struct {
+	db *gorm.DB
+}
+
+// NewJoinRequestDAO 创建 JoinRequestDAO
+func NewJoinRequestDAO(db *gorm.DB) *JoinRequestDAO {
+	return &JoinRequestDAO{db: db}
+}
+
+// Submit 提交入群申请，已有待审批的申请时返回该申请
+func (d *JoinRequestDAO) Submit(roomID, userID string) (*model.JoinRequest, error) {
+	request := &model.JoinRequest{
+		RoomID: roomID,
+		UserID: userID,
+		Status: model.JoinRequestPending,
+	}
+	err := d.db.Where("room_id = ? AND user_id = ? AND status = ?", roomID, userID, model.JoinRequestPending).
+		FirstOrCreate(request).Error
+	return request, err
+}
+
+// GetByID 根据ID获取申请
+func (d *JoinRequestDAO) GetByID(id uint64) (*model.JoinRequest, error) {
+	var request model.JoinRequest
+	err := d.db.Where("id = ?", id).First(&request).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	return &request, err
+}
+
+// ListPending 获取房间待审批的申请，按提交时间排列
+func (d *JoinRequestDAO) ListPending(roomID string) ([]*model.JoinRequest, error) {
+	var requests []*model.JoinRequest
+	err := d.db.Where("room_id = ? AND status = ?", roomID, model.JoinRequestPending).
+		Order("id ASC").
+		Find(&requests).Error
+	return requests, err
+}
+
+// Review 审批申请，申请已被处理时返回 ErrRequestReviewed
+func (d *JoinRequestDAO) Review(request *model.JoinRequest, status, reviewerID string, now int64) error {
+	result := d.db.Model(&model.JoinRequest{}).
+		Where("id = ? AND status = ?", request.ID, model.JoinRequestPending).
+		Updates(map[string]interface{}{
+			"status":      status,
+			"reviewer_id": reviewerID,
+			"reviewed_at": now,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrRequestReviewed
+	}
+	request.Status = status
+	request.ReviewerID = reviewerID
+	request.ReviewedAt = now
+	return nil
+}
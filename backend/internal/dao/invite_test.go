@@ -0,0 +1,17 @@
+package dao
+
+import "testing"
+
+func TestInviteUseConditions(t *testing.T) {
+	db, recorder := newDryRunDB(t)
+	if _, err := NewRoomInviteDAO(db).Use("abc", 1700000000000); err != nil {
+		t.Fatalf("Use: %v", err)
+	}
+
+	// 已撤销、用尽或过期的邀请不会被使用
+	want := "UPDATE `room_invites` SET `uses`=uses + 1 WHERE (code = 'abc' AND revoked_at = 0) " +
+		"AND (max_uses = 0 OR uses < max_uses) AND (expires_at = 0 OR expires_at > 1700000000000)"
+	if len(recorder.statements) != 1 || recorder.statements[0] != want {
+		t.Errorf("statements = %q, want %q", recorder.statements, want)
+	}
+}
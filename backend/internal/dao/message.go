@@ -1,9 +1,15 @@
 package dao
 
 import (
+	"errors"
+	
 	"github.com/baijianruoli/bot_chat/backend/internal/model"
+	"gorm.io/gorm"
 )
 
+// ErrMessageChanged 消息在读取后已被其他请求修改
+var ErrMessageChanged = errors.New("message changed")
+
 // MessageDAO 消息数据访问对象
 type MessageDAO struct {
 	db *gorm.DB
@@ -14,22 +20,53 @@ func NewMessageDAO(db *gorm.DB) *MessageDAO {
 	return &MessageDAO{db: db}
 }
 
-// Create 创建消息
+// Create 创建消息，在同一事务内递增房间的 last_seq 作为消息序号；话题回复同时更新根消息的回复数
 func (d *MessageDAO) Create(msg *model.Message) error {
-	return d.db.Create(msg).Error
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		// 行锁保证同一房间的序号不重复
+		result := tx.Model(&model.Room{}).
+			Where("room_id = ?", msg.RoomID).
+			Update("last_seq", gorm.Expr("last_seq + 1"))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		
+		var room model.Room
+		if err := tx.Select("last_seq").Where("room_id = ?", msg.RoomID).First(&room).Error; err != nil {
+			return err
+		}
+		msg.Seq = room.LastSeq
+		
+		if err := tx.Create(msg).Error; err != nil {
+			return err
+		}
+		
+		if msg.ThreadRootID == "" {
+			return nil
+		}
+		return tx.Model(&model.Message{}).
+			Where("msg_id = ?", msg.ThreadRootID).
+			Updates(map[string]interface{}{
+				"reply_count":   gorm.Expr("reply_count + 1"),
+				"last_reply_at": msg.CreatedAt,
+			}).Error
+	})
 }
 
-// GetHistory 获取历史消息
-func (d *MessageDAO) GetHistory(roomID string, beforeTime int64, limit int) ([]*model.Message, error) {
+// GetHistory 获取房间主时间线的历史消息（不含话题回复），beforeSeq 为 0 时从最新一条开始
+func (d *MessageDAO) GetHistory(roomID string, beforeSeq int64, limit int) ([]*model.Message, error) {
 	var messages []*model.Message
 	
-	query := d.db.Where("room_id = ?", roomID)
+	query := d.db.Where("room_id = ? AND thread_root_id = ''", roomID)
 	
-	if beforeTime > 0 {
-		query = query.Where("created_at < ?", beforeTime)
+	if beforeSeq > 0 {
+		query = query.Where("seq < ?", beforeSeq)
 	}
 	
-	err := query.Order("created_at DESC").Limit(limit).Find(&messages).Error
+	err := query.Order("seq DESC").Limit(limit).Find(&messages).Error
 	
 	// 反转顺序
 	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
@@ -48,3 +85,225 @@ func (d *MessageDAO) GetByID(msgID string) (*model.Message, error) {
 	}
 	return &msg, err
 }
+
+// ListAfterSeq 按序号升序获取房间主时间线 afterSeq 之后的消息，用于断线重连补齐
+func (d *MessageDAO) ListAfterSeq(roomID string, afterSeq int64, limit int) ([]*model.Message, error) {
+	var messages []*model.Message
+	err := d.db.Where("room_id = ? AND thread_root_id = '' AND seq > ?", roomID, afterSeq).
+		Order("seq ASC").
+		Limit(limit).
+		Find(&messages).Error
+	return messages, err
+}
+
+// messageStringColumns 升级后新增的字符串列，旧版本迁移时可能以 NULL 加入
+var messageStringColumns = []string{"thread_root_id", "reply_to_msg_id", "sender_name", "recalled_by"}
+
+// BackfillDefaults 将旧消息新增列中的 NULL 补为空串，否则按 thread_root_id 为空过滤主时间线时会漏掉旧消息
+func (d *MessageDAO) BackfillDefaults() error {
+	for _, column := range messageStringColumns {
+		err := d.db.Model(&model.Message{}).Where(column+" IS NULL").Update(column, "").Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BackfillSeq 为升级前没有序号的消息按创建时间补齐序号
+func (d *MessageDAO) BackfillSeq() error {
+	var roomIDs []string
+	err := d.db.Model(&model.Message{}).Where("seq IS NULL OR seq = 0").Group("room_id").Pluck("room_id", &roomIDs).Error
+	if err != nil {
+		return err
+	}
+	
+	for _, roomID := range roomIDs {
+		err := d.db.Transaction(func(tx *gorm.DB) error {
+			var room model.Room
+			if err := tx.Where("room_id = ?", roomID).First(&room).Error; err != nil {
+				return err
+			}
+			
+			var msgIDs []string
+			err := tx.Model(&model.Message{}).
+				Where("room_id = ? AND (seq IS NULL OR seq = 0)", roomID).
+				Order("created_at ASC, msg_id ASC").
+				Pluck("msg_id", &msgIDs).Error
+			if err != nil {
+				return err
+			}
+			
+			seq := room.LastSeq
+			for _, msgID := range msgIDs {
+				seq++
+				if err := tx.Model(&model.Message{}).Where("msg_id = ?", msgID).Update("seq", seq).Error; err != nil {
+					return err
+				}
+			}
+			return tx.Model(&model.Room{}).Where("room_id = ?", roomID).Update("last_seq", seq).Error
+		})
+		if err != nil && err != gorm.ErrRecordNotFound {
+			return err
+		}
+	}
+	return nil
+}
+
+// Edit 修改消息内容并保存修改前的版本。
+// 只有消息自读取后未被再次编辑时才会成功，否则返回 ErrMessageChanged
+func (d *MessageDAO) Edit(msg *model.Message, content, editorID string, editedAt int64) error {
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.Message{}).
+			Where("msg_id = ? AND edited_at = ? AND recalled_at = 0", msg.MsgID, msg.EditedAt).
+			Updates(map[string]interface{}{
+				"content":   content,
+				"edited_at": editedAt,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrMessageChanged
+		}
+		
+		revision := &model.MessageRevision{
+			MsgID:    msg.MsgID,
+			Content:  msg.Content,
+			EditorID: editorID,
+		}
+		if err := tx.Create(revision).Error; err != nil {
+			return err
+		}
+		
+		msg.Content = content
+		msg.EditedAt = editedAt
+		return nil
+	})
+}
+
+// ListRevisions 获取多条消息的历史版本，按消息ID分组并按时间升序
+func (d *MessageDAO) ListRevisions(msgIDs []string) (map[string][]*model.MessageRevision, error) {
+	revisions := make(map[string][]*model.MessageRevision)
+	if len(msgIDs) == 0 {
+		return revisions, nil
+	}
+	
+	var list []*model.MessageRevision
+	err := d.db.Where("msg_id IN ?", msgIDs).Order("id ASC").Find(&list).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, revision := range list {
+		revisions[revision.MsgID] = append(revisions[revision.MsgID], revision)
+	}
+	return revisions, nil
+}
+
+// Recall 将消息替换为墓碑：清空内容与表情回应，写入审计记录，话题回复同时减少根消息的回复数。
+// 历史版本保留在 message_revisions 中供审计，不再返回给客户端。消息已被撤回或删除时返回 ErrMessageChanged
+func (d *MessageDAO) Recall(msg *model.Message, action, operatorID string, recalledAt int64) error {
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.Message{}).
+			Where("msg_id = ? AND recalled_at = 0", msg.MsgID).
+			Updates(map[string]interface{}{
+				"content":     "",
+				"recalled_at": recalledAt,
+				"recalled_by": operatorID,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrMessageChanged
+		}
+		
+		if err := tx.Where("msg_id = ?", msg.MsgID).Delete(&model.Reaction{}).Error; err != nil {
+			return err
+		}
+		if msg.ThreadRootID != "" {
+			err := tx.Model(&model.Message{}).
+				Where("msg_id = ? AND reply_count > 0", msg.ThreadRootID).
+				Update("reply_count", gorm.Expr("reply_count - 1")).Error
+			if err != nil {
+				return err
+			}
+		}
+		
+		audit := &model.MessageAudit{
+			MsgID:      msg.MsgID,
+			RoomID:     msg.RoomID,
+			AuthorID:   msg.UserID,
+			Action:     action,
+			OperatorID: operatorID,
+			Content:    msg.Content,
+		}
+		if err := tx.Create(audit).Error; err != nil {
+			return err
+		}
+		
+		msg.Content = ""
+		msg.RecalledAt = recalledAt
+		msg.RecalledBy = operatorID
+		return nil
+	})
+}
+
+// LastMessages 获取各房间主时间线的最后一条消息
+func (d *MessageDAO) LastMessages(roomIDs []string) (map[string]*model.Message, error) {
+	messages := make(map[string]*model.Message)
+	if len(roomIDs) == 0 {
+		return messages, nil
+	}
+	
+	latest := d.db.Model(&model.Message{}).
+		Select("room_id, MAX(seq)").
+		Where("room_id IN ? AND thread_root_id = ''", roomIDs).
+		Group("room_id")
+	
+	var list []*model.Message
+	if err := d.db.Where("(room_id, seq) IN (?)", latest).Find(&list).Error; err != nil {
+		return nil, err
+	}
+	for _, msg := range list {
+		messages[msg.RoomID] = msg
+	}
+	return messages, nil
+}
+
+// GetByIDs 批量获取消息，按消息ID索引
+func (d *MessageDAO) GetByIDs(msgIDs []string) (map[string]*model.Message, error) {
+	messages := make(map[string]*model.Message)
+	if len(msgIDs) == 0 {
+		return messages, nil
+	}
+	
+	var list []*model.Message
+	if err := d.db.Where("msg_id IN ?", msgIDs).Find(&list).Error; err != nil {
+		return nil, err
+	}
+	for _, msg := range list {
+		messages[msg.MsgID] = msg
+	}
+	return messages, nil
+}
+
+// ListThread 按序号升序获取话题中 afterSeq 之后的回复
+func (d *MessageDAO) ListThread(rootID string, afterSeq int64, limit int) ([]*model.Message, error) {
+	var messages []*model.Message
+	err := d.db.Where("thread_root_id = ? AND seq > ?", rootID, afterSeq).
+		Order("seq ASC").
+		Limit(limit).
+		Find(&messages).Error
+	return messages, err
+}
+
+// ThreadParticipants 获取在话题中回复过的用户
+func (d *MessageDAO) ThreadParticipants(rootID string) ([]string, error) {
+	var userIDs []string
+	err := d.db.Model(&model.Message{}).
+		Where("thread_root_id = ? AND user_id <> ''", rootID).
+		Group("user_id").
+		Pluck("user_id", &userIDs).Error
+	return userIDs, err
+}
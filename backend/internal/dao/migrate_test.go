@@ -0,0 +1,158 @@
+package dao
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/baijianruoli/bot_chat/backend/internal/model"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// sqlRecorder 记录执行的 SQL 的日志器
+type sqlRecorder struct {
+	statements []string
+}
+
+func (r *sqlRecorder) LogMode(logger.LogLevel) logger.Interface      { return r }
+func (r *sqlRecorder) Info(context.Context, string, ...interface{})  {}
+func (r *sqlRecorder) Warn(context.Context, string, ...interface{})  {}
+func (r *sqlRecorder) Error(context.Context, string, ...interface{}) {}
+func (r *sqlRecorder) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	sql, _ := fc()
+	r.statements = append(r.statements, sql)
+}
+
+// newDryRunDB 创建只生成 SQL、不连接数据库的 MySQL 会话
+func newDryRunDB(t *testing.T) (*gorm.DB, *sqlRecorder) {
+	t.Helper()
+
+	recorder := &sqlRecorder{}
+	db, err := gorm.Open(mysql.New(mysql.Config{
+		DSN:                       "user:pass@tcp(127.0.0.1:3306)/bot_chat",
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{
+		DryRun:                 true,
+		DisableAutomaticPing:   true,
+		SkipDefaultTransaction: true,
+		Logger:                 recorder,
+	})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	return db, recorder
+}
+
+// TestMessageColumnsAddedWithDefaults 升级前的 messages 表没有这些列，AutoMigrate 以 ADD COLUMN 加入，
+// 必须带默认值，旧消息才不会是 NULL
+func TestMessageColumnsAddedWithDefaults(t *testing.T) {
+	columns := map[string]string{
+		"Seq":          "DEFAULT 0",
+		"ThreadRootID": "DEFAULT ''",
+		"ReplyToMsgID": "DEFAULT ''",
+		"SenderName":   "DEFAULT ''",
+		"RecalledBy":   "DEFAULT ''",
+		"EditedAt":     "DEFAULT 0",
+		"RecalledAt":   "DEFAULT 0",
+		"ReplyCount":   "DEFAULT 0",
+		"LastReplyAt":  "DEFAULT 0",
+	}
+	assertAddColumn(t, &model.Message{}, columns)
+}
+
+// assertAddColumn 模拟在升级前的表上逐列 ADD COLUMN，检查生成的列定义
+func assertAddColumn(t *testing.T, value interface{}, columns map[string]string) {
+	t.Helper()
+
+	for field, want := range columns {
+		db, recorder := newDryRunDB(t)
+		if err := db.Migrator().AddColumn(value, field); err != nil {
+			t.Fatalf("AddColumn(%s): %v", field, err)
+		}
+		if len(recorder.statements) != 1 || !strings.Contains(recorder.statements[0], want) {
+			t.Errorf("AddColumn(%s) = %q, want %s", field, recorder.statements, want)
+		}
+	}
+}
+
+func TestBackfillDefaults(t *testing.T) {
+	db, recorder := newDryRunDB(t)
+	if err := NewMessageDAO(db).BackfillDefaults(); err != nil {
+		t.Fatalf("BackfillDefaults: %v", err)
+	}
+
+	if len(recorder.statements) != len(messageStringColumns) {
+		t.Fatalf("statements = %q", recorder.statements)
+	}
+	for i, column := range messageStringColumns {
+		want := "UPDATE `messages` SET `" + column + "`='' WHERE " + column + " IS NULL"
+		if recorder.statements[i] != want {
+			t.Errorf("statement %d = %q, want %q", i, recorder.statements[i], want)
+		}
+	}
+}
+
+func TestBackfillSeqIncludesNullSeq(t *testing.T) {
+	db, recorder := newDryRunDB(t)
+	if err := NewMessageDAO(db).BackfillSeq(); err != nil {
+		t.Fatalf("BackfillSeq: %v", err)
+	}
+
+	want := "SELECT `room_id` FROM `messages` WHERE seq IS NULL OR seq = 0 GROUP BY `room_id`"
+	if len(recorder.statements) != 1 || recorder.statements[0] != want {
+		t.Errorf("statements = %q, want %q", recorder.statements, want)
+	}
+}
+
+func TestReactionUniqueIndex(t *testing.T) {
+	db, recorder := newDryRunDB(t)
+	if err := db.Migrator().CreateTable(&model.Reaction{}); err != nil {
+		t.Fatalf("CreateTable: %v", err)
+	}
+
+	// 唯一索引的列须有长度，MySQL 不能为 longtext 建索引
+	ddl := strings.Join(recorder.statements, "\n")
+	for _, want := range []string{
+		"`msg_id` varchar(64)",
+		"`user_id` varchar(64)",
+		"`emoji` varchar(64)",
+		"UNIQUE INDEX `idx_reaction` (`msg_id`,`user_id`,`emoji`)",
+	} {
+		if !strings.Contains(ddl, want) {
+			t.Errorf("DDL missing %s:\n%s", want, ddl)
+		}
+	}
+}
+
+func TestReadCursorColumnsAddedWithDefaults(t *testing.T) {
+	assertAddColumn(t, &model.RoomMember{}, map[string]string{
+		"LastReadSeq": "DEFAULT 0",
+		"LastReadAt":  "DEFAULT 0",
+	})
+}
+
+func TestDirectRoomMigration(t *testing.T) {
+	// 升级前的房间都是群聊
+	assertAddColumn(t, &model.Room{}, map[string]string{
+		"Kind": "DEFAULT 'group'",
+	})
+
+	db, recorder := newDryRunDB(t)
+	if err := db.Migrator().CreateIndex(&model.RoomMember{}, "idx_room_user"); err != nil {
+		t.Fatalf("CreateIndex: %v", err)
+	}
+	want := "CREATE UNIQUE INDEX `idx_room_user` ON `room_members`(`room_id`,`user_id`)"
+	if len(recorder.statements) != 1 || recorder.statements[0] != want {
+		t.Errorf("statements = %q, want %q", recorder.statements, want)
+	}
+}
+
+func TestRoomVisibilityMigration(t *testing.T) {
+	// 升级前的房间都是公开的
+	assertAddColumn(t, &model.Room{}, map[string]string{
+		"Visibility": "DEFAULT 'public'",
+	})
+}
@@ -0,0 +1,68 @@
+package dao
+
+import (
+	"github.com/baijianruoli/bot_chat/backend/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ReactionDAO 表情回应数据访问对象
+type ReactionDAO struct {
+	db *gorm.DB
+}
+
+// NewReactionDAO 创建 ReactionDAO
+func NewReactionDAO(db *gorm.DB) *ReactionDAO {
+	return &ReactionDAO{db: db}
+}
+
+// ReactionCount 消息上某个表情的汇总
+type ReactionCount struct {
+	MsgID   string
+	Emoji   string
+	Count   int32
+	Reacted bool // 查询者是否回应过
+}
+
+// Add 添加表情回应，已存在时忽略
+func (d *ReactionDAO) Add(msgID, userID, emoji string) error {
+	reaction := &model.Reaction{
+		MsgID:  msgID,
+		UserID: userID,
+		Emoji:  emoji,
+	}
+	return d.db.Clauses(clause.OnConflict{DoNothing: true}).Create(reaction).Error
+}
+
+// Remove 移除表情回应，不存在时忽略
+func (d *ReactionDAO) Remove(msgID, userID, emoji string) error {
+	return d.db.Where("msg_id = ? AND user_id = ? AND emoji = ?", msgID, userID, emoji).Delete(&model.Reaction{}).Error
+}
+
+// Summaries 按消息汇总各表情的回应数，表情按首次回应的先后排列
+func (d *ReactionDAO) Summaries(msgIDs []string, userID string) (map[string][]*ReactionCount, error) {
+	summaries := make(map[string][]*ReactionCount)
+	if len(msgIDs) == 0 {
+		return summaries, nil
+	}
+
+	var counts []*ReactionCount
+	err := d.db.Model(&model.Reaction{}).
+		Select("msg_id, emoji, COUNT(*) AS count, SUM(user_id = ?) > 0 AS reacted", userID).
+		Where("msg_id IN ?", msgIDs).
+		Group("msg_id, emoji").
+		Order("MIN(id)").
+		Scan(&counts).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, count := range counts {
+		summaries[count.MsgID] = append(summaries[count.MsgID], count)
+	}
+	return summaries, nil
+}
+
+// Dedupe 清理同一用户对同一消息的重复表情回应，需在建立唯一索引之前执行
+func (d *ReactionDAO) Dedupe() error {
+	return dedupe(d.db, &model.Reaction{}, "msg_id, user_id, emoji")
+}
@@ -1,10 +1,15 @@
 package dao
 
 import (
+	"errors"
+	
 	"github.com/baijianruoli/bot_chat/backend/internal/model"
 	"gorm.io/gorm"
 )
 
+// ErrRoleChanged 成员角色已被并发修改
+var ErrRoleChanged = errors.New("member role changed")
+
 // RoomDAO 房间数据访问对象
 type RoomDAO struct {
 	db *gorm.DB
@@ -30,22 +35,94 @@ func (d *RoomDAO) GetByID(roomID string) (*model.Room, error) {
 	return &room, err
 }
 
-// List 获取房间列表
-func (d *RoomDAO) List(page, pageSize int) ([]*model.Room, int64, error) {
+// List 获取 userID 可见的房间列表
+func (d *RoomDAO) List(page, pageSize int, userID string) ([]*model.Room, int64, error) {
 	var rooms []*model.Room
 	var total int64
 	
 	offset := (page - 1) * pageSize
 	
-	err := d.db.Model(&model.Room{}).Count(&total).Error
+	// 私聊房间不出现在房间列表中，私有房间只对成员可见
+	members := d.db.Model(&model.RoomMember{}).Select("room_id").Where("user_id = ?", userID)
+	query := d.db.Model(&model.Room{}).
+		Where("kind <> ?", model.RoomKindDirect).
+		Where("visibility <> ? OR room_id IN (?)", model.RoomVisibilityPrivate, members)
+	
+	err := query.Count(&total).Error
 	if err != nil {
 		return nil, 0, err
 	}
 	
-	err = d.db.Offset(offset).Limit(pageSize).Find(&rooms).Error
+	err = query.Offset(offset).Limit(pageSize).Find(&rooms).Error
 	return rooms, total, err
 }
 
+// OpenDirect 获取或创建私聊房间，并确保双方都是成员，返回本次新加入的用户
+func (d *RoomDAO) OpenDirect(room *model.Room, userIDs ...string) ([]string, error) {
+	var joined []string
+	err := d.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("room_id = ?", room.RoomID).FirstOrCreate(room).Error; err != nil {
+			return err
+		}
+		for _, userID := range userIDs {
+			var count int64
+			err := tx.Model(&model.RoomMember{}).Where("room_id = ? AND user_id = ?", room.RoomID, userID).Count(&count).Error
+			if err != nil {
+				return err
+			}
+			if count > 0 {
+				continue
+			}
+			if err := tx.Create(&model.RoomMember{RoomID: room.RoomID, UserID: userID}).Error; err != nil {
+				return err
+			}
+			joined = append(joined, userID)
+		}
+		return nil
+	})
+	return joined, err
+}
+
+// ListDirectByUser 获取用户的私聊房间，按最近活跃排序
+func (d *RoomDAO) ListDirectByUser(userID string) ([]*model.Room, error) {
+	var rooms []*model.Room
+	err := d.db.Model(&model.Room{}).
+		Joins("JOIN room_members ON room_members.room_id = rooms.room_id").
+		Where("room_members.user_id = ? AND rooms.kind = ?", userID, model.RoomKindDirect).
+		Order("rooms.updated_at DESC").
+		Find(&rooms).Error
+	return rooms, err
+}
+
+// TransferOwner 将房主转让给成员 toID，原房主降为管理员；任一方角色已变化时返回 ErrRoleChanged
+func (d *RoomDAO) TransferOwner(roomID, fromID, toID string) error {
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.RoomMember{}).
+			Where("room_id = ? AND user_id = ? AND role = ?", roomID, fromID, model.RoomRoleOwner).
+			Update("role", model.RoomRoleAdmin)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrRoleChanged
+		}
+		
+		result = tx.Model(&model.RoomMember{}).
+			Where("room_id = ? AND user_id = ? AND role <> ?", roomID, toID, model.RoomRoleOwner).
+			Update("role", model.RoomRoleOwner)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrRoleChanged
+		}
+		
+		return tx.Model(&model.Room{}).
+			Where("room_id = ?", roomID).
+			Update("creator_id", toID).Error
+	})
+}
+
 // UpdateUserCount 更新房间人数
 func (d *RoomDAO) UpdateUserCount(roomID string, delta int32) error {
 	return d.db.Model(&model.Room{}).
@@ -53,6 +130,13 @@ func (d *RoomDAO) UpdateUserCount(roomID string, delta int32) error {
 		Update("user_count", gorm.Expr("user_count + ?", delta)).Error
 }
 
+// UpdateDescription 更新房间描述（话题）
+func (d *RoomDAO) UpdateDescription(roomID, description string) error {
+	return d.db.Model(&model.Room{}).
+		Where("room_id = ?", roomID).
+		Update("description", description).Error
+}
+
 // RoomMemberDAO 房间成员 DAO
 type RoomMemberDAO struct {
 	db *gorm.DB
@@ -63,11 +147,17 @@ func NewRoomMemberDAO(db *gorm.DB) *RoomMemberDAO {
 	return &RoomMemberDAO{db: db}
 }
 
-// AddMember 添加成员
+// AddMember 以普通成员身份添加成员
 func (d *RoomMemberDAO) AddMember(roomID, userID string) error {
+	return d.AddMemberWithRole(roomID, userID, model.RoomRoleMember)
+}
+
+// AddMemberWithRole 以指定角色添加成员
+func (d *RoomMemberDAO) AddMemberWithRole(roomID, userID, role string) error {
 	member := &model.RoomMember{
 		RoomID: roomID,
 		UserID: userID,
+		Role:   role,
 	}
 	return d.db.Create(member).Error
 }
@@ -87,6 +177,55 @@ func (d *RoomMemberDAO) IsMember(roomID, userID string) (bool, error) {
 	return count > 0, err
 }
 
+// GetMember 获取成员关系，不是成员时返回 nil
+func (d *RoomMemberDAO) GetMember(roomID, userID string) (*model.RoomMember, error) {
+	var member model.RoomMember
+	err := d.db.Where("room_id = ? AND user_id = ?", roomID, userID).First(&member).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	return &member, err
+}
+
+// SetRole 将成员角色由 from 改为 to，角色已变化或已不是成员时返回 ErrRoleChanged
+func (d *RoomMemberDAO) SetRole(roomID, userID, from, to string) error {
+	result := d.db.Model(&model.RoomMember{}).
+		Where("room_id = ? AND user_id = ? AND role = ?", roomID, userID, from).
+		Update("role", to)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrRoleChanged
+	}
+	return nil
+}
+
+// ListByRoles 获取房间中具有指定角色之一的成员ID
+func (d *RoomMemberDAO) ListByRoles(roomID string, roles []string) ([]string, error) {
+	var userIDs []string
+	err := d.db.Model(&model.RoomMember{}).
+		Where("room_id = ? AND role IN ?", roomID, roles).
+		Pluck("user_id", &userIDs).Error
+	return userIDs, err
+}
+
+// BackfillOwners 为引入角色前创建的群聊补齐房主角色：创建者仍是普通成员时提升为房主
+func (d *RoomMemberDAO) BackfillOwners() error {
+	creators := d.db.Model(&model.Room{}).
+		Select("room_id, creator_id").
+		Where("kind <> ?", model.RoomKindDirect)
+	return d.db.Model(&model.RoomMember{}).
+		Where("role = ? AND (room_id, user_id) IN (?)", model.RoomRoleMember, creators).
+		Update("role", model.RoomRoleOwner).Error
+}
+
+// Dedupe 清理并发加入产生的重复成员行，保留最早加入的一行，需在建立唯一索引之前执行。
+// 房主角色随后由 BackfillOwners 补齐
+func (d *RoomMemberDAO) Dedupe() error {
+	return dedupe(d.db, &model.RoomMember{}, "room_id, user_id")
+}
+
 // GetMembers 获取房间成员列表
 func (d *RoomMemberDAO) GetMembers(roomID string) ([]string, error) {
 	var userIDs []string
@@ -95,3 +234,72 @@ func (d *RoomMemberDAO) GetMembers(roomID string) ([]string, error) {
 		Pluck("user_id", &userIDs).Error
 	return userIDs, err
 }
+
+// SharesRoom 两个用户是否同在某个房间
+func (d *RoomMemberDAO) SharesRoom(userA, userB string) (bool, error) {
+	rooms := d.db.Model(&model.RoomMember{}).
+		Select("room_id").
+		Where("user_id = ?", userB)
+	var count int64
+	err := d.db.Model(&model.RoomMember{}).
+		Where("user_id = ? AND room_id IN (?)", userA, rooms).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// GetRoomsByUser 获取用户加入的房间ID列表
+func (d *RoomMemberDAO) GetRoomsByUser(userID string) ([]string, error) {
+	var roomIDs []string
+	err := d.db.Model(&model.RoomMember{}).
+		Where("user_id = ?", userID).
+		Pluck("room_id", &roomIDs).Error
+	return roomIDs, err
+}
+
+// ReadCursor 成员的已读游标
+type ReadCursor struct {
+	UserID      string
+	LastReadSeq int64
+	LastReadAt  int64
+}
+
+// UnreadCount 成员在房间的未读数
+type UnreadCount struct {
+	RoomID      string
+	LastReadSeq int64
+	Count       int64
+}
+
+// MarkRead 将成员的已读游标推进到 seq，游标只前进不后退，未推进时返回 false
+func (d *RoomMemberDAO) MarkRead(roomID, userID string, seq, readAt int64) (bool, error) {
+	result := d.db.Model(&model.RoomMember{}).
+		Where("room_id = ? AND user_id = ? AND last_read_seq < ?", roomID, userID, seq).
+		Updates(map[string]interface{}{
+			"last_read_seq": seq,
+			"last_read_at":  readAt,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// ListReadCursors 获取房间所有成员的已读游标
+func (d *RoomMemberDAO) ListReadCursors(roomID string) ([]*ReadCursor, error) {
+	var cursors []*ReadCursor
+	err := d.db.Model(&model.RoomMember{}).
+		Select("user_id, last_read_seq, last_read_at").
+		Where("room_id = ?", roomID).
+		Scan(&cursors).Error
+	return cursors, err
+}
+
+// UnreadCounts 统计用户在所有已加入房间的未读数：游标之后他人发送、未撤回的主时间线消息
+func (d *RoomMemberDAO) UnreadCounts(userID string) ([]*UnreadCount, error) {
+	var counts []*UnreadCount
+	err := d.db.Table("room_members AS m").
+		Select("m.room_id, m.last_read_seq, COUNT(msg.msg_id) AS count").
+		Joins("LEFT JOIN messages AS msg ON msg.room_id = m.room_id AND msg.seq > m.last_read_seq "+
+			"AND msg.thread_root_id = '' AND msg.recalled_at = 0 AND msg.user_id <> m.user_id").
+		Where("m.user_id = ?", userID).
+		Group("m.room_id, m.last_read_seq").
+		Scan(&counts).Error
+	return counts, err
+}
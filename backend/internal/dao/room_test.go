@@ -0,0 +1,19 @@
+package dao
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRoomListHidesPrivateRooms(t *testing.T) {
+	db, recorder := newDryRunDB(t)
+	if _, _, err := NewRoomDAO(db).List(1, 20, "u_1"); err != nil {
+		t.Fatalf("List: %v", err)
+	}
+
+	want := "WHERE kind <> 'direct' AND (visibility <> 'private' OR room_id IN " +
+		"(SELECT `room_id` FROM `room_members` WHERE user_id = 'u_1'))"
+	if len(recorder.statements) == 0 || !strings.Contains(recorder.statements[0], want) {
+		t.Errorf("statements = %q, want filter %q", recorder.statements, want)
+	}
+}
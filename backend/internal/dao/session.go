@@ -0,0 +1,57 @@
+package dao
+
+import (
+	"github.com/baijianruoli/bot_chat/backend/internal/model"
+	"github.com/baijianruoli/bot_chat/backend/internal/utils"
+	"gorm.io/gorm"
+)
+
+// SessionDAO 会话数据访问对象
+type SessionDAO struct {
+	db *gorm.DB
+}
+
+// NewSessionDAO 创建 SessionDAO
+func NewSessionDAO(db *gorm.DB) *SessionDAO {
+	return &SessionDAO{db: db}
+}
+
+// Create 创建会话
+func (d *SessionDAO) Create(session *model.Session) error {
+	return d.db.Create(session).Error
+}
+
+// GetByID 根据ID获取会话
+func (d *SessionDAO) GetByID(sessionID string) (*model.Session, error) {
+	var session model.Session
+	err := d.db.Where("session_id = ?", sessionID).First(&session).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	return &session, err
+}
+
+// Rotate 用新的 refresh token 替换旧的，旧值不匹配时不更新
+func (d *SessionDAO) Rotate(sessionID, oldHash, newHash string, expiresAt int64) (bool, error) {
+	result := d.db.Model(&model.Session{}).
+		Where("session_id = ? AND refresh_hash = ? AND revoked_at = 0", sessionID, oldHash).
+		Updates(map[string]interface{}{
+			"refresh_hash": newHash,
+			"expires_at":   expiresAt,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// Revoke 注销会话
+func (d *SessionDAO) Revoke(sessionID string) error {
+	return d.db.Model(&model.Session{}).
+		Where("session_id = ? AND revoked_at = 0", sessionID).
+		Update("revoked_at", utils.GetCurrentTimestamp()).Error
+}
+
+// RevokeByUser 注销用户的全部会话
+func (d *SessionDAO) RevokeByUser(userID string) error {
+	return d.db.Model(&model.Session{}).
+		Where("user_id = ? AND revoked_at = 0", userID).
+		Update("revoked_at", utils.GetCurrentTimestamp()).Error
+}
@@ -44,3 +44,12 @@ func (d *UserDAO) GetByUsername(username string) (*model.User, error) {
 func (d *UserDAO) Update(user *model.User) error {
 	return d.db.Save(user).Error
 }
+
+// CountBotsByOwner 统计用户创建的机器人数量
+func (d *UserDAO) CountBotsByOwner(ownerID string) (int64, error) {
+	var count int64
+	err := d.db.Model(&model.User{}).
+		Where("owner_id = ? AND user_type = ?", ownerID, model.UserTypeBot).
+		Count(&count).Error
+	return count, err
+}
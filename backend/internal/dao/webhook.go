@@ -0,0 +1,68 @@
+package dao
+
+import (
+	"github.com/baijianruoli/bot_chat/backend/internal/model"
+	"gorm.io/gorm"
+)
+
+// WebhookDAO Webhook 数据访问对象
+type WebhookDAO struct {
+	db *gorm.DB
+}
+
+// NewWebhookDAO 创建 WebhookDAO
+func NewWebhookDAO(db *gorm.DB) *WebhookDAO {
+	return &WebhookDAO{db: db}
+}
+
+// CreateOutgoing 创建外发 Webhook
+func (d *WebhookDAO) CreateOutgoing(hook *model.OutgoingWebhook) error {
+	return d.db.Create(hook).Error
+}
+
+// GetOutgoing 根据ID获取外发 Webhook
+func (d *WebhookDAO) GetOutgoing(webhookID string) (*model.OutgoingWebhook, error) {
+	var hook model.OutgoingWebhook
+	err := d.db.Where("webhook_id = ?", webhookID).First(&hook).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	return &hook, err
+}
+
+// ListOutgoingByRoom 获取房间的外发 Webhook
+func (d *WebhookDAO) ListOutgoingByRoom(roomID string) ([]*model.OutgoingWebhook, error) {
+	var hooks []*model.OutgoingWebhook
+	err := d.db.Where("room_id = ?", roomID).Find(&hooks).Error
+	return hooks, err
+}
+
+// DeleteOutgoing 删除外发 Webhook
+func (d *WebhookDAO) DeleteOutgoing(webhookID string) error {
+	return d.db.Where("webhook_id = ?", webhookID).Delete(&model.OutgoingWebhook{}).Error
+}
+
+// CreateIncoming 创建传入 Webhook
+func (d *WebhookDAO) CreateIncoming(hook *model.IncomingWebhook) error {
+	return d.db.Create(hook).Error
+}
+
+// GetIncoming 根据ID获取传入 Webhook
+func (d *WebhookDAO) GetIncoming(webhookID string) (*model.IncomingWebhook, error) {
+	var hook model.IncomingWebhook
+	err := d.db.Where("webhook_id = ?", webhookID).First(&hook).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	return &hook, err
+}
+
+// DeleteIncoming 删除传入 Webhook
+func (d *WebhookDAO) DeleteIncoming(webhookID string) error {
+	return d.db.Where("webhook_id = ?", webhookID).Delete(&model.IncomingWebhook{}).Error
+}
+
+// CreateDeadLetter 记录投递失败的事件
+func (d *WebhookDAO) CreateDeadLetter(letter *model.WebhookDeadLetter) error {
+	return d.db.Create(letter).Error
+}
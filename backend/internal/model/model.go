@@ -1,5 +1,11 @@
 package model
 
+// 用户类型
+const (
+	UserTypeHuman int32 = 1
+	UserTypeBot   int32 = 2
+)
+
 // User 用户模型
 type User struct {
 	UserID    string `json:"user_id" gorm:"primaryKey"`
@@ -7,10 +13,17 @@ type User struct {
 	Password  string `json:"-" gorm:"not null"`
 	Nickname  string `json:"nickname"`
 	Avatar    string `json:"avatar"`
+	UserType  int32  `json:"user_type" gorm:"default:1"` // 1:普通用户 2:机器人
+	OwnerID   string `json:"owner_id"`                   // 机器人的创建者
 	CreatedAt int64  `json:"created_at" gorm:"autoCreateTime:milli"`
 	UpdatedAt int64  `json:"updated_at" gorm:"autoUpdateTime:milli"`
 }
 
+// IsBot 是否为机器人账号
+func (u *User) IsBot() bool {
+	return u.UserType == UserTypeBot
+}
+
 // Room 聊天室模型
 type Room struct {
 	RoomID      string `json:"room_id" gorm:"primaryKey"`
@@ -18,31 +31,201 @@ type Room struct {
 	Description string `json:"description"`
 	CreatorID   string `json:"creator_id"`
 	UserCount   int32  `json:"user_count" gorm:"default:0"`
+	LastSeq     int64  `json:"last_seq" gorm:"default:0"` // 房间最新消息序号
+	Kind        string `json:"kind" gorm:"size:16;default:group;index"`
+	Visibility  string `json:"visibility" gorm:"size:16;default:public"`
 	CreatedAt   int64  `json:"created_at" gorm:"autoCreateTime:milli"`
 	UpdatedAt   int64  `json:"updated_at" gorm:"autoUpdateTime:milli"`
 }
 
+// 房间类型
+const (
+	RoomKindGroup  = "group"  // 群聊
+	RoomKindDirect = "direct" // 两人私聊，不出现在房间列表中，不能加入或离开
+)
+
+// 房间可见性
+const (
+	RoomVisibilityPublic  = "public"  // 所有人可见，可直接加入
+	RoomVisibilityInvite  = "invite"  // 所有人可见，凭邀请链接加入或申请后由管理者审批
+	RoomVisibilityPrivate = "private" // 仅成员可见，只能凭邀请链接加入
+)
+
+// RoomInvite 房间邀请链接
+type RoomInvite struct {
+	Code      string `json:"code" gorm:"primaryKey"`
+	RoomID    string `json:"room_id" gorm:"index"`
+	CreatorID string `json:"creator_id"`
+	MaxUses   int32  `json:"max_uses" gorm:"default:0"` // 0 表示不限次数
+	Uses      int32  `json:"uses" gorm:"default:0"`
+	ExpiresAt int64  `json:"expires_at" gorm:"default:0"` // 0 表示永不过期
+	RevokedAt int64  `json:"revoked_at" gorm:"default:0"`
+	CreatedAt int64  `json:"created_at" gorm:"autoCreateTime:milli"`
+}
+
+// 入群申请状态
+const (
+	JoinRequestPending  = "pending"
+	JoinRequestApproved = "approved"
+	JoinRequestRejected = "rejected"
+)
+
+// JoinRequest 加入需审批房间的申请
+type JoinRequest struct {
+	ID         uint64 `json:"id" gorm:"primaryKey;autoIncrement"`
+	RoomID     string `json:"room_id" gorm:"index"`
+	UserID     string `json:"user_id" gorm:"index"`
+	Status     string `json:"status" gorm:"size:16;index"`
+	ReviewerID string `json:"reviewer_id"`
+	CreatedAt  int64  `json:"created_at" gorm:"autoCreateTime:milli"`
+	ReviewedAt int64  `json:"reviewed_at" gorm:"default:0"`
+}
+
+// RoomBan 房间封禁名单，被封禁的用户不能以任何方式重新加入
+type RoomBan struct {
+	ID         uint64 `json:"id" gorm:"primaryKey;autoIncrement"`
+	RoomID     string `json:"room_id" gorm:"uniqueIndex:idx_room_ban,priority:1;size:64"`
+	UserID     string `json:"user_id" gorm:"uniqueIndex:idx_room_ban,priority:2;size:64"`
+	OperatorID string `json:"operator_id"`
+	CreatedAt  int64  `json:"created_at" gorm:"autoCreateTime:milli"`
+}
+
+// 房间成员角色，由高到低
+const (
+	RoomRoleOwner     = "owner"     // 房主，每个房间唯一，只能转让
+	RoomRoleAdmin     = "admin"     // 管理员
+	RoomRoleModerator = "moderator" // 协管员
+	RoomRoleMember    = "member"    // 普通成员
+	RoomRoleMuted     = "muted"     // 被禁言的成员，只能查看
+)
+
 // RoomMember 房间成员关系
 type RoomMember struct {
 	ID        uint64 `json:"id" gorm:"primaryKey;autoIncrement"`
-	RoomID    string `json:"room_id" gorm:"index"`
-	UserID    string `json:"user_id" gorm:"index"`
+	RoomID    string `json:"room_id" gorm:"index;uniqueIndex:idx_room_user,priority:1"`
+	UserID    string `json:"user_id" gorm:"index;uniqueIndex:idx_room_user,priority:2"`
 	JoinTime  int64  `json:"join_time" gorm:"autoCreateTime:milli"`
+	Role      string `json:"role" gorm:"size:16;default:member"`
+	
+	// 已读游标：已读到的最后一条主时间线消息序号
+	LastReadSeq int64 `json:"last_read_seq" gorm:"default:0"`
+	LastReadAt  int64 `json:"last_read_at" gorm:"default:0"`
 }
 
 // Message 消息模型
 type Message struct {
-	MsgID     string `json:"msg_id" gorm:"primaryKey"`
-	RoomID    string `json:"room_id" gorm:"index"`
-	UserID    string `json:"user_id"`
-	Content   string `json:"content"`
-	MsgType   int32  `json:"msg_type" gorm:"default:1"` // 1:文本 2:图片 3:系统
-	CreatedAt int64  `json:"created_at" gorm:"autoCreateTime:milli"`
+	MsgID      string `json:"msg_id" gorm:"primaryKey"`
+	RoomID     string `json:"room_id" gorm:"index;index:idx_room_seq,priority:1"`
+	Seq        int64  `json:"seq" gorm:"default:0;index:idx_room_seq,priority:2"` // 房间内单调递增的序号
+	UserID     string `json:"user_id"`
+	Content    string `json:"content"`
+	MsgType    int32  `json:"msg_type" gorm:"default:1"`             // 1:文本 2:图片 3:系统
+	SenderName string `json:"sender_name" gorm:"size:64;default:''"` // 显示名覆盖（集成消息）
+	EditedAt   int64  `json:"edited_at" gorm:"default:0"`            // 最后一次编辑时间，0 表示未编辑
+	RecalledAt int64  `json:"recalled_at" gorm:"default:0"`          // 撤回/删除时间，非 0 时内容已清空
+	RecalledBy string `json:"recalled_by" gorm:"size:64;default:''"` // 撤回者（作者）或删除者（管理者）
+	CreatedAt  int64  `json:"created_at" gorm:"autoCreateTime:milli"`
+	
+	// 引用与话题
+	ReplyToMsgID string `json:"reply_to_msg_id" gorm:"size:64;default:''"`      // 引用的消息
+	ThreadRootID string `json:"thread_root_id" gorm:"size:64;default:'';index"` // 所属话题的根消息，为空表示在房间主时间线
+	ReplyCount   int32  `json:"reply_count" gorm:"default:0"`                   // 根消息的话题回复数
+	LastReplyAt  int64  `json:"last_reply_at" gorm:"default:0"`                 // 根消息的最后一条回复时间
 	
 	// 关联用户（不存入数据库）
 	User *User `json:"user,omitempty" gorm:"-"`
 }
 
+// MessageRevision 消息被编辑前的内容
+type MessageRevision struct {
+	ID        uint64 `json:"id" gorm:"primaryKey;autoIncrement"`
+	MsgID     string `json:"msg_id" gorm:"index"`
+	Content   string `json:"content"`
+	EditorID  string `json:"editor_id"`
+	CreatedAt int64  `json:"created_at" gorm:"autoCreateTime:milli"` // 被替换的时间
+}
+
+// Reaction 消息表情回应，同一用户对同一消息的同一表情只记一次
+type Reaction struct {
+	ID        uint64 `json:"id" gorm:"primaryKey;autoIncrement"`
+	MsgID     string `json:"msg_id" gorm:"uniqueIndex:idx_reaction,priority:1;size:64"`
+	UserID    string `json:"user_id" gorm:"uniqueIndex:idx_reaction,priority:2;size:64"`
+	Emoji     string `json:"emoji" gorm:"uniqueIndex:idx_reaction,priority:3;size:64"`
+	CreatedAt int64  `json:"created_at" gorm:"autoCreateTime:milli"`
+}
+
+// 消息审计操作
+const (
+	MessageActionRecall = "recall" // 作者撤回
+	MessageActionDelete = "delete" // 管理者删除
+)
+
+// MessageAudit 消息撤回/删除的审计记录，保留原始内容
+type MessageAudit struct {
+	ID         uint64 `json:"id" gorm:"primaryKey;autoIncrement"`
+	MsgID      string `json:"msg_id" gorm:"index"`
+	RoomID     string `json:"room_id" gorm:"index"`
+	AuthorID   string `json:"author_id"`
+	Action     string `json:"action"`
+	OperatorID string `json:"operator_id"`
+	Content    string `json:"content" gorm:"type:text"`
+	CreatedAt  int64  `json:"created_at" gorm:"autoCreateTime:milli"`
+}
+
+// Session 登录会话，保存当前 refresh token 的哈希
+type Session struct {
+	SessionID   string `json:"session_id" gorm:"primaryKey"`
+	UserID      string `json:"user_id" gorm:"index"`
+	RefreshHash string `json:"-" gorm:"not null"`
+	ExpiresAt   int64  `json:"expires_at"`
+	RevokedAt   int64  `json:"revoked_at" gorm:"default:0"`
+	CreatedAt   int64  `json:"created_at" gorm:"autoCreateTime:milli"`
+	UpdatedAt   int64  `json:"updated_at" gorm:"autoUpdateTime:milli"`
+}
+
+// BotToken 机器人 API 令牌，只保存哈希
+type BotToken struct {
+	TokenID   string `json:"token_id" gorm:"primaryKey"`
+	BotID     string `json:"bot_id" gorm:"index"`
+	TokenHash string `json:"-" gorm:"not null"`
+	RevokedAt int64  `json:"revoked_at" gorm:"default:0"`
+	CreatedAt int64  `json:"created_at" gorm:"autoCreateTime:milli"`
+}
+
+// OutgoingWebhook 房间外发 Webhook，事件以 BotID 对应的机器人身份回复
+type OutgoingWebhook struct {
+	WebhookID string `json:"webhook_id" gorm:"primaryKey"`
+	RoomID    string `json:"room_id" gorm:"index"`
+	BotID     string `json:"bot_id"`
+	URL       string `json:"url" gorm:"not null"`
+	Secret    string `json:"-" gorm:"not null"`
+	Events    string `json:"events"` // 逗号分隔：message,join,leave
+	CreatorID string `json:"creator_id"`
+	CreatedAt int64  `json:"created_at" gorm:"autoCreateTime:milli"`
+}
+
+// IncomingWebhook 房间传入 Webhook，外部系统凭令牌向房间发消息
+type IncomingWebhook struct {
+	WebhookID string `json:"webhook_id" gorm:"primaryKey"`
+	RoomID    string `json:"room_id" gorm:"index"`
+	Name      string `json:"name"`
+	BotID     string `json:"bot_id"` // 集成用户，为空时以系统消息发出
+	TokenHash string `json:"-" gorm:"not null"`
+	CreatorID string `json:"creator_id"`
+	CreatedAt int64  `json:"created_at" gorm:"autoCreateTime:milli"`
+}
+
+// WebhookDeadLetter 重试耗尽仍投递失败的 Webhook 事件
+type WebhookDeadLetter struct {
+	ID        uint64 `json:"id" gorm:"primaryKey;autoIncrement"`
+	WebhookID string `json:"webhook_id" gorm:"index"`
+	Event     string `json:"event"`
+	Payload   string `json:"payload" gorm:"type:text"`
+	Attempts  int    `json:"attempts"`
+	LastError string `json:"last_error"`
+	CreatedAt int64  `json:"created_at" gorm:"autoCreateTime:milli"`
+}
+
 // TableName 指定表名
 func (User) TableName() string {
 	return "users"
@@ -56,6 +239,50 @@ func (RoomMember) TableName() string {
 	return "room_members"
 }
 
+func (RoomInvite) TableName() string {
+	return "room_invites"
+}
+
+func (JoinRequest) TableName() string {
+	return "room_join_requests"
+}
+
+func (RoomBan) TableName() string {
+	return "room_bans"
+}
+
 func (Message) TableName() string {
 	return "messages"
 }
+
+func (MessageRevision) TableName() string {
+	return "message_revisions"
+}
+
+func (Reaction) TableName() string {
+	return "message_reactions"
+}
+
+func (MessageAudit) TableName() string {
+	return "message_audits"
+}
+
+func (Session) TableName() string {
+	return "sessions"
+}
+
+func (BotToken) TableName() string {
+	return "bot_tokens"
+}
+
+func (OutgoingWebhook) TableName() string {
+	return "outgoing_webhooks"
+}
+
+func (WebhookDeadLetter) TableName() string {
+	return "webhook_dead_letters"
+}
+
+func (IncomingWebhook) TableName() string {
+	return "incoming_webhooks"
+}
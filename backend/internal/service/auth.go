@@ -0,0 +1,254 @@
+package service
+
+import (
+	"crypto/subtle"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/baijianruoli/bot_chat/backend/internal/conf"
+	"github.com/baijianruoli/bot_chat/backend/internal/dao"
+	"github.com/baijianruoli/bot_chat/backend/internal/model"
+	"github.com/baijianruoli/bot_chat/backend/internal/utils"
+)
+
+var (
+	ErrSessionRevoked = errors.New("session revoked")
+	ErrRefreshReused  = errors.New("refresh token reused")
+)
+
+// botTokenPrefix 机器人 API 令牌前缀，格式 bot_<token_id>.<secret>
+const botTokenPrefix = "bot_"
+
+// TokenPair 登录或刷新后下发的令牌
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresAt    int64 // access token 过期时间（毫秒）
+}
+
+// SessionStore 会话存储，默认为 dao.SessionDAO
+type SessionStore interface {
+	Create(session *model.Session) error
+	GetByID(sessionID string) (*model.Session, error)
+	Rotate(sessionID, oldHash, newHash string, expiresAt int64) (bool, error)
+	Revoke(sessionID string) error
+}
+
+// TokenService 负责签发、校验、刷新和注销令牌
+type TokenService struct {
+	secret     []byte
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+	sessions   SessionStore // 为空时使用数据库，测试时可替换
+}
+
+// NewTokenService 创建 TokenService，未配置密钥时使用随机密钥
+func NewTokenService(cfg conf.AuthConfig) *TokenService {
+	secret := cfg.TokenSecret
+	if secret == "" {
+		secret = utils.RandomToken(32)
+	}
+	return &TokenService{
+		secret:     []byte(secret),
+		accessTTL:  cfg.AccessTokenTTL,
+		refreshTTL: cfg.RefreshTokenTTL,
+	}
+}
+
+// Issue 为用户创建新会话并签发令牌
+func (t *TokenService) Issue(userID string) (*TokenPair, error) {
+	sessionID := utils.GenerateUUID()
+	secret := utils.RandomToken(32)
+
+	session := &model.Session{
+		SessionID:   sessionID,
+		UserID:      userID,
+		RefreshHash: utils.SHA256(secret),
+		ExpiresAt:   time.Now().Add(t.refreshTTL).UnixMilli(),
+	}
+	if err := t.sessionStore().Create(session); err != nil {
+		return nil, err
+	}
+
+	return t.sign(userID, sessionID, secret)
+}
+
+// Verify 校验 access token 或机器人 API 令牌，返回其载荷
+func (t *TokenService) Verify(token string) (*utils.TokenClaims, error) {
+	if strings.HasPrefix(token, botTokenPrefix) {
+		return t.verifyBotToken(token)
+	}
+
+	claims, err := utils.ParseToken(token, t.secret)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Type != utils.TokenTypeAccess {
+		return nil, utils.ErrTokenInvalid
+	}
+
+	session, err := t.sessionStore().GetByID(claims.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil || session.RevokedAt != 0 || session.UserID != claims.UserID {
+		return nil, ErrSessionRevoked
+	}
+
+	return claims, nil
+}
+
+// Refresh 使用 refresh token 换取新令牌，旧 refresh token 随即失效。
+// 已轮换过的 refresh token 再次出现时视为泄露，整个会话被注销。
+func (t *TokenService) Refresh(refreshToken string) (*TokenPair, string, error) {
+	claims, err := utils.ParseToken(refreshToken, t.secret)
+	if err != nil {
+		return nil, "", err
+	}
+	if claims.Type != utils.TokenTypeRefresh {
+		return nil, "", utils.ErrTokenInvalid
+	}
+
+	sessionID, secret, ok := splitRefreshSubject(claims.SessionID)
+	if !ok {
+		return nil, "", utils.ErrTokenInvalid
+	}
+
+	sessionDAO := t.sessionStore()
+	session, err := sessionDAO.GetByID(sessionID)
+	if err != nil {
+		return nil, "", err
+	}
+	if session == nil || session.RevokedAt != 0 || session.UserID != claims.UserID {
+		return nil, "", ErrSessionRevoked
+	}
+	if session.ExpiresAt <= utils.GetCurrentTimestamp() {
+		return nil, "", utils.ErrTokenExpired
+	}
+
+	newSecret := utils.RandomToken(32)
+	rotated, err := sessionDAO.Rotate(sessionID, utils.SHA256(secret), utils.SHA256(newSecret),
+		time.Now().Add(t.refreshTTL).UnixMilli())
+	if err != nil {
+		return nil, "", err
+	}
+	if !rotated {
+		sessionDAO.Revoke(sessionID)
+		return nil, "", ErrRefreshReused
+	}
+
+	pair, err := t.sign(session.UserID, sessionID, newSecret)
+	return pair, session.UserID, err
+}
+
+// Revoke 注销会话，该会话签发的所有令牌立即失效
+func (t *TokenService) Revoke(sessionID string) error {
+	return t.sessionStore().Revoke(sessionID)
+}
+
+// sessionStore 返回会话存储
+func (t *TokenService) sessionStore() SessionStore {
+	if t.sessions != nil {
+		return t.sessions
+	}
+	return dao.NewSessionDAO(dao.DB)
+}
+
+// IssueBotToken 为机器人签发长期有效的 API 令牌，明文只返回一次
+func (t *TokenService) IssueBotToken(botID string) (string, error) {
+	tokenID := utils.GenerateUUID()[:8]
+	secret := utils.RandomToken(24)
+
+	err := dao.NewBotTokenDAO(dao.DB).Create(&model.BotToken{
+		TokenID:   tokenID,
+		BotID:     botID,
+		TokenHash: utils.SHA256(secret),
+	})
+	if err != nil {
+		return "", err
+	}
+	return botTokenPrefix + tokenID + "." + secret, nil
+}
+
+// RevokeBotToken 吊销机器人 API 令牌
+func (t *TokenService) RevokeBotToken(tokenID string) error {
+	return dao.NewBotTokenDAO(dao.DB).Revoke(tokenID)
+}
+
+// verifyBotToken 校验机器人 API 令牌
+func (t *TokenService) verifyBotToken(token string) (*utils.TokenClaims, error) {
+	tokenID, secret, ok := strings.Cut(strings.TrimPrefix(token, botTokenPrefix), ".")
+	if !ok || tokenID == "" || secret == "" {
+		return nil, utils.ErrTokenInvalid
+	}
+
+	botToken, err := dao.NewBotTokenDAO(dao.DB).GetByID(tokenID)
+	if err != nil {
+		return nil, err
+	}
+	if botToken == nil || botToken.RevokedAt != 0 {
+		return nil, ErrSessionRevoked
+	}
+	if subtle.ConstantTimeCompare([]byte(botToken.TokenHash), []byte(utils.SHA256(secret))) != 1 {
+		return nil, utils.ErrTokenInvalid
+	}
+
+	return &utils.TokenClaims{
+		UserID:    botToken.BotID,
+		SessionID: botToken.TokenID,
+		Type:      utils.TokenTypeBot,
+		IssuedAt:  botToken.CreatedAt / 1000,
+	}, nil
+}
+
+// sign 签发 access token 和 refresh token
+func (t *TokenService) sign(userID, sessionID, secret string) (*TokenPair, error) {
+	now := time.Now()
+	accessExp := now.Add(t.accessTTL)
+
+	access, err := utils.SignToken(&utils.TokenClaims{
+		UserID:    userID,
+		SessionID: sessionID,
+		Type:      utils.TokenTypeAccess,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: accessExp.Unix(),
+	}, t.secret)
+	if err != nil {
+		return nil, err
+	}
+
+	// refresh token 的 sid 额外携带一次性随机串，用于检测重放
+	refresh, err := utils.SignToken(&utils.TokenClaims{
+		UserID:    userID,
+		SessionID: sessionID + ":" + secret,
+		Type:      utils.TokenTypeRefresh,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(t.refreshTTL).Unix(),
+	}, t.secret)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		ExpiresAt:    accessExp.UnixMilli(),
+	}, nil
+}
+
+func splitRefreshSubject(sid string) (string, string, bool) {
+	idx := strings.LastIndex(sid, ":")
+	if idx <= 0 || idx == len(sid)-1 {
+		return "", "", false
+	}
+	return sid[:idx], sid[idx+1:], true
+}
+
+// BearerToken 从 Authorization 头中提取令牌
+func BearerToken(header string) string {
+	if len(header) > 7 && strings.EqualFold(header[:7], "Bearer ") {
+		return strings.TrimSpace(header[7:])
+	}
+	return ""
+}
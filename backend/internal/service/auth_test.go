@@ -0,0 +1,162 @@
+package service
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/baijianruoli/bot_chat/backend/internal/conf"
+	"github.com/baijianruoli/bot_chat/backend/internal/model"
+	"github.com/baijianruoli/bot_chat/backend/internal/utils"
+)
+
+// memorySessionStore 内存会话存储
+type memorySessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]*model.Session
+}
+
+func newMemorySessionStore() *memorySessionStore {
+	return &memorySessionStore{sessions: make(map[string]*model.Session)}
+}
+
+func (s *memorySessionStore) Create(session *model.Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	copied := *session
+	s.sessions[session.SessionID] = &copied
+	return nil
+}
+
+func (s *memorySessionStore) GetByID(sessionID string) (*model.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[sessionID]
+	if !ok {
+		return nil, nil
+	}
+	copied := *session
+	return &copied, nil
+}
+
+func (s *memorySessionStore) Rotate(sessionID, oldHash, newHash string, expiresAt int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[sessionID]
+	if !ok || session.RefreshHash != oldHash || session.RevokedAt != 0 {
+		return false, nil
+	}
+	session.RefreshHash = newHash
+	session.ExpiresAt = expiresAt
+	return true, nil
+}
+
+func (s *memorySessionStore) Revoke(sessionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if session, ok := s.sessions[sessionID]; ok && session.RevokedAt == 0 {
+		session.RevokedAt = utils.GetCurrentTimestamp()
+	}
+	return nil
+}
+
+func newTestTokenService() *TokenService {
+	tokens := NewTokenService(conf.AuthConfig{
+		TokenSecret:     "test-secret",
+		AccessTokenTTL:  time.Minute,
+		RefreshTokenTTL: time.Hour,
+	})
+	tokens.sessions = newMemorySessionStore()
+	return tokens
+}
+
+func TestTokenServiceIssueAndVerify(t *testing.T) {
+	tokens := newTestTokenService()
+
+	pair, err := tokens.Issue("u_1")
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+
+	claims, err := tokens.Verify(pair.AccessToken)
+	if err != nil {
+		t.Fatalf("Verify access token: %v", err)
+	}
+	if claims.UserID != "u_1" || claims.Type != utils.TokenTypeAccess {
+		t.Fatalf("unexpected claims: %+v", claims)
+	}
+
+	// refresh token 不能当作 access token 使用
+	if _, err := tokens.Verify(pair.RefreshToken); err != utils.ErrTokenInvalid {
+		t.Fatalf("Verify refresh token as access: err = %v, want %v", err, utils.ErrTokenInvalid)
+	}
+
+	// 其他密钥签发的令牌无效
+	other := newTestTokenService()
+	other.secret = []byte("other-secret")
+	if _, err := other.Verify(pair.AccessToken); err != utils.ErrTokenInvalid {
+		t.Fatalf("Verify with other secret: err = %v, want %v", err, utils.ErrTokenInvalid)
+	}
+
+	if err := tokens.Revoke(claims.SessionID); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	if _, err := tokens.Verify(pair.AccessToken); err != ErrSessionRevoked {
+		t.Fatalf("Verify after revoke: err = %v, want %v", err, ErrSessionRevoked)
+	}
+}
+
+func TestTokenServiceRefreshRotation(t *testing.T) {
+	tokens := newTestTokenService()
+
+	first, err := tokens.Issue("u_1")
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+
+	second, userID, err := tokens.Refresh(first.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if userID != "u_1" {
+		t.Fatalf("Refresh user = %q, want u_1", userID)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Fatal("refresh token was not rotated")
+	}
+	if _, err := tokens.Verify(second.AccessToken); err != nil {
+		t.Fatalf("Verify rotated access token: %v", err)
+	}
+
+	third, _, err := tokens.Refresh(second.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh rotated token: %v", err)
+	}
+
+	// 已轮换过的 refresh token 再次出现视为泄露，整个会话被注销
+	if _, _, err := tokens.Refresh(first.RefreshToken); err != ErrRefreshReused {
+		t.Fatalf("Refresh reused token: err = %v, want %v", err, ErrRefreshReused)
+	}
+	if _, err := tokens.Verify(third.AccessToken); err != ErrSessionRevoked {
+		t.Fatalf("Verify after reuse: err = %v, want %v", err, ErrSessionRevoked)
+	}
+	if _, _, err := tokens.Refresh(third.RefreshToken); err != ErrSessionRevoked {
+		t.Fatalf("Refresh after reuse: err = %v, want %v", err, ErrSessionRevoked)
+	}
+}
+
+func TestTokenServiceRefreshRejectsAccessToken(t *testing.T) {
+	tokens := newTestTokenService()
+
+	pair, err := tokens.Issue("u_1")
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	if _, _, err := tokens.Refresh(pair.AccessToken); err != utils.ErrTokenInvalid {
+		t.Fatalf("Refresh with access token: err = %v, want %v", err, utils.ErrTokenInvalid)
+	}
+}
@@ -0,0 +1,401 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/baijianruoli/bot_chat/backend/internal/dao"
+	"github.com/baijianruoli/bot_chat/backend/internal/model"
+	"github.com/baijianruoli/bot_chat/backend/internal/utils"
+	chat "github.com/baijianruoli/bot_chat/backend/kitex_gen/chat"
+)
+
+// maxBotsPerOwner 每个用户最多创建的机器人数量
+const maxBotsPerOwner = 20
+
+// CreateBot 创建机器人账号并签发 API 令牌，机器人不能再创建机器人
+func (s *ChatServiceImpl) CreateBot(ctx context.Context, req *chat.CreateBotReq) (*chat.CreateBotResp, error) {
+	claims, ok := ClaimsFromContext(ctx)
+	if !ok || claims.Type == utils.TokenTypeBot {
+		return &chat.CreateBotResp{
+			Code:    utils.CodeUnauthorized,
+			Message: "unauthorized",
+		}, nil
+	}
+	ownerID := claims.UserID
+	if req.Username == "" {
+		return &chat.CreateBotResp{
+			Code:    utils.CodeParamError,
+			Message: "username required",
+		}, nil
+	}
+
+	userDAO := dao.NewUserDAO(dao.DB)
+	botCount, err := userDAO.CountBotsByOwner(ownerID)
+	if err != nil {
+		return &chat.CreateBotResp{
+			Code:    utils.CodeServerError,
+			Message: "database error",
+		}, nil
+	}
+	if botCount >= maxBotsPerOwner {
+		return &chat.CreateBotResp{
+			Code:    utils.CodeParamError,
+			Message: fmt.Sprintf("at most %d bots per user", maxBotsPerOwner),
+		}, nil
+	}
+
+	existingUser, err := userDAO.GetByUsername(req.Username)
+	if err != nil {
+		return &chat.CreateBotResp{
+			Code:    utils.CodeServerError,
+			Message: "database error",
+		}, nil
+	}
+	if existingUser != nil {
+		return &chat.CreateBotResp{
+			Code:    utils.CodeUserExists,
+			Message: "username already exists",
+		}, nil
+	}
+
+	bot := &model.User{
+		UserID:   utils.GenerateUserID(),
+		Username: req.Username,
+		Nickname: req.Nickname,
+		Avatar:   req.Avatar,
+		UserType: model.UserTypeBot,
+		OwnerID:  ownerID,
+	}
+	if err := userDAO.Create(bot); err != nil {
+		return &chat.CreateBotResp{
+			Code:    utils.CodeServerError,
+			Message: "failed to create bot",
+		}, nil
+	}
+
+	apiToken, err := s.tokens.IssueBotToken(bot.UserID)
+	if err != nil {
+		return &chat.CreateBotResp{
+			Code:    utils.CodeServerError,
+			Message: "failed to issue token",
+		}, nil
+	}
+
+	return &chat.CreateBotResp{
+		Code:    utils.CodeSuccess,
+		Message: "success",
+		Bot: &chat.UserInfo{
+			UserId:    bot.UserID,
+			Username:  bot.Username,
+			Nickname:  bot.Nickname,
+			Avatar:    bot.Avatar,
+			IsBot:     true,
+			CreatedAt: bot.CreatedAt,
+		},
+		ApiToken: apiToken,
+	}, nil
+}
+
+// Bot 进程内机器人，回调在该机器人独占的 goroutine 中按序执行
+type Bot interface {
+	// OnMessage 所在房间收到新消息（不包括机器人自己发送的消息）
+	OnMessage(ctx context.Context, bc *BotContext, msg *chat.MessageInfo)
+	// OnJoin 有用户加入所在房间
+	OnJoin(ctx context.Context, bc *BotContext, roomID, userID string)
+	// OnLeave 有用户离开所在房间
+	OnLeave(ctx context.Context, bc *BotContext, roomID, userID string)
+	// OnCommand 所在房间有人执行了该机器人注册的命令
+	OnCommand(ctx context.Context, bc *BotContext, cmd *BotCommand)
+}
+
+// CommandProvider 机器人可选实现，声明要注册的斜杠命令。
+// 返回的 Command 无需设置 Handler，命令由宿主转交给 OnCommand。
+type CommandProvider interface {
+	Commands() []*Command
+}
+
+// BaseBot 提供空实现，嵌入后只需覆盖关心的回调
+type BaseBot struct{}
+
+func (BaseBot) OnMessage(ctx context.Context, bc *BotContext, msg *chat.MessageInfo) {}
+func (BaseBot) OnJoin(ctx context.Context, bc *BotContext, roomID, userID string)    {}
+func (BaseBot) OnLeave(ctx context.Context, bc *BotContext, roomID, userID string)   {}
+func (BaseBot) OnCommand(ctx context.Context, bc *BotContext, cmd *BotCommand)       {}
+
+// BotCommand 机器人收到的命令
+type BotCommand struct {
+	RoomID string
+	UserID string
+	Name   string   // 不含前导 /
+	Args   []string // 解析后的参数，支持双引号
+	Raw    string   // 命令名之后的原始文本
+}
+
+// BotContext 机器人以自身身份调用服务的入口
+type BotContext struct {
+	BotID string
+	host  *BotHost
+}
+
+// Context 返回携带机器人身份的 context
+func (bc *BotContext) Context(parent context.Context) context.Context {
+	return WithClaims(parent, &utils.TokenClaims{
+		UserID: bc.BotID,
+		Type:   utils.TokenTypeBot,
+	})
+}
+
+// Reply 以机器人身份向房间发送消息，与 SendMessageWithWS 走同一路径
+func (bc *BotContext) Reply(ctx context.Context, roomID, content string) (*chat.MessageInfo, error) {
+	resp, err := bc.host.svc.SendMessageWithWS(bc.Context(ctx), &chat.SendMessageReq{
+		RoomId:  roomID,
+		Content: content,
+		MsgType: 1,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Code != utils.CodeSuccess {
+		return nil, fmt.Errorf("send message: code=%d, %s", resp.Code, resp.Message)
+	}
+	return resp.Msg, nil
+}
+
+// JoinRoom 机器人加入房间
+func (bc *BotContext) JoinRoom(ctx context.Context, roomID string) error {
+	resp, err := bc.host.svc.JoinRoomWithWS(bc.Context(ctx), &chat.JoinRoomReq{RoomId: roomID}, nil)
+	if err != nil {
+		return err
+	}
+	if resp.Code != utils.CodeSuccess && resp.Code != utils.CodeAlreadyInRoom {
+		return fmt.Errorf("join room: code=%d, %s", resp.Code, resp.Message)
+	}
+	bc.host.setMembership(bc.BotID, roomID, true)
+	return nil
+}
+
+// LeaveRoom 机器人离开房间
+func (bc *BotContext) LeaveRoom(ctx context.Context, roomID string) error {
+	resp, err := bc.host.svc.LeaveRoomWithWS(bc.Context(ctx), &chat.LeaveRoomReq{RoomId: roomID})
+	if err != nil {
+		return err
+	}
+	if resp.Code != utils.CodeSuccess && resp.Code != utils.CodeNotInRoom {
+		return fmt.Errorf("leave room: code=%d, %s", resp.Code, resp.Message)
+	}
+	bc.host.setMembership(bc.BotID, roomID, false)
+	return nil
+}
+
+// hostedBot 已注册的机器人及其所在房间
+type hostedBot struct {
+	bot   Bot
+	bc    *BotContext
+	rooms map[string]bool
+	queue chan func(ctx context.Context)
+}
+
+// BotHost 进程内机器人宿主，通过 WSManager 的房间广播接收事件
+type BotHost struct {
+	svc    *ChatServiceImpl
+	events chan *WSMessage
+	mu     sync.RWMutex
+	bots   map[string]*hostedBot // botID -> bot
+}
+
+// NewBotHost 创建机器人宿主
+func NewBotHost(svc *ChatServiceImpl) *BotHost {
+	return &BotHost{
+		svc:    svc,
+		events: make(chan *WSMessage, 256),
+		bots:   make(map[string]*hostedBot),
+	}
+}
+
+// Register 将机器人实现绑定到机器人账号，并载入其已加入的房间
+func (h *BotHost) Register(botID string, bot Bot) error {
+	user, err := dao.NewUserDAO(dao.DB).GetByID(botID)
+	if err != nil {
+		return err
+	}
+	if user == nil || !user.IsBot() {
+		return fmt.Errorf("user %s is not a bot account", botID)
+	}
+
+	roomIDs, err := dao.NewRoomMemberDAO(dao.DB).GetRoomsByUser(botID)
+	if err != nil {
+		return err
+	}
+
+	hb := &hostedBot{
+		bot:   bot,
+		bc:    &BotContext{BotID: botID, host: h},
+		rooms: make(map[string]bool, len(roomIDs)),
+		queue: make(chan func(ctx context.Context), 64),
+	}
+	for _, roomID := range roomIDs {
+		hb.rooms[roomID] = true
+	}
+
+	h.mu.Lock()
+	if _, ok := h.bots[botID]; ok {
+		h.mu.Unlock()
+		return fmt.Errorf("bot %s already registered", botID)
+	}
+	h.bots[botID] = hb
+	h.mu.Unlock()
+
+	if provider, ok := bot.(CommandProvider); ok {
+		if err := h.registerCommands(hb, provider.Commands()); err != nil {
+			h.mu.Lock()
+			delete(h.bots, botID)
+			h.mu.Unlock()
+			return err
+		}
+	}
+
+	go hb.loop()
+	log.Printf("Bot registered: bot=%s, rooms=%d", botID, len(roomIDs))
+	return nil
+}
+
+// Run 订阅房间广播并分发给机器人
+func (h *BotHost) Run() {
+	GlobalWSManager.Subscribe(h.events)
+
+	for message := range h.events {
+		h.dispatch(message)
+	}
+}
+
+// dispatch 将一条房间事件投递给该房间内的机器人
+func (h *BotHost) dispatch(message *WSMessage) {
+	switch message.Type {
+	case "join", "leave":
+		userID := eventUserID(message)
+		if userID == "" {
+			return
+		}
+		h.setMembership(userID, message.RoomID, message.Type == "join")
+		h.each(message.RoomID, userID, func(hb *hostedBot) {
+			hb.enqueue(func(ctx context.Context) {
+				if message.Type == "join" {
+					hb.bot.OnJoin(ctx, hb.bc, message.RoomID, userID)
+				} else {
+					hb.bot.OnLeave(ctx, hb.bc, message.RoomID, userID)
+				}
+			})
+		})
+
+	case "message":
+		msg, ok := message.Data.(*chat.MessageInfo)
+		if !ok || msg.Sender == nil {
+			return
+		}
+		h.each(message.RoomID, msg.Sender.UserId, func(hb *hostedBot) {
+			hb.enqueue(func(ctx context.Context) {
+				hb.bot.OnMessage(ctx, hb.bc, msg)
+			})
+		})
+	}
+}
+
+// each 遍历房间内除 senderID 外的机器人
+func (h *BotHost) each(roomID, senderID string, fn func(hb *hostedBot)) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for botID, hb := range h.bots {
+		if botID != senderID && hb.rooms[roomID] {
+			fn(hb)
+		}
+	}
+}
+
+// setMembership 维护机器人所在房间
+func (h *BotHost) setMembership(botID, roomID string, joined bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if hb, ok := h.bots[botID]; ok {
+		if joined {
+			hb.rooms[roomID] = true
+		} else {
+			delete(hb.rooms, roomID)
+		}
+	}
+}
+
+// enqueue 投递回调，机器人处理过慢时丢弃
+func (hb *hostedBot) enqueue(fn func(ctx context.Context)) {
+	select {
+	case hb.queue <- fn:
+	default:
+		log.Printf("Bot queue full, event dropped: bot=%s", hb.bc.BotID)
+	}
+}
+
+// loop 顺序执行机器人回调，单个回调 panic 不影响后续事件
+func (hb *hostedBot) loop() {
+	for fn := range hb.queue {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Printf("Bot panic: bot=%s, err=%v", hb.bc.BotID, r)
+				}
+			}()
+			fn(context.Background())
+		}()
+	}
+}
+
+// eventUserID 读取 join/leave 事件中的用户ID
+func eventUserID(message *WSMessage) string {
+	if data, ok := message.Data.(map[string]interface{}); ok {
+		if userID, ok := data["user_id"].(string); ok {
+			return userID
+		}
+	}
+	return message.UserID
+}
+
+// registerCommands 将机器人声明的命令注册到服务的命令注册表，
+// 命令只在机器人所在的房间内可用
+func (h *BotHost) registerCommands(hb *hostedBot, cmds []*Command) error {
+	var registered []string
+	for _, c := range cmds {
+		cmd := *c
+		cmd.Handler = func(cc *CommandContext) (*CommandResult, error) {
+			h.mu.RLock()
+			inRoom := hb.rooms[cc.Room.RoomID]
+			h.mu.RUnlock()
+			if !inRoom {
+				return &CommandResult{Notice: "/" + cc.Name + " is not available in this room"}, nil
+			}
+
+			botCmd := &BotCommand{
+				RoomID: cc.Room.RoomID,
+				UserID: cc.UserID,
+				Name:   cc.Name,
+				Args:   cc.Args,
+				Raw:    cc.Raw,
+			}
+			hb.enqueue(func(ctx context.Context) {
+				hb.bot.OnCommand(ctx, hb.bc, botCmd)
+			})
+			return nil, nil
+		}
+
+		if err := h.svc.commands.Register(&cmd); err != nil {
+			for _, name := range registered {
+				h.svc.commands.Unregister(name)
+			}
+			return err
+		}
+		registered = append(registered, cmd.Name)
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/baijianruoli/bot_chat/backend/internal/utils"
+	chat "github.com/baijianruoli/bot_chat/backend/kitex_gen/chat"
+)
+
+func TestCreateBotRejectsBotToken(t *testing.T) {
+	s := &ChatServiceImpl{}
+	tests := []struct {
+		name string
+		ctx  context.Context
+	}{
+		{"anonymous", context.Background()},
+		{"bot token", WithClaims(context.Background(), &utils.TokenClaims{UserID: "u_bot", Type: utils.TokenTypeBot})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := s.CreateBot(tt.ctx, &chat.CreateBotReq{Username: "child"})
+			if err != nil {
+				t.Fatalf("CreateBot: %v", err)
+			}
+			if resp.Code != utils.CodeUnauthorized {
+				t.Fatalf("CreateBot code = %d, want %d", resp.Code, utils.CodeUnauthorized)
+			}
+		})
+	}
+}
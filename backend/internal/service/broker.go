@@ -0,0 +1,198 @@
+package service
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// BrokerEnvelope 节点间转发的房间消息
+type BrokerEnvelope struct {
+	Node    string     `json:"node"` // 发布消息的节点ID，节点会忽略自己发布的消息
+	Message *WSMessage `json:"message"`
+	To      []string   `json:"to,omitempty"`     // 即 Message.To，帧本身不下发收件人
+	Except  string     `json:"except,omitempty"` // 即 Message.Except
+}
+
+// Broker WSManager 的跨节点消息总线
+type Broker interface {
+	// Publish 向所有节点发布房间消息
+	Publish(ctx context.Context, env *BrokerEnvelope) error
+	// Subscribe 接收所有节点发布的消息，阻塞直到 ctx 结束或连接断开
+	Subscribe(ctx context.Context, handler func(env *BrokerEnvelope)) error
+	// SetRoomUsers 上报本节点某房间的在线用户，userIDs 为空表示清除
+	SetRoomUsers(ctx context.Context, node, roomID string, userIDs []string) error
+	// RoomUsers 返回房间在所有节点的在线用户（已去重）
+	RoomUsers(ctx context.Context, roomID string) ([]string, error)
+	// Close 释放资源
+	Close() error
+}
+
+// MemoryBroker 进程内消息总线，单节点部署时使用；多个 WSManager 共用同一实例即可模拟多节点
+type MemoryBroker struct {
+	mu       sync.RWMutex
+	handlers map[int]func(env *BrokerEnvelope)
+	nextID   int
+	online   map[string]map[string][]string // roomID -> node -> userIDs
+}
+
+// NewMemoryBroker 创建进程内消息总线
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{
+		handlers: make(map[int]func(env *BrokerEnvelope)),
+		online:   make(map[string]map[string][]string),
+	}
+}
+
+// Publish 同步投递给所有订阅者
+func (b *MemoryBroker) Publish(ctx context.Context, env *BrokerEnvelope) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, handler := range b.handlers {
+		handler(env)
+	}
+	return nil
+}
+
+// Subscribe 注册订阅者，ctx 结束时注销
+func (b *MemoryBroker) Subscribe(ctx context.Context, handler func(env *BrokerEnvelope)) error {
+	b.mu.Lock()
+	id := b.nextID
+	b.nextID++
+	b.handlers[id] = handler
+	b.mu.Unlock()
+
+	<-ctx.Done()
+
+	b.mu.Lock()
+	delete(b.handlers, id)
+	b.mu.Unlock()
+	return ctx.Err()
+}
+
+// SetRoomUsers 记录节点的房间在线用户
+func (b *MemoryBroker) SetRoomUsers(ctx context.Context, node, roomID string, userIDs []string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(userIDs) == 0 {
+		delete(b.online[roomID], node)
+		if len(b.online[roomID]) == 0 {
+			delete(b.online, roomID)
+		}
+		return nil
+	}
+	if b.online[roomID] == nil {
+		b.online[roomID] = make(map[string][]string)
+	}
+	b.online[roomID][node] = append([]string(nil), userIDs...)
+	return nil
+}
+
+// RoomUsers 合并所有节点的房间在线用户
+func (b *MemoryBroker) RoomUsers(ctx context.Context, roomID string) ([]string, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	var users []string
+	seen := make(map[string]bool)
+	for _, userIDs := range b.online[roomID] {
+		for _, userID := range userIDs {
+			if !seen[userID] {
+				seen[userID] = true
+				users = append(users, userID)
+			}
+		}
+	}
+	return users, nil
+}
+
+// Close 无需释放资源
+func (b *MemoryBroker) Close() error {
+	return nil
+}
+
+// currentBroker 当前使用的消息总线
+func (m *WSManager) currentBroker() Broker {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.broker
+}
+
+// markDirty 标记房间在线用户有变化，调用方需持有锁
+func (m *WSManager) markDirty(roomID string) {
+	select {
+	case m.dirty <- roomID:
+	default:
+		// 队列满时由定时同步补齐
+	}
+}
+
+// receiveRemote 接收其他节点发布的消息并投递给本节点的连接，断开后自动重连
+func (m *WSManager) receiveRemote() {
+	broker := m.currentBroker()
+	for {
+		err := broker.Subscribe(context.Background(), func(env *BrokerEnvelope) {
+			if env.Node == m.nodeID {
+				return
+			}
+			env.Message.remote = true
+			env.Message.To = env.To
+			env.Message.Except = env.Except
+			m.broadcast <- env.Message
+		})
+		log.Printf("Broker subscription ended, retrying: %v", err)
+		time.Sleep(time.Second)
+	}
+}
+
+// publishLocal 将本节点产生的消息发布到消息总线
+func (m *WSManager) publishLocal() {
+	broker := m.currentBroker()
+	for message := range m.outbox {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		if err := broker.Publish(ctx, &BrokerEnvelope{Node: m.nodeID, Message: message, To: message.To, Except: message.Except}); err != nil {
+			log.Printf("Failed to publish message: room=%s, type=%s, err=%v", message.RoomID, message.Type, err)
+		}
+		cancel()
+	}
+}
+
+// syncOnline 上报本节点的房间在线用户：变化时立即上报，并定期刷新以免过期
+func (m *WSManager) syncOnline() {
+	cfg, clock := m.config()
+	ticker := clock.NewTicker(cfg.OnlineSync)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case roomID := <-m.dirty:
+			m.syncRoom(roomID)
+
+		case <-ticker.C():
+			m.mu.RLock()
+			roomIDs := make([]string, 0, len(m.rooms))
+			for roomID := range m.rooms {
+				roomIDs = append(roomIDs, roomID)
+			}
+			m.mu.RUnlock()
+
+			for _, roomID := range roomIDs {
+				m.syncRoom(roomID)
+			}
+		}
+	}
+}
+
+// syncRoom 上报本节点单个房间的在线用户
+func (m *WSManager) syncRoom(roomID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	if err := m.currentBroker().SetRoomUsers(ctx, m.nodeID, roomID, m.localUsers(roomID)); err != nil {
+		log.Printf("Failed to sync online users: room=%s, err=%v", roomID, err)
+	}
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/baijianruoli/bot_chat/backend/internal/conf"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	redisBrokerChannel = "bot_chat:ws:broadcast"
+	redisOnlinePrefix  = "bot_chat:ws:online:" // + roomID + ":" + node -> set(userID)
+	redisNodesPrefix   = "bot_chat:ws:nodes:"  // + roomID -> set(node)
+)
+
+// RedisBroker 基于 Redis pub/sub 的消息总线，多副本部署时使用。
+// 每个节点的房间在线用户保存在带过期时间的集合中，节点宕机后自动失效
+type RedisBroker struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+// NewRedisBroker 连接 Redis 并创建消息总线，ttl 为在线用户快照的有效期
+func NewRedisBroker(cfg conf.RedisConfig, ttl time.Duration) (*RedisBroker, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)),
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	return &RedisBroker{
+		client: client,
+		ttl:    ttl,
+	}, nil
+}
+
+// Publish 发布到广播频道
+func (b *RedisBroker) Publish(ctx context.Context, env *BrokerEnvelope) error {
+	data, err := json.Marshal(env)
+	if err != nil {
+		return err
+	}
+	return b.client.Publish(ctx, redisBrokerChannel, data).Err()
+}
+
+// Subscribe 订阅广播频道
+func (b *RedisBroker) Subscribe(ctx context.Context, handler func(env *BrokerEnvelope)) error {
+	sub := b.client.Subscribe(ctx, redisBrokerChannel)
+	defer sub.Close()
+
+	// 等待订阅确认，避免启动初期的消息丢失
+	if _, err := sub.Receive(ctx); err != nil {
+		return err
+	}
+
+	ch := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+
+		case msg, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			var env BrokerEnvelope
+			if err := json.Unmarshal([]byte(msg.Payload), &env); err != nil || env.Message == nil {
+				log.Printf("Invalid broker message: %v", err)
+				continue
+			}
+			handler(&env)
+		}
+	}
+}
+
+// SetRoomUsers 覆盖节点的房间在线用户并刷新过期时间
+func (b *RedisBroker) SetRoomUsers(ctx context.Context, node, roomID string, userIDs []string) error {
+	key := redisOnlinePrefix + roomID + ":" + node
+	nodesKey := redisNodesPrefix + roomID
+
+	pipe := b.client.TxPipeline()
+	pipe.Del(ctx, key)
+	if len(userIDs) == 0 {
+		pipe.SRem(ctx, nodesKey, node)
+	} else {
+		members := make([]interface{}, len(userIDs))
+		for i, userID := range userIDs {
+			members[i] = userID
+		}
+		pipe.SAdd(ctx, key, members...)
+		pipe.Expire(ctx, key, b.ttl)
+		pipe.SAdd(ctx, nodesKey, node)
+		pipe.Expire(ctx, nodesKey, b.ttl)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// RoomUsers 合并房间在各节点的在线用户，已过期的节点快照自动忽略
+func (b *RedisBroker) RoomUsers(ctx context.Context, roomID string) ([]string, error) {
+	nodes, err := b.client.SMembers(ctx, redisNodesPrefix+roomID).Result()
+	if err != nil || len(nodes) == 0 {
+		return nil, err
+	}
+
+	keys := make([]string, len(nodes))
+	for i, node := range nodes {
+		keys[i] = redisOnlinePrefix + roomID + ":" + node
+	}
+	return b.client.SUnion(ctx, keys...).Result()
+}
+
+// Close 关闭 Redis 连接
+func (b *RedisBroker) Close() error {
+	return b.client.Close()
+}
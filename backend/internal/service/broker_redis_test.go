@@ -0,0 +1,458 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/baijianruoli/bot_chat/backend/internal/conf"
+	"github.com/baijianruoli/bot_chat/backend/internal/utils"
+	"github.com/gorilla/websocket"
+)
+
+// fakeRedis 实现 RedisBroker 用到的 RESP2 命令子集：pub/sub、集合、过期与 MULTI/EXEC，过期时间按 now 判断
+type fakeRedis struct {
+	ln  net.Listener
+	now func() time.Time
+
+	mu      sync.Mutex
+	sets    map[string]map[string]bool
+	expires map[string]time.Time
+	subs    map[*fakeRedisConn]map[string]bool // 连接 -> 订阅的频道
+}
+
+// fakeRedisConn 一个客户端连接，发布的消息可能由其他连接的 goroutine 写入
+type fakeRedisConn struct {
+	conn net.Conn
+	mu   sync.Mutex
+}
+
+func (c *fakeRedisConn) write(reply string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.conn.Write([]byte(reply))
+}
+
+func newFakeRedis(t *testing.T, now func() time.Time) *fakeRedis {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	s := &fakeRedis{
+		ln:      ln,
+		now:     now,
+		sets:    make(map[string]map[string]bool),
+		expires: make(map[string]time.Time),
+		subs:    make(map[*fakeRedisConn]map[string]bool),
+	}
+	go s.serve()
+	t.Cleanup(func() { ln.Close() })
+	return s
+}
+
+func (s *fakeRedis) config() conf.RedisConfig {
+	addr := s.ln.Addr().(*net.TCPAddr)
+	return conf.RedisConfig{Host: addr.IP.String(), Port: addr.Port}
+}
+
+func (s *fakeRedis) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(&fakeRedisConn{conn: conn})
+	}
+}
+
+func (s *fakeRedis) handle(c *fakeRedisConn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.subs, c)
+		s.mu.Unlock()
+		c.conn.Close()
+	}()
+
+	r := bufio.NewReader(c.conn)
+	var queued [][]string
+	inMulti := false
+	for {
+		args, err := readRESPCommand(r)
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(args[0])
+
+		switch {
+		case cmd == "MULTI":
+			inMulti, queued = true, nil
+			c.write("+OK\r\n")
+		case cmd == "EXEC":
+			s.mu.Lock()
+			reply := fmt.Sprintf("*%d\r\n", len(queued))
+			for _, q := range queued {
+				reply += s.exec(c, q)
+			}
+			s.mu.Unlock()
+			inMulti, queued = false, nil
+			c.write(reply)
+		case inMulti:
+			queued = append(queued, args)
+			c.write("+QUEUED\r\n")
+		default:
+			s.mu.Lock()
+			reply := s.exec(c, args)
+			s.mu.Unlock()
+			if reply != "" {
+				c.write(reply)
+			}
+		}
+	}
+}
+
+// exec 执行一条命令并返回回复，调用方需持有锁
+func (s *fakeRedis) exec(c *fakeRedisConn, args []string) string {
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		if len(s.subs[c]) > 0 {
+			return "*2\r\n$4\r\npong\r\n$0\r\n\r\n"
+		}
+		return "+PONG\r\n"
+	case "SUBSCRIBE":
+		if s.subs[c] == nil {
+			s.subs[c] = make(map[string]bool)
+		}
+		reply := ""
+		for _, channel := range args[1:] {
+			s.subs[c][channel] = true
+			reply += "*3\r\n" + respBulk("subscribe") + respBulk(channel) + respInt(len(s.subs[c]))
+		}
+		return reply
+	case "PUBLISH":
+		receivers := 0
+		for sub, channels := range s.subs {
+			if channels[args[1]] {
+				receivers++
+				sub.write("*3\r\n" + respBulk("message") + respBulk(args[1]) + respBulk(args[2]))
+			}
+		}
+		return respInt(receivers)
+	case "DEL":
+		deleted := 0
+		for _, key := range args[1:] {
+			if s.set(key) != nil {
+				deleted++
+			}
+			delete(s.sets, key)
+			delete(s.expires, key)
+		}
+		return respInt(deleted)
+	case "SADD":
+		set := s.set(args[1])
+		if set == nil {
+			set = make(map[string]bool)
+			s.sets[args[1]] = set
+		}
+		added := 0
+		for _, member := range args[2:] {
+			if !set[member] {
+				set[member] = true
+				added++
+			}
+		}
+		return respInt(added)
+	case "SREM":
+		set := s.set(args[1])
+		removed := 0
+		for _, member := range args[2:] {
+			if set[member] {
+				delete(set, member)
+				removed++
+			}
+		}
+		if set != nil && len(set) == 0 {
+			delete(s.sets, args[1])
+			delete(s.expires, args[1])
+		}
+		return respInt(removed)
+	case "EXPIRE", "PEXPIRE":
+		if s.set(args[1]) == nil {
+			return respInt(0)
+		}
+		n, _ := strconv.ParseInt(args[2], 10, 64)
+		unit := time.Second
+		if strings.ToUpper(args[0]) == "PEXPIRE" {
+			unit = time.Millisecond
+		}
+		s.expires[args[1]] = s.now().Add(time.Duration(n) * unit)
+		return respInt(1)
+	case "SMEMBERS":
+		return respArray(s.set(args[1]))
+	case "SUNION":
+		union := make(map[string]bool)
+		for _, key := range args[1:] {
+			for member := range s.set(key) {
+				union[member] = true
+			}
+		}
+		return respArray(union)
+	default:
+		// HELLO、CLIENT SETINFO 等不支持的命令，客户端会回退到 RESP2
+		return "-ERR unknown command '" + args[0] + "'\r\n"
+	}
+}
+
+// set 返回未过期的集合，已过期的删除
+func (s *fakeRedis) set(key string) map[string]bool {
+	if expiresAt, ok := s.expires[key]; ok && !s.now().Before(expiresAt) {
+		delete(s.sets, key)
+		delete(s.expires, key)
+	}
+	return s.sets[key]
+}
+
+// subscribers 当前的订阅连接数
+func (s *fakeRedis) subscribers() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.subs)
+}
+
+func readRESPCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return nil, fmt.Errorf("unexpected line %q", line)
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		header, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(header[1:]))
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func respBulk(s string) string { return fmt.Sprintf("$%d\r\n%s\r\n", len(s), s) }
+func respInt(n int) string     { return fmt.Sprintf(":%d\r\n", n) }
+
+func respArray(members map[string]bool) string {
+	reply := fmt.Sprintf("*%d\r\n", len(members))
+	for member := range members {
+		reply += respBulk(member)
+	}
+	return reply
+}
+
+func sortedUsers(users []string) []string {
+	users = append([]string(nil), users...)
+	sort.Strings(users)
+	return users
+}
+
+func newTestRedisBroker(t *testing.T, server *fakeRedis, ttl time.Duration) *RedisBroker {
+	t.Helper()
+
+	broker, err := NewRedisBroker(server.config(), ttl)
+	if err != nil {
+		t.Fatalf("NewRedisBroker: %v", err)
+	}
+	t.Cleanup(func() { broker.Close() })
+	return broker
+}
+
+func TestRedisBrokerPublishSubscribe(t *testing.T) {
+	server := newFakeRedis(t, time.Now)
+	publisher := newTestRedisBroker(t, server, time.Minute)
+	subscriber := newTestRedisBroker(t, server, time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	received := make(chan *BrokerEnvelope, 1)
+	go subscriber.Subscribe(ctx, func(env *BrokerEnvelope) { received <- env })
+	waitFor(t, "subscription", func() bool { return server.subscribers() == 1 })
+
+	err := publisher.Publish(ctx, &BrokerEnvelope{
+		Node:    "n_a",
+		Message: &WSMessage{V: WSProtocolVersion, Type: EventMessage, Seq: 7, RoomID: "r_1", UserID: "u_1", Data: "hi"},
+		To:      []string{"u_2"},
+		Except:  "u_3",
+	})
+	if err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+
+	select {
+	case env := <-received:
+		if env.Node != "n_a" || env.Except != "u_3" || !reflect.DeepEqual(env.To, []string{"u_2"}) {
+			t.Fatalf("unexpected envelope: %+v", env)
+		}
+		msg := env.Message
+		if msg.Type != EventMessage || msg.Seq != 7 || msg.RoomID != "r_1" || msg.UserID != "u_1" || msg.Data != "hi" {
+			t.Fatalf("unexpected message: %+v", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("envelope not received")
+	}
+}
+
+func TestRedisBrokerOnlineSets(t *testing.T) {
+	clock := newFakeClock()
+	server := newFakeRedis(t, clock.Now)
+	ttl := 90 * time.Second
+	nodeA := newTestRedisBroker(t, server, ttl)
+	nodeB := newTestRedisBroker(t, server, ttl)
+	ctx := context.Background()
+
+	roomUsers := func() []string {
+		t.Helper()
+		users, err := nodeA.RoomUsers(ctx, "r_1")
+		if err != nil {
+			t.Fatalf("RoomUsers: %v", err)
+		}
+		return sortedUsers(users)
+	}
+
+	if users := roomUsers(); len(users) != 0 {
+		t.Fatalf("empty room users = %v", users)
+	}
+
+	// 每个节点各自维护一份快照，查询时合并去重
+	nodeA.SetRoomUsers(ctx, "n_a", "r_1", []string{"u_1", "u_2"})
+	nodeB.SetRoomUsers(ctx, "n_b", "r_1", []string{"u_2", "u_3"})
+	if users := roomUsers(); !reflect.DeepEqual(users, []string{"u_1", "u_2", "u_3"}) {
+		t.Fatalf("room users = %v", users)
+	}
+
+	// 覆盖而不是追加
+	nodeB.SetRoomUsers(ctx, "n_b", "r_1", []string{"u_4"})
+	if users := roomUsers(); !reflect.DeepEqual(users, []string{"u_1", "u_2", "u_4"}) {
+		t.Fatalf("room users after overwrite = %v", users)
+	}
+
+	// 节点 A 定期刷新，节点 B 宕机不再刷新，快照过期后只剩节点 A 的用户
+	for i := 0; i < 3; i++ {
+		clock.Advance(ttl / 3)
+		nodeA.SetRoomUsers(ctx, "n_a", "r_1", []string{"u_1", "u_2"})
+	}
+	if users := roomUsers(); !reflect.DeepEqual(users, []string{"u_1", "u_2"}) {
+		t.Fatalf("room users after node B expired = %v", users)
+	}
+
+	// 用户全部离开时清除本节点快照
+	nodeA.SetRoomUsers(ctx, "n_a", "r_1", nil)
+	if users := roomUsers(); len(users) != 0 {
+		t.Fatalf("room users after clear = %v", users)
+	}
+}
+
+// startTestNode 启动一个使用 broker 的 WSManager 节点，OnlineSync 由 clock 驱动
+func startTestNode(clock *fakeClock, broker Broker) *WSManager {
+	cfg := testWSConfig()
+	cfg.OnlineSync = 30 * time.Second
+	m := NewWSManager()
+	m.clock = clock
+	m.cfg = cfg
+	m.UseBroker(broker)
+	go m.Run()
+	return m
+}
+
+// subscribeTestClient 在节点上接入一个用户并订阅房间
+func subscribeTestClient(t *testing.T, m *WSManager, clock *fakeClock, userID, roomID string) *fakeConn {
+	t.Helper()
+
+	conn := newFakeConn(clock)
+	client := m.attach(conn, &utils.TokenClaims{UserID: userID, Type: utils.TokenTypeAccess}, "test", nil)
+	waitFor(t, "client registered", func() bool { return m.registered(client) })
+	if !m.SubscribeRoom(client, roomID) {
+		t.Fatalf("SubscribeRoom(%s, %s) failed", userID, roomID)
+	}
+	return conn
+}
+
+// eventFrames 返回连接收到的指定事件的帧
+func eventFrames(conn *fakeConn, event string) []string {
+	var frames []string
+	for _, frame := range conn.frames(websocket.TextMessage) {
+		if strings.Contains(string(frame.data), `"type":"`+event+`"`) {
+			frames = append(frames, string(frame.data))
+		}
+	}
+	return frames
+}
+
+// TestWSManagerCrossNode 两个节点共用一条 Redis 总线：房间广播跨节点各投递一次，在线用户按节点汇总，
+// 宕机节点的快照在 ttl 后过期。节点 B 使用独立且不前进的时钟，即不再刷新快照
+func TestWSManagerCrossNode(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	clockA, clockB := newFakeClock(), newFakeClock()
+	server := newFakeRedis(t, clockA.Now)
+	nodeA := startTestNode(clockA, newTestRedisBroker(t, server, 90*time.Second))
+	nodeB := startTestNode(clockB, newTestRedisBroker(t, server, 90*time.Second))
+	waitFor(t, "both nodes subscribed", func() bool { return server.subscribers() == 2 })
+
+	connA := subscribeTestClient(t, nodeA, clockA, "u_1", "r_1")
+	connB := subscribeTestClient(t, nodeB, clockB, "u_2", "r_1")
+	connOther := subscribeTestClient(t, nodeB, clockB, "u_3", "r_2")
+
+	// 在线用户按所有节点汇总
+	online := func(m *WSManager) []string { return sortedUsers(m.GetOnlineUsers("r_1")) }
+	waitFor(t, "cluster online users", func() bool {
+		return reflect.DeepEqual(online(nodeA), []string{"u_1", "u_2"}) && reflect.DeepEqual(online(nodeB), []string{"u_1", "u_2"})
+	})
+
+	// 节点 A 的广播经总线到达节点 B，每个连接只收到一次，其他房间收不到
+	nodeA.BroadcastToRoom("r_1", EventTopic, map[string]string{"topic": "cross node"})
+	waitFor(t, "remote delivery", func() bool { return len(eventFrames(connB, EventTopic)) == 1 })
+	// 反方向同样投递一次，节点不会再处理自己发布的消息
+	nodeB.BroadcastToRoom("r_1", EventTopic, map[string]string{"topic": "back"})
+	waitFor(t, "reverse delivery", func() bool { return len(eventFrames(connA, EventTopic)) == 2 })
+	time.Sleep(50 * time.Millisecond)
+	if got := len(eventFrames(connA, EventTopic)); got != 2 {
+		t.Fatalf("node A client topic frames = %d, want 2", got)
+	}
+	if got := len(eventFrames(connB, EventTopic)); got != 2 {
+		t.Fatalf("node B client topic frames = %d, want 2", got)
+	}
+	if got := len(eventFrames(connOther, EventTopic)); got != 0 {
+		t.Fatalf("client in other room topic frames = %d, want 0", got)
+	}
+
+	// 节点 B 宕机后不再刷新，节点 A 每 30s 刷新，90s 后只剩节点 A 的用户
+	for i := 0; i < 3; i++ {
+		clockA.Advance(30 * time.Second)
+	}
+	waitFor(t, "dead node expired", func() bool { return reflect.DeepEqual(online(nodeA), []string{"u_1"}) })
+}
@@ -2,8 +2,11 @@ package service
 
 import (
 	"context"
-	"errors"
+	"log"
+	"strings"
+	"time"
 	
+	"github.com/baijianruoli/bot_chat/backend/internal/conf"
 	"github.com/baijianruoli/bot_chat/backend/internal/dao"
 	"github.com/baijianruoli/bot_chat/backend/internal/model"
 	"github.com/baijianruoli/bot_chat/backend/internal/utils"
@@ -11,11 +14,27 @@ import (
 )
 
 // ChatServiceImpl 聊天服务实现
-type ChatServiceImpl struct{}
+type ChatServiceImpl struct {
+	tokens   *TokenService
+	commands *CommandRegistry
+	messages conf.MessageConfig
+}
 
 // NewChatService 创建服务实例
-func NewChatService() *ChatServiceImpl {
-	return &ChatServiceImpl{}
+func NewChatService(tokens *TokenService, messages conf.MessageConfig) *ChatServiceImpl {
+	commands := NewCommandRegistry()
+	registerBuiltinCommands(commands)
+
+	return &ChatServiceImpl{
+		tokens:   tokens,
+		commands: commands,
+		messages: messages,
+	}
+}
+
+// Commands 返回斜杠命令注册表
+func (s *ChatServiceImpl) Commands() *CommandRegistry {
+	return s.commands
 }
 
 // Register 用户注册
@@ -24,7 +43,10 @@ func (s *ChatServiceImpl) Register(ctx context.Context, req *chat.RegisterReq) (
 	userDAO := dao.NewUserDAO(dao.DB)
 	existingUser, err := userDAO.GetByUsername(req.Username)
 	if err != nil {
-		return utils.Error(utils.CodeServerError, "database error").(*utils.Resp), nil
+		return &chat.RegisterResp{
+			Code:    utils.CodeServerError,
+			Message: "database error",
+		}, nil
 	}
 	if existingUser != nil {
 		return &chat.RegisterResp{
@@ -34,10 +56,18 @@ func (s *ChatServiceImpl) Register(ctx context.Context, req *chat.RegisterReq) (
 	}
 	
 	// 创建新用户
+	passwordHash, err := utils.HashPassword(req.Password)
+	if err != nil {
+		return &chat.RegisterResp{
+			Code:    utils.CodeParamError,
+			Message: "invalid password",
+		}, nil
+	}
+	
 	user := &model.User{
 		UserID:   utils.GenerateUserID(),
 		Username: req.Username,
-		Password: utils.HashPassword(req.Password),
+		Password: passwordHash,
 		Nickname: req.Nickname,
 	}
 	
@@ -72,33 +102,136 @@ func (s *ChatServiceImpl) Login(ctx context.Context, req *chat.LoginReq) (*chat.
 		}, nil
 	}
 	
+	// 机器人只能使用 API 令牌
+	if user.IsBot() {
+		return &chat.LoginResp{
+			Code:    utils.CodePasswordError,
+			Message: "bot accounts must use an api token",
+		}, nil
+	}
+	
 	// 验证密码
-	if !utils.VerifyPassword(req.Password, user.Password) {
+	ok, needsRehash := utils.VerifyPassword(req.Password, user.Password)
+	if !ok {
 		return &chat.LoginResp{
 			Code:    utils.CodePasswordError,
 			Message: "password incorrect",
 		}, nil
 	}
 	
-	// 生成 token
-	token := utils.MD5(user.UserID + utils.GetCurrentTimeStr())
+	// 旧哈希（MD5 或低强度 bcrypt）登录成功后透明升级
+	if needsRehash {
+		if passwordHash, err := utils.HashPassword(req.Password); err == nil {
+			user.Password = passwordHash
+			if err := userDAO.Update(user); err != nil {
+				log.Printf("Failed to upgrade password hash: user=%s, err=%v", user.UserID, err)
+			}
+		}
+	}
+	
+	// 签发 token
+	tokens, err := s.tokens.Issue(user.UserID)
+	if err != nil {
+		return &chat.LoginResp{
+			Code:    utils.CodeServerError,
+			Message: "failed to issue token",
+		}, nil
+	}
 	
 	return &chat.LoginResp{
-		Code:    utils.CodeSuccess,
-		Message: "success",
-		Token:   token,
+		Code:         utils.CodeSuccess,
+		Message:      "success",
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresAt:    tokens.ExpiresAt,
 		User: &chat.UserInfo{
 			UserId:    user.UserID,
 			Username:  user.Username,
 			Nickname:  user.Nickname,
 			Avatar:    user.Avatar,
+			IsBot:     user.IsBot(),
 			CreatedAt: user.CreatedAt,
 		},
 	}, nil
 }
 
+// RefreshToken 刷新令牌
+func (s *ChatServiceImpl) RefreshToken(ctx context.Context, req *chat.RefreshTokenReq) (*chat.RefreshTokenResp, error) {
+	tokens, _, err := s.tokens.Refresh(req.RefreshToken)
+	if err != nil {
+		code, message := tokenErrorCode(err)
+		return &chat.RefreshTokenResp{
+			Code:    code,
+			Message: message,
+		}, nil
+	}
+	
+	return &chat.RefreshTokenResp{
+		Code:         utils.CodeSuccess,
+		Message:      "success",
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresAt:    tokens.ExpiresAt,
+	}, nil
+}
+
+// Logout 退出登录
+func (s *ChatServiceImpl) Logout(ctx context.Context, req *chat.LogoutReq) (*chat.LogoutResp, error) {
+	claims, ok := ClaimsFromContext(ctx)
+	if !ok {
+		return &chat.LogoutResp{
+			Code:    utils.CodeUnauthorized,
+			Message: "unauthorized",
+		}, nil
+	}
+	
+	revoke := s.tokens.Revoke
+	if claims.Type == utils.TokenTypeBot {
+		revoke = s.tokens.RevokeBotToken
+	}
+	if err := revoke(claims.SessionID); err != nil {
+		return &chat.LogoutResp{
+			Code:    utils.CodeServerError,
+			Message: "database error",
+		}, nil
+	}
+	
+	return &chat.LogoutResp{
+		Code:    utils.CodeSuccess,
+		Message: "success",
+	}, nil
+}
+
+// tokenErrorCode 将令牌校验错误映射为业务码
+func tokenErrorCode(err error) (int32, string) {
+	switch err {
+	case utils.ErrTokenExpired:
+		return utils.CodeTokenExpired, "token expired"
+	case utils.ErrTokenInvalid, ErrSessionRevoked, ErrRefreshReused:
+		return utils.CodeTokenInvalid, err.Error()
+	default:
+		return utils.CodeServerError, "database error"
+	}
+}
+
 // CreateRoom 创建房间
 func (s *ChatServiceImpl) CreateRoom(ctx context.Context, req *chat.CreateRoomReq) (*chat.CreateRoomResp, error) {
+	creatorID, ok := authorize(ctx, req.CreatorId)
+	if !ok {
+		return &chat.CreateRoomResp{
+			Code:    utils.CodeUnauthorized,
+			Message: "unauthorized",
+		}, nil
+	}
+	
+	visibility, ok := roomVisibility(req.Visibility)
+	if !ok {
+		return &chat.CreateRoomResp{
+			Code:    utils.CodeParamError,
+			Message: "invalid visibility",
+		}, nil
+	}
+	
 	roomDAO := dao.NewRoomDAO(dao.DB)
 	roomMemberDAO := dao.NewRoomMemberDAO(dao.DB)
 	
@@ -106,8 +239,9 @@ func (s *ChatServiceImpl) CreateRoom(ctx context.Context, req *chat.CreateRoomRe
 		RoomID:      utils.GenerateRoomID(),
 		Name:        req.Name,
 		Description: req.Description,
-		CreatorID:   req.CreatorId,
+		CreatorID:   creatorID,
 		UserCount:   1,
+		Visibility:  visibility,
 	}
 	
 	if err := roomDAO.Create(room); err != nil {
@@ -117,8 +251,8 @@ func (s *ChatServiceImpl) CreateRoom(ctx context.Context, req *chat.CreateRoomRe
 		}, nil
 	}
 	
-	// 创建者自动加入房间
-	if err := roomMemberDAO.AddMember(room.RoomID, req.CreatorId); err != nil {
+	// 创建者自动加入房间并成为房主
+	if err := roomMemberDAO.AddMemberWithRole(room.RoomID, creatorID, model.RoomRoleOwner); err != nil {
 		return &chat.CreateRoomResp{
 			Code:    utils.CodeServerError,
 			Message: "failed to join room",
@@ -135,12 +269,21 @@ func (s *ChatServiceImpl) CreateRoom(ctx context.Context, req *chat.CreateRoomRe
 			CreatorId: room.CreatorID,
 			UserCount: room.UserCount,
 			CreatedAt: room.CreatedAt,
+			Visibility: room.Visibility,
 		},
 	}, nil
 }
 
 // ListRooms 获取房间列表
 func (s *ChatServiceImpl) ListRooms(ctx context.Context, req *chat.ListRoomsReq) (*chat.ListRoomsResp, error) {
+	userID, ok := authorize(ctx, "")
+	if !ok {
+		return &chat.ListRoomsResp{
+			Code:    utils.CodeUnauthorized,
+			Message: "unauthorized",
+		}, nil
+	}
+	
 	roomDAO := dao.NewRoomDAO(dao.DB)
 	
 	if req.Page <= 0 {
@@ -150,7 +293,7 @@ func (s *ChatServiceImpl) ListRooms(ctx context.Context, req *chat.ListRoomsReq)
 		req.PageSize = 20
 	}
 	
-	rooms, total, err := roomDAO.List(int(req.Page), int(req.PageSize))
+	rooms, total, err := roomDAO.List(int(req.Page), int(req.PageSize), userID)
 	if err != nil {
 		return &chat.ListRoomsResp{
 			Code:    utils.CodeServerError,
@@ -167,6 +310,7 @@ func (s *ChatServiceImpl) ListRooms(ctx context.Context, req *chat.ListRoomsReq)
 			CreatorId:   room.CreatorID,
 			UserCount:   room.UserCount,
 			CreatedAt:   room.CreatedAt,
+			Visibility:  room.Visibility,
 		}
 	}
 	
@@ -180,8 +324,37 @@ func (s *ChatServiceImpl) ListRooms(ctx context.Context, req *chat.ListRoomsReq)
 
 // JoinRoom 加入房间
 func (s *ChatServiceImpl) JoinRoom(ctx context.Context, req *chat.JoinRoomReq) (*chat.JoinRoomResp, error) {
+	userID, ok := authorize(ctx, req.UserId)
+	if !ok {
+		return &chat.JoinRoomResp{
+			Code:    utils.CodeUnauthorized,
+			Message: "unauthorized",
+		}, nil
+	}
+	
 	roomDAO := dao.NewRoomDAO(dao.DB)
 	roomMemberDAO := dao.NewRoomMemberDAO(dao.DB)
+	inviteDAO := dao.NewRoomInviteDAO(dao.DB)
+	
+	// 凭邀请码加入时房间由邀请链接确定，回填 req.RoomId 供调用方广播
+	var invite *model.RoomInvite
+	if req.InviteCode != "" {
+		var err error
+		invite, err = inviteDAO.GetByCode(req.InviteCode)
+		if err != nil {
+			return &chat.JoinRoomResp{
+				Code:    utils.CodeServerError,
+				Message: "database error",
+			}, nil
+		}
+		if invite == nil || (req.RoomId != "" && invite.RoomID != req.RoomId) {
+			return &chat.JoinRoomResp{
+				Code:    utils.CodeInviteInvalid,
+				Message: "invalid invite",
+			}, nil
+		}
+		req.RoomId = invite.RoomID
+	}
 	
 	// 检查房间是否存在
 	room, err := roomDAO.GetByID(req.RoomId)
@@ -199,7 +372,7 @@ func (s *ChatServiceImpl) JoinRoom(ctx context.Context, req *chat.JoinRoomReq) (
 	}
 	
 	// 检查是否已在房间中
-	isMember, err := roomMemberDAO.IsMember(req.RoomId, req.UserId)
+	isMember, err := roomMemberDAO.IsMember(req.RoomId, userID)
 	if err != nil {
 		return &chat.JoinRoomResp{
 			Code:    utils.CodeServerError,
@@ -213,13 +386,75 @@ func (s *ChatServiceImpl) JoinRoom(ctx context.Context, req *chat.JoinRoomReq) (
 		}, nil
 	}
 	
-	// 添加成员
-	if err := roomMemberDAO.AddMember(req.RoomId, req.UserId); err != nil {
+	// 被封禁的用户不能加入，邀请链接与入群申请同样拒绝
+	banned, err := dao.NewRoomBanDAO(dao.DB).IsBanned(req.RoomId, userID)
+	if err != nil {
 		return &chat.JoinRoomResp{
 			Code:    utils.CodeServerError,
-			Message: "failed to join room",
+			Message: "database error",
 		}, nil
 	}
+	if banned {
+		return &chat.JoinRoomResp{
+			Code:    utils.CodeBannedFromRoom,
+			Message: "banned from room",
+		}, nil
+	}
+	
+	// 私聊房间只能通过 OpenDirectChat 进入
+	if room.Kind == model.RoomKindDirect {
+		return &chat.JoinRoomResp{
+			Code:    utils.CodeRoomNotFound,
+			Message: "room not found",
+		}, nil
+	}
+	
+	// 有效的邀请链接可加入任意可见性的房间，使用邀请与添加成员在同一事务中；否则按可见性处理
+	if invite != nil {
+		ok, err := inviteDAO.JoinWithInvite(invite.Code, req.RoomId, userID, time.Now().UnixMilli())
+		if err != nil {
+			return &chat.JoinRoomResp{
+				Code:    utils.CodeServerError,
+				Message: "failed to join room",
+			}, nil
+		}
+		if !ok {
+			return &chat.JoinRoomResp{
+				Code:    utils.CodeInviteInvalid,
+				Message: "invite expired or used up",
+			}, nil
+		}
+	} else {
+		switch room.Visibility {
+		case model.RoomVisibilityPrivate:
+			return &chat.JoinRoomResp{
+				Code:    utils.CodeRoomNotFound,
+				Message: "room not found",
+			}, nil
+		case model.RoomVisibilityInvite:
+			request, err := dao.NewJoinRequestDAO(dao.DB).Submit(room.RoomID, userID)
+			if err != nil {
+				return &chat.JoinRoomResp{
+					Code:    utils.CodeServerError,
+					Message: "failed to submit join request",
+				}, nil
+			}
+			user, _ := dao.NewUserDAO(dao.DB).GetByID(userID)
+			return &chat.JoinRoomResp{
+				Code:    utils.CodeJoinPending,
+				Message: "join request pending approval",
+				Request: toJoinRequestInfo(request, user),
+			}, nil
+		}
+		
+		// 添加成员
+		if err := roomMemberDAO.AddMember(req.RoomId, userID); err != nil {
+			return &chat.JoinRoomResp{
+				Code:    utils.CodeServerError,
+				Message: "failed to join room",
+			}, nil
+		}
+	}
 	
 	// 更新房间人数
 	roomDAO.UpdateUserCount(req.RoomId, 1)
@@ -234,32 +469,42 @@ func (s *ChatServiceImpl) JoinRoom(ctx context.Context, req *chat.JoinRoomReq) (
 			CreatorId:   room.CreatorID,
 			UserCount:   room.UserCount + 1,
 			CreatedAt:   room.CreatedAt,
+			Visibility:  room.Visibility,
 		},
 	}, nil
 }
 
 // LeaveRoom 离开房间
 func (s *ChatServiceImpl) LeaveRoom(ctx context.Context, req *chat.LeaveRoomReq) (*chat.LeaveRoomResp, error) {
+	access, code, message := authorizeRoom(ctx, req.UserId, req.RoomId, PermView)
+	if access == nil {
+		return &chat.LeaveRoomResp{
+			Code:    code,
+			Message: message,
+		}, nil
+	}
+	userID := access.UserID
+	
 	roomDAO := dao.NewRoomDAO(dao.DB)
 	roomMemberDAO := dao.NewRoomMemberDAO(dao.DB)
 	
-	// 检查是否在房间中
-	isMember, err := roomMemberDAO.IsMember(req.RoomId, req.UserId)
-	if err != nil {
+	if access.Room.Kind == model.RoomKindDirect {
 		return &chat.LeaveRoomResp{
-			Code:    utils.CodeServerError,
-			Message: "database error",
+			Code:    utils.CodeParamError,
+			Message: "cannot leave a direct chat",
 		}, nil
 	}
-	if !isMember {
+	
+	// 房间还有其他成员时，房主需先转让房主
+	if access.Role == model.RoomRoleOwner && access.Room.UserCount > 1 {
 		return &chat.LeaveRoomResp{
-			Code:    utils.CodeNotInRoom,
-			Message: "not in room",
+			Code:    utils.CodeParamError,
+			Message: "transfer ownership before leaving",
 		}, nil
 	}
 	
 	// 移除成员
-	if err := roomMemberDAO.RemoveMember(req.RoomId, req.UserId); err != nil {
+	if err := roomMemberDAO.RemoveMember(req.RoomId, userID); err != nil {
 		return &chat.LeaveRoomResp{
 			Code:    utils.CodeServerError,
 			Message: "failed to leave room",
@@ -277,43 +522,51 @@ func (s *ChatServiceImpl) LeaveRoom(ctx context.Context, req *chat.LeaveRoomReq)
 
 // SendMessage 发送消息
 func (s *ChatServiceImpl) SendMessage(ctx context.Context, req *chat.SendMessageReq) (*chat.SendMessageResp, error) {
-	roomDAO := dao.NewRoomDAO(dao.DB)
+	// 斜杠命令的权限由各命令自行校验，例如被禁言的成员仍可使用 /help
+	perm := PermSend
+	if IsCommand(req.Content) {
+		perm = PermView
+	}
+	access, code, message := authorizeRoom(ctx, req.UserId, req.RoomId, perm)
+	if access == nil {
+		return &chat.SendMessageResp{
+			Code:    code,
+			Message: message,
+		}, nil
+	}
+	userID := access.UserID
+	
 	roomMemberDAO := dao.NewRoomMemberDAO(dao.DB)
 	userDAO := dao.NewUserDAO(dao.DB)
 	messageDAO := dao.NewMessageDAO(dao.DB)
 	
-	// 检查房间是否存在
-	room, err := roomDAO.GetByID(req.RoomId)
-	if err != nil {
-		return &chat.SendMessageResp{
-			Code:    utils.CodeServerError,
-			Message: "database error",
-		}, nil
+	// 斜杠命令不入库，交给命令注册表处理；以 // 开头的消息去掉一个 / 后按普通消息发送
+	content := req.Content
+	if IsCommand(content) {
+		return s.execCommand(ctx, access, content), nil
 	}
-	if room == nil {
-		return &chat.SendMessageResp{
-			Code:    utils.CodeRoomNotFound,
-			Message: "room not found",
-		}, nil
+	if strings.HasPrefix(content, "//") {
+		content = content[1:]
 	}
-	
-	// 检查用户是否在房间中
-	isMember, err := roomMemberDAO.IsMember(req.RoomId, req.UserId)
-	if err != nil {
+	msgType, ok := userMsgType(req.MsgType)
+	if !ok {
 		return &chat.SendMessageResp{
-			Code:    utils.CodeServerError,
-			Message: "database error",
+			Code:    utils.CodeParamError,
+			Message: "invalid msg_type",
 		}, nil
 	}
-	if !isMember {
+	
+	// 引用消息与话题根消息须在同一房间
+	quoted, threadRootID, code, errMsg := resolveReply(req.RoomId, req.ReplyToMsgId, req.ThreadRootId)
+	if code != utils.CodeSuccess {
 		return &chat.SendMessageResp{
-			Code:    utils.CodeNotInRoom,
-			Message: "not in room",
+			Code:    code,
+			Message: errMsg,
 		}, nil
 	}
 	
 	// 获取发送者信息
-	user, err := userDAO.GetByID(req.UserId)
+	user, err := userDAO.GetByID(userID)
 	if err != nil {
 		return &chat.SendMessageResp{
 			Code:    utils.CodeServerError,
@@ -323,11 +576,13 @@ func (s *ChatServiceImpl) SendMessage(ctx context.Context, req *chat.SendMessage
 	
 	// 创建消息
 	msg := &model.Message{
-		MsgID:   utils.GenerateMsgID(),
-		RoomID:  req.RoomId,
-		UserID:  req.UserId,
-		Content: req.Content,
-		MsgType: req.MsgType,
+		MsgID:        utils.GenerateMsgID(),
+		RoomID:       req.RoomId,
+		UserID:       userID,
+		Content:      content,
+		MsgType:      msgType,
+		ReplyToMsgID: req.ReplyToMsgId,
+		ThreadRootID: threadRootID,
 	}
 	
 	if err := messageDAO.Create(msg); err != nil {
@@ -337,61 +592,37 @@ func (s *ChatServiceImpl) SendMessage(ctx context.Context, req *chat.SendMessage
 		}, nil
 	}
 	
+	// 发送者已读到自己发送的消息，失败不影响发送
+	if threadRootID == "" {
+		roomMemberDAO.MarkRead(req.RoomId, userID, msg.Seq, msg.CreatedAt)
+	}
+	
+	info := toMessageInfo(msg, user)
+	if quoted != nil {
+		quotedUser, _ := userDAO.GetByID(quoted.UserID)
+		info.ReplyTo = toMessageInfo(quoted, quotedUser)
+	}
+	
 	return &chat.SendMessageResp{
 		Code:    utils.CodeSuccess,
 		Message: "success",
-		Msg: &chat.MessageInfo{
-			MsgId:     msg.MsgID,
-			RoomId:    msg.RoomID,
-			Sender: &chat.UserInfo{
-				UserId:   user.UserID,
-				Username: user.Username,
-				Nickname: user.Nickname,
-				Avatar:   user.Avatar,
-			},
-			Content:   msg.Content,
-			MsgType:   msg.MsgType,
-			Timestamp: msg.CreatedAt,
-		},
+		Msg:     info,
 	}, nil
 }
 
 // GetHistory 获取历史消息
 func (s *ChatServiceImpl) GetHistory(ctx context.Context, req *chat.GetHistoryReq) (*chat.GetHistoryResp, error) {
-	roomDAO := dao.NewRoomDAO(dao.DB)
-	roomMemberDAO := dao.NewRoomMemberDAO(dao.DB)
-	userDAO := dao.NewUserDAO(dao.DB)
-	messageDAO := dao.NewMessageDAO(dao.DB)
-	
-	// 检查房间是否存在
-	room, err := roomDAO.GetByID(req.RoomId)
-	if err != nil {
+	access, code, message := authorizeRoom(ctx, req.UserId, req.RoomId, PermView)
+	if access == nil {
 		return &chat.GetHistoryResp{
-			Code:    utils.CodeServerError,
-			Message: "database error",
-		}, nil
-	}
-	if room == nil {
-		return &chat.GetHistoryResp{
-			Code:    utils.CodeRoomNotFound,
-			Message: "room not found",
+			Code:    code,
+			Message: message,
 		}, nil
 	}
+	userID := access.UserID
 	
-	// 检查用户是否在房间中
-	isMember, err := roomMemberDAO.IsMember(req.RoomId, req.UserId)
-	if err != nil {
-		return &chat.GetHistoryResp{
-			Code:    utils.CodeServerError,
-			Message: "database error",
-		}, nil
-	}
-	if !isMember {
-		return &chat.GetHistoryResp{
-			Code:    utils.CodeNotInRoom,
-			Message: "not in room",
-		}, nil
-	}
+	userDAO := dao.NewUserDAO(dao.DB)
+	messageDAO := dao.NewMessageDAO(dao.DB)
 	
 	// 获取消息
 	if req.Limit <= 0 {
@@ -401,7 +632,7 @@ func (s *ChatServiceImpl) GetHistory(ctx context.Context, req *chat.GetHistoryRe
 		req.Limit = 100
 	}
 	
-	messages, err := messageDAO.GetHistory(req.RoomId, req.BeforeTime, int(req.Limit))
+	messages, err := messageDAO.GetHistory(req.RoomId, req.BeforeSeq, int(req.Limit))
 	if err != nil {
 		return &chat.GetHistoryResp{
 			Code:    utils.CodeServerError,
@@ -412,19 +643,29 @@ func (s *ChatServiceImpl) GetHistory(ctx context.Context, req *chat.GetHistoryRe
 	// 填充发送者信息
 	msgList := make([]*chat.MessageInfo, len(messages))
 	for i, msg := range messages {
-		user, _ := userDAO.GetByID(msg.UserID)
-		msgList[i] = &chat.MessageInfo{
-			MsgId:  msg.MsgID,
-			RoomId: msg.RoomID,
-			Sender: &chat.UserInfo{
-				UserId:   user.UserID,
-				Username: user.Username,
-				Nickname: user.Nickname,
-				Avatar:   user.Avatar,
-			},
-			Content:   msg.Content,
-			MsgType:   msg.MsgType,
-			Timestamp: msg.CreatedAt,
+		var user *model.User
+		if msg.UserID != "" {
+			user, _ = userDAO.GetByID(msg.UserID)
+		}
+		msgList[i] = toMessageInfo(msg, user)
+	}
+	
+	err = attachReplyTo(msgList)
+	if err == nil {
+		err = attachReactions(msgList, userID)
+	}
+	if err != nil {
+		return &chat.GetHistoryResp{
+			Code:    utils.CodeServerError,
+			Message: "database error",
+		}, nil
+	}
+	if req.WithRevisions {
+		if err := attachRevisions(messages, msgList); err != nil {
+			return &chat.GetHistoryResp{
+				Code:    utils.CodeServerError,
+				Message: "database error",
+			}, nil
 		}
 	}
 	
@@ -437,3 +678,40 @@ func (s *ChatServiceImpl) GetHistory(ctx context.Context, req *chat.GetHistoryRe
 		HasMore:  hasMore,
 	}, nil
 }
+
+// toMessageInfo 组装消息信息；user 为空时（系统消息）以消息上的显示名作为发送者
+func toMessageInfo(msg *model.Message, user *model.User) *chat.MessageInfo {
+	sender := &chat.UserInfo{
+		UserId:   msg.UserID,
+		Nickname: msg.SenderName,
+	}
+	if user != nil {
+		sender = &chat.UserInfo{
+			UserId:   user.UserID,
+			Username: user.Username,
+			Nickname: user.Nickname,
+			Avatar:   user.Avatar,
+			IsBot:    user.IsBot(),
+		}
+		if msg.SenderName != "" {
+			sender.Nickname = msg.SenderName
+		}
+	}
+	
+	return &chat.MessageInfo{
+		MsgId:        msg.MsgID,
+		RoomId:       msg.RoomID,
+		Sender:       sender,
+		Content:      msg.Content,
+		MsgType:      msg.MsgType,
+		Timestamp:    msg.CreatedAt,
+		Seq:          msg.Seq,
+		EditedAt:     msg.EditedAt,
+		RecalledAt:   msg.RecalledAt,
+		RecalledBy:   msg.RecalledBy,
+		ReplyToMsgId: msg.ReplyToMsgID,
+		ThreadRootId: msg.ThreadRootID,
+		ReplyCount:   msg.ReplyCount,
+		LastReplyAt:  msg.LastReplyAt,
+	}
+}
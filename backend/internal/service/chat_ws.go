@@ -3,7 +3,10 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"log"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/baijianruoli/bot_chat/backend/internal/dao"
 	"github.com/baijianruoli/bot_chat/backend/internal/model"
@@ -19,44 +22,245 @@ func (s *ChatServiceImpl) SendMessageWithWS(ctx context.Context, req *chat.SendM
 		return resp, err
 	}
 
+	// 消息发出后不再显示正在输入
+	userID, _ := CallerFromContext(ctx)
+	GlobalWSManager.StopTyping(req.RoomId, userID)
+
+	// 话题回复只通知话题参与者，不进入房间主时间线
+	if resp.Msg != nil && resp.Msg.ThreadRootId != "" {
+		message, err := threadReplyMessage(resp.Msg)
+		if err != nil {
+			log.Printf("Failed to notify thread participants: msg=%s, err=%v", resp.Msg.MsgId, err)
+		} else if message != nil {
+			GlobalWSManager.Broadcast(message)
+		}
+		return resp, nil
+	}
+
 	// 通过 WebSocket 广播消息
 	if resp.Msg != nil {
 		message := &WSMessage{
-			Type:   "message",
+			Type:   EventMessage,
+			Seq:    resp.Msg.Seq,
 			RoomID: req.RoomId,
-			UserID: req.UserId,
+			UserID: resp.Msg.Sender.UserId,
 			Data:   resp.Msg,
 		}
-		GlobalWSManager.broadcast <- message
+		GlobalWSManager.Broadcast(message)
+	}
+
+	return resp, nil
+}
+
+// EditMessageWithWS 编辑消息并通过 WebSocket 广播新内容
+func (s *ChatServiceImpl) EditMessageWithWS(ctx context.Context, req *chat.EditMessageReq) (*chat.EditMessageResp, error) {
+	resp, err := s.EditMessage(ctx, req)
+	if err != nil || resp.Code != utils.CodeSuccess {
+		return resp, err
 	}
 
+	GlobalWSManager.Broadcast(&WSMessage{
+		Type:   EventEdited,
+		RoomID: resp.Msg.RoomId,
+		UserID: resp.Msg.Sender.UserId,
+		Data:   resp.Msg,
+	})
+
+	return resp, nil
+}
+
+// RecallMessageWithWS 撤回消息并通知房间移除
+func (s *ChatServiceImpl) RecallMessageWithWS(ctx context.Context, req *chat.RecallMessageReq) (*chat.RecallMessageResp, error) {
+	resp, err := s.RecallMessage(ctx, req)
+	if err != nil || resp.Code != utils.CodeSuccess {
+		return resp, err
+	}
+	broadcastRecalled(resp.Msg)
+	return resp, nil
+}
+
+// DeleteMessageWithWS 删除消息并通知房间移除
+func (s *ChatServiceImpl) DeleteMessageWithWS(ctx context.Context, req *chat.DeleteMessageReq) (*chat.DeleteMessageResp, error) {
+	resp, err := s.DeleteMessage(ctx, req)
+	if err != nil || resp.Code != utils.CodeSuccess {
+		return resp, err
+	}
+	broadcastRecalled(resp.Msg)
+	return resp, nil
+}
+
+// broadcastRecalled 向房间广播消息墓碑
+func broadcastRecalled(msg *chat.MessageInfo) {
+	GlobalWSManager.Broadcast(&WSMessage{
+		Type:   EventRecalled,
+		RoomID: msg.RoomId,
+		UserID: msg.RecalledBy,
+		Data:   msg,
+	})
+}
+
+// AddReactionWithWS 添加表情回应并通过 WebSocket 广播
+func (s *ChatServiceImpl) AddReactionWithWS(ctx context.Context, req *chat.AddReactionReq) (*chat.AddReactionResp, error) {
+	resp, err := s.AddReaction(ctx, req)
+	if err != nil || resp.Code != utils.CodeSuccess {
+		return resp, err
+	}
+	userID, _ := CallerFromContext(ctx)
+	broadcastReaction(req.MsgId, userID, req.Emoji, true, resp.Reactions)
+	return resp, nil
+}
+
+// RemoveReactionWithWS 移除表情回应并通过 WebSocket 广播
+func (s *ChatServiceImpl) RemoveReactionWithWS(ctx context.Context, req *chat.RemoveReactionReq) (*chat.RemoveReactionResp, error) {
+	resp, err := s.RemoveReaction(ctx, req)
+	if err != nil || resp.Code != utils.CodeSuccess {
+		return resp, err
+	}
+	userID, _ := CallerFromContext(ctx)
+	broadcastReaction(req.MsgId, userID, req.Emoji, false, resp.Reactions)
+	return resp, nil
+}
+
+// broadcastReaction 向消息所在房间广播某个表情的最新回应数
+func broadcastReaction(msgID, userID, emoji string, added bool, reactions []*chat.ReactionInfo) {
+	msg, err := dao.NewMessageDAO(dao.DB).GetByID(msgID)
+	if err != nil || msg == nil {
+		return
+	}
+
+	var count int32
+	for _, reaction := range reactions {
+		if reaction.Emoji == emoji {
+			count = reaction.Count
+		}
+	}
+	GlobalWSManager.BroadcastToRoom(msg.RoomID, EventReaction, map[string]interface{}{
+		"msg_id":  msgID,
+		"user_id": userID,
+		"emoji":   emoji,
+		"added":   added,
+		"count":   count,
+	})
+}
+
+// MarkReadWithWS 标记已读，游标前进时向房间广播已读回执
+func (s *ChatServiceImpl) MarkReadWithWS(ctx context.Context, req *chat.MarkReadReq) (*chat.MarkReadResp, error) {
+	resp, err := s.MarkRead(ctx, req)
+	if err != nil || resp.Code != utils.CodeSuccess || !resp.Advanced {
+		return resp, err
+	}
+	userID, _ := CallerFromContext(ctx)
+	for _, cursor := range resp.Cursors {
+		if cursor.UserId == userID {
+			GlobalWSManager.BroadcastToRoom(req.RoomId, EventReadReceipt, map[string]interface{}{
+				"user_id":       userID,
+				"last_read_seq": cursor.LastReadSeq,
+				"read_at":       cursor.LastReadAt,
+			})
+		}
+	}
 	return resp, nil
 }
 
 // JoinRoomWithWS 加入房间并通过 WebSocket 广播
 func (s *ChatServiceImpl) JoinRoomWithWS(ctx context.Context, req *chat.JoinRoomReq, wsClient *WSClient) (*chat.JoinRoomResp, error) {
 	resp, err := s.JoinRoom(ctx, req)
+	if err == nil && resp.Code == utils.CodeJoinPending {
+		notifyJoinRequest(resp.Request)
+	}
 	if err != nil || resp.Code != utils.CodeSuccess {
 		return resp, err
 	}
 
-	// 更新 WebSocket 客户端的房间
-	wsClient.roomID = req.RoomId
+	// WebSocket 连接订阅该房间（HTTP 调用时为 nil）
+	if wsClient != nil {
+		GlobalWSManager.SubscribeRoom(wsClient, req.RoomId)
+	}
 
 	// 广播用户加入消息
-	GlobalWSManager.BroadcastToRoom(req.RoomId, "join", map[string]interface{}{
-		"user_id":  req.UserId,
+	userID, _ := CallerFromContext(ctx)
+	GlobalWSManager.BroadcastToRoom(req.RoomId, EventJoin, map[string]interface{}{
+		"user_id":  userID,
 		"nickname": resp.Room.Name,
 	})
 
 	// 更新在线人数
-	GlobalWSManager.BroadcastToRoom(req.RoomId, "online_count", map[string]interface{}{
+	GlobalWSManager.BroadcastToRoom(req.RoomId, EventOnlineCount, map[string]interface{}{
 		"count": GlobalWSManager.GetOnlineCount(req.RoomId),
 	})
 
 	return resp, nil
 }
 
+// notifyJoinRequest 通知可审批的成员（房主）有新的入群申请
+func notifyJoinRequest(request *chat.JoinRequestInfo) {
+	reviewers, err := dao.NewRoomMemberDAO(dao.DB).ListByRoles(request.RoomId, rolesWith(PermApproveJoin))
+	if err != nil || len(reviewers) == 0 {
+		return
+	}
+	GlobalWSManager.Broadcast(&WSMessage{
+		Type:   EventJoinRequest,
+		RoomID: request.RoomId,
+		UserID: request.User.UserId,
+		To:     reviewers,
+		Data:   request,
+	})
+}
+
+// ReviewJoinRequestWithWS 审批入群申请，批准时向房间广播成员加入
+func (s *ChatServiceImpl) ReviewJoinRequestWithWS(ctx context.Context, req *chat.ReviewJoinRequestReq) (*chat.ReviewJoinRequestResp, error) {
+	resp, err := s.ReviewJoinRequest(ctx, req)
+	if err != nil || resp.Code != utils.CodeSuccess || !req.Approve {
+		return resp, err
+	}
+
+	userID, _ := CallerFromContext(ctx)
+	GlobalWSManager.BroadcastToRoom(resp.Request.RoomId, EventJoin, map[string]interface{}{
+		"user_id":     resp.Request.User.UserId,
+		"approved_by": userID,
+	})
+	return resp, nil
+}
+
+// PromoteMemberWithWS 提升成员角色并向房间广播
+func (s *ChatServiceImpl) PromoteMemberWithWS(ctx context.Context, req *chat.PromoteMemberReq) (*chat.PromoteMemberResp, error) {
+	resp, err := s.PromoteMember(ctx, req)
+	if err == nil && resp.Code == utils.CodeSuccess {
+		broadcastRoleChanged(ctx, resp.Member)
+	}
+	return resp, err
+}
+
+// DemoteMemberWithWS 降低成员角色并向房间广播
+func (s *ChatServiceImpl) DemoteMemberWithWS(ctx context.Context, req *chat.DemoteMemberReq) (*chat.DemoteMemberResp, error) {
+	resp, err := s.DemoteMember(ctx, req)
+	if err == nil && resp.Code == utils.CodeSuccess {
+		broadcastRoleChanged(ctx, resp.Member)
+	}
+	return resp, err
+}
+
+// TransferOwnershipWithWS 转让房主并向房间广播双方的角色变更
+func (s *ChatServiceImpl) TransferOwnershipWithWS(ctx context.Context, req *chat.TransferOwnershipReq) (*chat.TransferOwnershipResp, error) {
+	resp, err := s.TransferOwnership(ctx, req)
+	if err == nil && resp.Code == utils.CodeSuccess {
+		for _, member := range resp.Members {
+			broadcastRoleChanged(ctx, member)
+		}
+	}
+	return resp, err
+}
+
+// broadcastRoleChanged 向房间广播成员角色变更
+func broadcastRoleChanged(ctx context.Context, member *chat.RoomMemberInfo) {
+	userID, _ := CallerFromContext(ctx)
+	GlobalWSManager.BroadcastToRoom(member.RoomId, EventRoleChanged, map[string]interface{}{
+		"user_id":    member.UserId,
+		"role":       member.Role,
+		"changed_by": userID,
+	})
+}
+
 // LeaveRoomWithWS 离开房间并通过 WebSocket 广播
 func (s *ChatServiceImpl) LeaveRoomWithWS(ctx context.Context, req *chat.LeaveRoomReq) (*chat.LeaveRoomResp, error) {
 	resp, err := s.LeaveRoom(ctx, req)
@@ -65,51 +269,47 @@ func (s *ChatServiceImpl) LeaveRoomWithWS(ctx context.Context, req *chat.LeaveRo
 	}
 
 	// 广播用户离开消息
-	GlobalWSManager.BroadcastToRoom(req.RoomId, "leave", map[string]interface{}{
-		"user_id": req.UserId,
+	userID, _ := CallerFromContext(ctx)
+	GlobalWSManager.UnsubscribeUser(userID, req.RoomId)
+	GlobalWSManager.BroadcastToRoom(req.RoomId, EventLeave, map[string]interface{}{
+		"user_id": userID,
 	})
 
 	// 更新在线人数
-	GlobalWSManager.BroadcastToRoom(req.RoomId, "online_count", map[string]interface{}{
+	GlobalWSManager.BroadcastToRoom(req.RoomId, EventOnlineCount, map[string]interface{}{
 		"count": GlobalWSManager.GetOnlineCount(req.RoomId),
 	})
 
 	return resp, nil
 }
 
-// WSMessageData WebSocket 消息数据结构
-type WSMessageData struct {
-	MsgID     string `json:"msg_id"`
-	RoomID    string `json:"room_id"`
-	UserID    string `json:"user_id"`
-	Content   string `json:"content"`
-	Nickname  string `json:"nickname"`
-	Avatar    string `json:"avatar"`
-	Timestamp int64  `json:"timestamp"`
-	MsgType   int32  `json:"msg_type"`
-}
-
-// HandleWSMessage 处理 WebSocket 消息
-func HandleWSMessage(userID string, roomID string, data json.RawMessage) {
-	var msgData WSMessageData
-	if err := json.Unmarshal(data, &msgData); err != nil {
-		return
+// HandleWSMessage 处理 WebSocket 发送的消息，返回 ack 的结果。
+// 与 HTTP/RPC 共用 SendMessage 的成员校验与入库逻辑，广播的 MessageInfo 与历史消息一致
+func (r *WSRouter) HandleWSMessage(client *WSClient, roomID string, data *WSSendData) (int32, string, interface{}) {
+	ctx := WithClaims(context.Background(), client.claims)
+	resp, err := r.chatService.SendMessageWithWS(ctx, &chat.SendMessageReq{
+		RoomId:       roomID,
+		Content:      data.Content,
+		MsgType:      data.MsgType,
+		ReplyToMsgId: data.ReplyToMsgID,
+		ThreadRootId: data.ThreadRootID,
+	})
+	if err != nil {
+		return utils.CodeServerError, "server error", nil
 	}
-
-	// 保存消息到数据库
-	msg := &model.Message{
-		MsgID:   utils.GenerateMsgID(),
-		RoomID:  roomID,
-		UserID:  userID,
-		Content: msgData.Content,
-		MsgType: msgData.MsgType,
+	if resp.Code != utils.CodeSuccess {
+		return resp.Code, resp.Message, nil
 	}
 
-	dao := dao.NewMessageDAO(dao.DB)
-	dao.Create(msg)
-
-	// 广播消息
-	GlobalWSManager.BroadcastToRoom(roomID, "message", msg)
+	// 斜杠命令的执行结果只回给发送者
+	result := map[string]interface{}{}
+	if resp.Msg != nil {
+		result["msg"] = resp.Msg
+	}
+	if resp.Notice != "" {
+		result["notice"] = resp.Notice
+	}
+	return resp.Code, resp.Message, result
 }
 
 // WSRouter WebSocket 路由
@@ -118,21 +318,317 @@ type WSRouter struct {
 }
 
 // NewWSRouter 创建 WebSocket 路由
-func NewWSRouter() *WSRouter {
+func NewWSRouter(svc *ChatServiceImpl) *WSRouter {
 	return &WSRouter{
-		chatService: NewChatService(),
+		chatService: svc,
 	}
 }
 
 // HandleConnection 处理 WebSocket 连接
 func (r *WSRouter) HandleConnection(w http.ResponseWriter, req *http.Request) {
-	// 从 query 参数获取 userID
-	userID := req.URL.Query().Get("user_id")
-	if userID == "" {
-		http.Error(w, "user_id required", http.StatusBadRequest)
+	// 浏览器无法为 WebSocket 设置请求头，令牌可通过 query 参数传递
+	token := req.URL.Query().Get("token")
+	if token == "" {
+		token = BearerToken(req.Header.Get("Authorization"))
+	}
+
+	claims, err := r.chatService.tokens.Verify(token)
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// 兼容旧客户端携带的 user_id，但必须与令牌一致
+	if userID := req.URL.Query().Get("user_id"); userID != "" && userID != claims.UserID {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
 		return
 	}
 
 	// 升级为 WebSocket
-	GlobalWSManager.HandleWebSocket(w, req, userID)
+	GlobalWSManager.HandleWebSocket(w, req, claims, r.HandleRequest)
+}
+
+// HandleRequest 分发客户端操作，每个操作都回复 ack 或 error 帧
+func (r *WSRouter) HandleRequest(client *WSClient, req *WSRequest) {
+	ctx := WithClaims(context.Background(), client.claims)
+
+	roomID := req.RoomID
+	if roomID == "" {
+		roomID = GlobalWSManager.FocusedRoom(client)
+	}
+
+	switch req.Op {
+	case OpJoin:
+		var data WSJoinData
+		if len(req.Data) > 0 {
+			if err := json.Unmarshal(req.Data, &data); err != nil {
+				client.replyError(req.ID, utils.CodeParamError, "invalid join data")
+				return
+			}
+		}
+		// 凭邀请码加入时以邀请链接所属的房间为准，不使用当前查看的房间
+		joinReq := &chat.JoinRoomReq{RoomId: roomID, InviteCode: data.InviteCode}
+		if data.InviteCode != "" {
+			joinReq.RoomId = req.RoomID
+		}
+		resp, err := r.chatService.JoinRoomWithWS(ctx, joinReq, client)
+		if err != nil {
+			client.replyError(req.ID, utils.CodeServerError, "server error")
+			return
+		}
+		roomID = joinReq.RoomId
+		// 已是房间成员时只订阅房间事件
+		if resp.Code == utils.CodeAlreadyInRoom {
+			GlobalWSManager.SubscribeRoom(client, roomID)
+		} else if resp.Code != utils.CodeSuccess {
+			client.replyError(req.ID, resp.Code, resp.Message)
+			return
+		}
+		client.reply(req.ID, utils.CodeSuccess, "success", map[string]interface{}{
+			"room_id":      roomID,
+			"online_count": GlobalWSManager.GetOnlineCount(roomID),
+		})
+
+	case OpLeave:
+		resp, err := r.chatService.LeaveRoomWithWS(ctx, &chat.LeaveRoomReq{RoomId: roomID})
+		if err != nil {
+			client.replyError(req.ID, utils.CodeServerError, "server error")
+			return
+		}
+		if resp.Code != utils.CodeSuccess {
+			client.replyError(req.ID, resp.Code, resp.Message)
+			return
+		}
+		GlobalWSManager.UnsubscribeRoom(client, roomID)
+		client.reply(req.ID, utils.CodeSuccess, "success", nil)
+
+	case OpSubscribe:
+		isMember, err := dao.NewRoomMemberDAO(dao.DB).IsMember(roomID, client.userID)
+		if err != nil {
+			client.replyError(req.ID, utils.CodeServerError, "database error")
+			return
+		}
+		if !isMember {
+			client.replyError(req.ID, utils.CodeNotInRoom, "not in room")
+			return
+		}
+		GlobalWSManager.SubscribeRoom(client, roomID)
+		client.reply(req.ID, utils.CodeSuccess, "success", map[string]interface{}{
+			"room_id":      roomID,
+			"online_count": GlobalWSManager.GetOnlineCount(roomID),
+		})
+
+	case OpResume:
+		var data WSResumeData
+		if len(req.Data) > 0 {
+			if err := json.Unmarshal(req.Data, &data); err != nil || data.LastSeq < 0 {
+				client.replyError(req.ID, utils.CodeParamError, "invalid resume data")
+				return
+			}
+		}
+		isMember, err := dao.NewRoomMemberDAO(dao.DB).IsMember(roomID, client.userID)
+		if err != nil {
+			client.replyError(req.ID, utils.CodeServerError, "database error")
+			return
+		}
+		if !isMember {
+			client.replyError(req.ID, utils.CodeNotInRoom, "not in room")
+			return
+		}
+		lastSeq, truncated, err := r.resume(client, roomID, data.LastSeq)
+		if err != nil {
+			client.replyError(req.ID, utils.CodeServerError, "database error")
+			return
+		}
+		client.reply(req.ID, utils.CodeSuccess, "success", map[string]interface{}{
+			"room_id":      roomID,
+			"last_seq":     lastSeq,
+			"truncated":    truncated,
+			"online_count": GlobalWSManager.GetOnlineCount(roomID),
+		})
+
+	case OpUnsubscribe:
+		GlobalWSManager.UnsubscribeRoom(client, roomID)
+		client.reply(req.ID, utils.CodeSuccess, "success", nil)
+
+	case OpFocus:
+		// room_id 为空表示不再查看任何房间
+		if req.RoomID != "" && !GlobalWSManager.IsSubscribed(client, req.RoomID) {
+			client.replyError(req.ID, utils.CodeNotInRoom, "not subscribed")
+			return
+		}
+		client.reply(req.ID, utils.CodeSuccess, "success", map[string]interface{}{
+			"unread": GlobalWSManager.FocusRoom(client, req.RoomID),
+		})
+
+	case OpSend:
+		var data WSSendData
+		if err := json.Unmarshal(req.Data, &data); err != nil || strings.TrimSpace(data.Content) == "" {
+			client.replyError(req.ID, utils.CodeParamError, "invalid send data")
+			return
+		}
+		if data.MsgType == 0 {
+			data.MsgType = 1
+		}
+		code, message, result := r.HandleWSMessage(client, roomID, &data)
+		client.reply(req.ID, code, message, result)
+
+	case OpEdit:
+		var data WSEditData
+		if err := json.Unmarshal(req.Data, &data); err != nil || data.MsgID == "" || strings.TrimSpace(data.Content) == "" {
+			client.replyError(req.ID, utils.CodeParamError, "invalid edit data")
+			return
+		}
+		resp, err := r.chatService.EditMessageWithWS(ctx, &chat.EditMessageReq{
+			MsgId:   data.MsgID,
+			Content: data.Content,
+		})
+		if err != nil {
+			client.replyError(req.ID, utils.CodeServerError, "server error")
+			return
+		}
+		if resp.Code != utils.CodeSuccess {
+			client.replyError(req.ID, resp.Code, resp.Message)
+			return
+		}
+		client.reply(req.ID, utils.CodeSuccess, "success", map[string]interface{}{
+			"msg": resp.Msg,
+		})
+
+	case OpReact, OpUnreact:
+		var data WSReactData
+		if err := json.Unmarshal(req.Data, &data); err != nil || data.MsgID == "" || data.Emoji == "" {
+			client.replyError(req.ID, utils.CodeParamError, "invalid reaction data")
+			return
+		}
+		var (
+			code      int32
+			message   string
+			reactions []*chat.ReactionInfo
+			err       error
+		)
+		if req.Op == OpReact {
+			var resp *chat.AddReactionResp
+			resp, err = r.chatService.AddReactionWithWS(ctx, &chat.AddReactionReq{MsgId: data.MsgID, Emoji: data.Emoji})
+			if resp != nil {
+				code, message, reactions = resp.Code, resp.Message, resp.Reactions
+			}
+		} else {
+			var resp *chat.RemoveReactionResp
+			resp, err = r.chatService.RemoveReactionWithWS(ctx, &chat.RemoveReactionReq{MsgId: data.MsgID, Emoji: data.Emoji})
+			if resp != nil {
+				code, message, reactions = resp.Code, resp.Message, resp.Reactions
+			}
+		}
+		if err != nil {
+			client.replyError(req.ID, utils.CodeServerError, "server error")
+			return
+		}
+		if code != utils.CodeSuccess {
+			client.replyError(req.ID, code, message)
+			return
+		}
+		client.reply(req.ID, utils.CodeSuccess, "success", map[string]interface{}{
+			"msg_id":    data.MsgID,
+			"reactions": reactions,
+		})
+
+	case OpRead:
+		var data WSReadData
+		if len(req.Data) > 0 {
+			if err := json.Unmarshal(req.Data, &data); err != nil || data.Seq < 0 {
+				client.replyError(req.ID, utils.CodeParamError, "invalid read data")
+				return
+			}
+		}
+		resp, err := r.chatService.MarkReadWithWS(ctx, &chat.MarkReadReq{
+			RoomId: roomID,
+			Seq:    data.Seq,
+		})
+		if err != nil {
+			client.replyError(req.ID, utils.CodeServerError, "server error")
+			return
+		}
+		if resp.Code != utils.CodeSuccess {
+			client.replyError(req.ID, resp.Code, resp.Message)
+			return
+		}
+		client.reply(req.ID, utils.CodeSuccess, "success", map[string]interface{}{
+			"room_id":       roomID,
+			"last_read_seq": resp.LastReadSeq,
+			"cursors":       resp.Cursors,
+		})
+
+	case OpTypingStart, OpTyping:
+		broadcast, ok := GlobalWSManager.StartTyping(client, roomID)
+		if !ok {
+			client.replyError(req.ID, utils.CodeNotInRoom, "not in room")
+			return
+		}
+		client.reply(req.ID, utils.CodeSuccess, "success", map[string]interface{}{
+			"throttled": !broadcast,
+		})
+
+	case OpTypingStop:
+		GlobalWSManager.StopTyping(roomID, client.userID)
+		client.reply(req.ID, utils.CodeSuccess, "success", nil)
+
+	case OpAck:
+		var data WSAckData
+		if err := json.Unmarshal(req.Data, &data); err != nil || data.MsgID == "" {
+			client.replyError(req.ID, utils.CodeParamError, "invalid ack data")
+			return
+		}
+		client.reply(req.ID, utils.CodeSuccess, "success", map[string]interface{}{
+			"msg_id": data.MsgID,
+		})
+
+	case OpPing:
+		client.reply(req.ID, utils.CodeSuccess, "pong", map[string]interface{}{
+			"time": time.Now().UnixMilli(),
+		})
+	}
+}
+
+// resume 订阅房间并按序号补发 afterSeq 之后的消息，补发完成后才恢复实时投递。
+// 返回已补发的最后一条序号，truncated 表示超出上限或发送队列已满，未补完
+func (r *WSRouter) resume(client *WSClient, roomID string, afterSeq int64) (int64, bool, error) {
+	messageDAO := dao.NewMessageDAO(dao.DB)
+	userDAO := dao.NewUserDAO(dao.DB)
+	users := make(map[string]*model.User)
+
+	return GlobalWSManager.Replay(client, roomID, afterSeq, func(afterSeq int64, limit int) ([]*WSMessage, error) {
+		messages, err := messageDAO.ListAfterSeq(roomID, afterSeq, limit)
+		if err != nil {
+			return nil, err
+		}
+
+		infos := make([]*chat.MessageInfo, len(messages))
+		for i, msg := range messages {
+			user, ok := users[msg.UserID]
+			if !ok && msg.UserID != "" {
+				user, _ = userDAO.GetByID(msg.UserID)
+				users[msg.UserID] = user
+			}
+			infos[i] = toMessageInfo(msg, user)
+		}
+		if err := attachReplyTo(infos); err != nil {
+			return nil, err
+		}
+		if err := attachReactions(infos, client.userID); err != nil {
+			return nil, err
+		}
+
+		frames := make([]*WSMessage, len(messages))
+		for i, msg := range messages {
+			frames[i] = &WSMessage{
+				Type:   EventMessage,
+				Seq:    msg.Seq,
+				RoomID: roomID,
+				UserID: msg.UserID,
+				Data:   infos[i],
+			}
+		}
+		return frames, nil
+	})
 }
@@ -0,0 +1,32 @@
+package service
+
+import "time"
+
+// Clock 时间来源，便于在测试中替换为可控时钟
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker 周期触发器
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// realClock 系统时钟
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+// realTicker 包装 time.Ticker
+type realTicker struct {
+	t *time.Ticker
+}
+
+func (r realTicker) C() <-chan time.Time { return r.t.C }
+func (r realTicker) Stop()               { r.t.Stop() }
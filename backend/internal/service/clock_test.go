@@ -0,0 +1,135 @@
+package service
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock 只在 Advance 时前进的时钟
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*fakeTicker
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Unix(1700000000, 0)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *fakeClock) NewTicker(d time.Duration) Ticker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := &fakeTicker{ch: make(chan time.Time, 1), period: d, next: c.now.Add(d)}
+	c.tickers = append(c.tickers, t)
+	return t
+}
+
+// Advance 拨快时钟，到期的 ticker 按周期触发，与 time.Ticker 一样来不及读取的触发被丢弃
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	now := c.now
+	tickers := append([]*fakeTicker(nil), c.tickers...)
+	c.mu.Unlock()
+
+	for _, t := range tickers {
+		t.fire(now)
+	}
+}
+
+// Tickers 返回未停止的 ticker 数量
+func (c *fakeClock) Tickers() int {
+	c.mu.Lock()
+	tickers := append([]*fakeTicker(nil), c.tickers...)
+	c.mu.Unlock()
+
+	active := 0
+	for _, t := range tickers {
+		t.mu.Lock()
+		if !t.stopped {
+			active++
+		}
+		t.mu.Unlock()
+	}
+	return active
+}
+
+// fakeTicker fakeClock 创建的 ticker
+type fakeTicker struct {
+	mu      sync.Mutex
+	ch      chan time.Time
+	period  time.Duration
+	next    time.Time
+	stopped bool
+}
+
+func (t *fakeTicker) C() <-chan time.Time { return t.ch }
+
+func (t *fakeTicker) Stop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.stopped = true
+}
+
+func (t *fakeTicker) fire(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for !t.stopped && !t.next.After(now) {
+		select {
+		case t.ch <- t.next:
+		default:
+		}
+		t.next = t.next.Add(t.period)
+	}
+}
+
+func TestFakeClock(t *testing.T) {
+	clock := newFakeClock()
+	start := clock.Now()
+	ticker := clock.NewTicker(10 * time.Second)
+
+	clock.Advance(9 * time.Second)
+	select {
+	case tick := <-ticker.C():
+		t.Fatalf("ticker fired early at %v", tick)
+	default:
+	}
+
+	clock.Advance(time.Second)
+	if tick := <-ticker.C(); !tick.Equal(start.Add(10 * time.Second)) {
+		t.Fatalf("tick = %v, want %v", tick, start.Add(10*time.Second))
+	}
+
+	// 未读取的触发只保留一个
+	clock.Advance(30 * time.Second)
+	if tick := <-ticker.C(); !tick.Equal(start.Add(20 * time.Second)) {
+		t.Fatalf("tick = %v, want %v", tick, start.Add(20*time.Second))
+	}
+	select {
+	case tick := <-ticker.C():
+		t.Fatalf("unexpected extra tick %v", tick)
+	default:
+	}
+
+	ticker.Stop()
+	clock.Advance(time.Minute)
+	select {
+	case tick := <-ticker.C():
+		t.Fatalf("stopped ticker fired at %v", tick)
+	default:
+	}
+	if clock.Tickers() != 0 {
+		t.Fatalf("active tickers = %d, want 0", clock.Tickers())
+	}
+}
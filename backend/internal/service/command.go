@@ -0,0 +1,447 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/baijianruoli/bot_chat/backend/internal/dao"
+	"github.com/baijianruoli/bot_chat/backend/internal/model"
+	"github.com/baijianruoli/bot_chat/backend/internal/utils"
+	chat "github.com/baijianruoli/bot_chat/backend/kitex_gen/chat"
+)
+
+// CommandContext 命令执行上下文
+type CommandContext struct {
+	Ctx    context.Context
+	Svc    *ChatServiceImpl
+	Room   *model.Room
+	UserID string
+	Role   string   // 执行者在房间中的角色
+	Name   string   // 不含前导 /
+	Args   []string // 解析后的参数，支持双引号
+	Raw    string   // 命令名之后的原始文本
+}
+
+// CommandResult 命令执行结果
+type CommandResult struct {
+	Notice string         // 只回给发送者的提示
+	Msg    *model.Message // 需要保存并广播的消息，可为空
+}
+
+// Command 斜杠命令
+type Command struct {
+	Name        string
+	Usage       string // 例如 "/kick <user_id>"
+	Description string
+	MinArgs     int
+	// Permission 为空表示房间成员均可执行，通常由 requirePermission 构造
+	Permission func(cc *CommandContext) bool
+	Handler    func(cc *CommandContext) (*CommandResult, error)
+}
+
+// CommandRegistry 命令注册表
+type CommandRegistry struct {
+	mu       sync.RWMutex
+	commands map[string]*Command
+}
+
+// NewCommandRegistry 创建命令注册表
+func NewCommandRegistry() *CommandRegistry {
+	return &CommandRegistry{
+		commands: make(map[string]*Command),
+	}
+}
+
+// Register 注册命令，重名时返回错误
+func (r *CommandRegistry) Register(cmd *Command) error {
+	name := strings.ToLower(cmd.Name)
+	if name == "" || cmd.Handler == nil {
+		return fmt.Errorf("invalid command %q", cmd.Name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.commands[name]; ok {
+		return fmt.Errorf("command /%s already registered", name)
+	}
+	r.commands[name] = cmd
+	return nil
+}
+
+// Unregister 注销命令
+func (r *CommandRegistry) Unregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.commands, strings.ToLower(name))
+}
+
+// Lookup 查找命令
+func (r *CommandRegistry) Lookup(name string) (*Command, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	cmd, ok := r.commands[strings.ToLower(name)]
+	return cmd, ok
+}
+
+// List 按名称排序返回全部命令
+func (r *CommandRegistry) List() []*Command {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	cmds := make([]*Command, 0, len(r.commands))
+	for _, cmd := range r.commands {
+		cmds = append(cmds, cmd)
+	}
+	sort.Slice(cmds, func(i, j int) bool {
+		return cmds[i].Name < cmds[j].Name
+	})
+	return cmds
+}
+
+// IsCommand 判断消息是否为斜杠命令（// 开头视为转义的普通消息）
+func IsCommand(content string) bool {
+	return strings.HasPrefix(content, "/") && !strings.HasPrefix(content, "//") && len(content) > 1
+}
+
+// ParseCommand 解析命令名和参数，双引号内的空白不分隔参数
+func ParseCommand(content string) (name string, args []string, raw string) {
+	body := strings.TrimPrefix(content, "/")
+	idx := strings.IndexFunc(body, unicode.IsSpace)
+	if idx < 0 {
+		return strings.ToLower(body), nil, ""
+	}
+	name, raw = strings.ToLower(body[:idx]), strings.TrimSpace(body[idx:])
+
+	var current strings.Builder
+	inQuote, hasToken := false, false
+	for _, r := range raw {
+		switch {
+		case r == '"':
+			inQuote = !inQuote
+			hasToken = true
+		case unicode.IsSpace(r) && !inQuote:
+			if hasToken {
+				args = append(args, current.String())
+				current.Reset()
+				hasToken = false
+			}
+		default:
+			current.WriteRune(r)
+			hasToken = true
+		}
+	}
+	if hasToken {
+		args = append(args, current.String())
+	}
+	return name, args, raw
+}
+
+// execCommand 执行斜杠命令，命令本身不入库
+func (s *ChatServiceImpl) execCommand(ctx context.Context, access *roomAccess, content string) *chat.SendMessageResp {
+	name, args, raw := ParseCommand(content)
+
+	cmd, ok := s.commands.Lookup(name)
+	if !ok {
+		return &chat.SendMessageResp{
+			Code:    utils.CodeParamError,
+			Message: fmt.Sprintf("unknown command /%s, try /help", name),
+		}
+	}
+
+	cc := &CommandContext{
+		Ctx:    ctx,
+		Svc:    s,
+		Room:   access.Room,
+		UserID: access.UserID,
+		Role:   access.Role,
+		Name:   name,
+		Args:   args,
+		Raw:    raw,
+	}
+	if cmd.Permission != nil && !cmd.Permission(cc) {
+		return &chat.SendMessageResp{
+			Code:    utils.CodeUnauthorized,
+			Message: "permission denied: /" + name,
+		}
+	}
+	if len(args) < cmd.MinArgs {
+		return &chat.SendMessageResp{
+			Code:    utils.CodeParamError,
+			Message: "usage: " + cmd.Usage,
+		}
+	}
+
+	result, err := cmd.Handler(cc)
+	if err != nil {
+		return &chat.SendMessageResp{
+			Code:    utils.CodeServerError,
+			Message: err.Error(),
+		}
+	}
+
+	resp := &chat.SendMessageResp{
+		Code:    utils.CodeSuccess,
+		Message: "success",
+	}
+	if result == nil {
+		return resp
+	}
+	resp.Notice = result.Notice
+
+	if result.Msg != nil {
+		if err := dao.NewMessageDAO(dao.DB).Create(result.Msg); err != nil {
+			return &chat.SendMessageResp{
+				Code:    utils.CodeServerError,
+				Message: "failed to send message",
+			}
+		}
+		user, _ := dao.NewUserDAO(dao.DB).GetByID(result.Msg.UserID)
+		resp.Msg = toMessageInfo(result.Msg, user)
+	}
+	return resp
+}
+
+// requirePermission 要求执行者的角色具备 perm
+func requirePermission(perm Permission) func(cc *CommandContext) bool {
+	return func(cc *CommandContext) bool {
+		return cc.Room != nil && roleCan(cc.Room, cc.Role, perm)
+	}
+}
+
+// systemMessage 构造一条由 userID 触发的系统消息
+func systemMessage(roomID, userID, content string) *model.Message {
+	return &model.Message{
+		MsgID:   utils.GenerateMsgID(),
+		RoomID:  roomID,
+		UserID:  userID,
+		Content: content,
+		MsgType: 3,
+	}
+}
+
+// displayName 用户显示名
+func displayName(userID string) string {
+	user, err := dao.NewUserDAO(dao.DB).GetByID(userID)
+	if err != nil || user == nil {
+		return userID
+	}
+	if user.Nickname != "" {
+		return user.Nickname
+	}
+	return user.Username
+}
+
+// evictMember 将成员移出房间并通知房间，reason 为 kicked 或 banned
+func evictMember(roomID, userID, reason string) error {
+	if err := dao.NewRoomMemberDAO(dao.DB).RemoveMember(roomID, userID); err != nil {
+		return err
+	}
+	dao.NewRoomDAO(dao.DB).UpdateUserCount(roomID, -1)
+	GlobalWSManager.UnsubscribeUser(userID, roomID)
+
+	GlobalWSManager.BroadcastToRoom(roomID, EventLeave, map[string]interface{}{
+		"user_id": userID,
+		reason:    true,
+	})
+	return nil
+}
+
+// registerBuiltinCommands 注册内置命令
+func registerBuiltinCommands(r *CommandRegistry) {
+	r.Register(&Command{
+		Name:        "help",
+		Usage:       "/help [command]",
+		Description: "列出可用命令",
+		Handler: func(cc *CommandContext) (*CommandResult, error) {
+			if len(cc.Args) > 0 {
+				cmd, ok := r.Lookup(strings.TrimPrefix(cc.Args[0], "/"))
+				if !ok {
+					return &CommandResult{Notice: "unknown command " + cc.Args[0]}, nil
+				}
+				return &CommandResult{Notice: cmd.Usage + "  " + cmd.Description}, nil
+			}
+
+			var lines []string
+			for _, cmd := range r.List() {
+				if cmd.Permission == nil || cmd.Permission(cc) {
+					lines = append(lines, cmd.Usage+"  "+cmd.Description)
+				}
+			}
+			return &CommandResult{Notice: strings.Join(lines, "\n")}, nil
+		},
+	})
+
+	r.Register(&Command{
+		Name:        "me",
+		Usage:       "/me <action>",
+		Description: "以第三人称描述动作",
+		MinArgs:     1,
+		Permission:  requirePermission(PermSend),
+		Handler: func(cc *CommandContext) (*CommandResult, error) {
+			content := "* " + displayName(cc.UserID) + " " + cc.Raw
+			return &CommandResult{Msg: systemMessage(cc.Room.RoomID, cc.UserID, content)}, nil
+		},
+	})
+
+	r.Register(&Command{
+		Name:        "topic",
+		Usage:       "/topic <text>",
+		Description: "修改房间话题",
+		MinArgs:     1,
+		Permission:  requirePermission(PermEditRoom),
+		Handler: func(cc *CommandContext) (*CommandResult, error) {
+			if err := dao.NewRoomDAO(dao.DB).UpdateDescription(cc.Room.RoomID, cc.Raw); err != nil {
+				return nil, fmt.Errorf("failed to update topic")
+			}
+			GlobalWSManager.BroadcastToRoom(cc.Room.RoomID, EventTopic, map[string]interface{}{
+				"user_id": cc.UserID,
+				"topic":   cc.Raw,
+			})
+			content := displayName(cc.UserID) + " 将话题修改为：" + cc.Raw
+			return &CommandResult{Msg: systemMessage(cc.Room.RoomID, cc.UserID, content)}, nil
+		},
+	})
+
+	r.Register(&Command{
+		Name:        "kick",
+		Usage:       "/kick <user_id>",
+		Description: "将成员移出房间",
+		MinArgs:     1,
+		Permission:  requirePermission(PermKick),
+		Handler: func(cc *CommandContext) (*CommandResult, error) {
+			targetID := cc.Args[0]
+			if targetID == cc.UserID {
+				return &CommandResult{Notice: "you cannot kick yourself"}, nil
+			}
+
+			roomMemberDAO := dao.NewRoomMemberDAO(dao.DB)
+			target, err := roomMemberDAO.GetMember(cc.Room.RoomID, targetID)
+			if err != nil {
+				return nil, fmt.Errorf("database error")
+			}
+			if target == nil {
+				return &CommandResult{Notice: targetID + " is not in this room"}, nil
+			}
+			if roleRanks[target.Role] >= roleRanks[cc.Role] {
+				return &CommandResult{Notice: "you cannot kick a " + target.Role}, nil
+			}
+			if err := evictMember(cc.Room.RoomID, targetID, "kicked"); err != nil {
+				return nil, fmt.Errorf("failed to kick member")
+			}
+			content := displayName(targetID) + " 被 " + displayName(cc.UserID) + " 移出房间"
+			return &CommandResult{Msg: systemMessage(cc.Room.RoomID, cc.UserID, content)}, nil
+		},
+	})
+
+	r.Register(&Command{
+		Name:        "ban",
+		Usage:       "/ban <user_id>",
+		Description: "将用户移出房间并禁止重新加入",
+		MinArgs:     1,
+		Permission:  requirePermission(PermBan),
+		Handler: func(cc *CommandContext) (*CommandResult, error) {
+			targetID := cc.Args[0]
+			if targetID == cc.UserID {
+				return &CommandResult{Notice: "you cannot ban yourself"}, nil
+			}
+			user, err := dao.NewUserDAO(dao.DB).GetByID(targetID)
+			if err != nil {
+				return nil, fmt.Errorf("database error")
+			}
+			if user == nil {
+				return &CommandResult{Notice: "user " + targetID + " not found"}, nil
+			}
+
+			// 不在房间中的用户也可以预先封禁
+			target, err := dao.NewRoomMemberDAO(dao.DB).GetMember(cc.Room.RoomID, targetID)
+			if err != nil {
+				return nil, fmt.Errorf("database error")
+			}
+			if target != nil && roleRanks[target.Role] >= roleRanks[cc.Role] {
+				return &CommandResult{Notice: "you cannot ban a " + target.Role}, nil
+			}
+			if err := dao.NewRoomBanDAO(dao.DB).Ban(cc.Room.RoomID, targetID, cc.UserID); err != nil {
+				return nil, fmt.Errorf("failed to ban user")
+			}
+			if target != nil {
+				if err := evictMember(cc.Room.RoomID, targetID, "banned"); err != nil {
+					return nil, fmt.Errorf("failed to remove banned member")
+				}
+			}
+			content := displayName(targetID) + " 被 " + displayName(cc.UserID) + " 封禁"
+			return &CommandResult{Msg: systemMessage(cc.Room.RoomID, cc.UserID, content)}, nil
+		},
+	})
+
+	r.Register(&Command{
+		Name:        "unban",
+		Usage:       "/unban <user_id>",
+		Description: "解除封禁，用户可重新加入房间",
+		MinArgs:     1,
+		Permission:  requirePermission(PermBan),
+		Handler: func(cc *CommandContext) (*CommandResult, error) {
+			targetID := cc.Args[0]
+			unbanned, err := dao.NewRoomBanDAO(dao.DB).Unban(cc.Room.RoomID, targetID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to unban user")
+			}
+			if !unbanned {
+				return &CommandResult{Notice: targetID + " is not banned"}, nil
+			}
+			content := displayName(cc.UserID) + " 解除了对 " + displayName(targetID) + " 的封禁"
+			return &CommandResult{Msg: systemMessage(cc.Room.RoomID, cc.UserID, content)}, nil
+		},
+	})
+
+	r.Register(&Command{
+		Name:        "invite",
+		Usage:       "/invite <user_id>",
+		Description: "邀请用户加入房间",
+		MinArgs:     1,
+		Permission:  requirePermission(PermInvite),
+		Handler: func(cc *CommandContext) (*CommandResult, error) {
+			targetID := cc.Args[0]
+			target, err := dao.NewUserDAO(dao.DB).GetByID(targetID)
+			if err != nil {
+				return nil, fmt.Errorf("database error")
+			}
+			if target == nil {
+				return &CommandResult{Notice: "user " + targetID + " not found"}, nil
+			}
+
+			roomMemberDAO := dao.NewRoomMemberDAO(dao.DB)
+			isMember, err := roomMemberDAO.IsMember(cc.Room.RoomID, targetID)
+			if err != nil {
+				return nil, fmt.Errorf("database error")
+			}
+			if isMember {
+				return &CommandResult{Notice: targetID + " is already in this room"}, nil
+			}
+			banned, err := dao.NewRoomBanDAO(dao.DB).IsBanned(cc.Room.RoomID, targetID)
+			if err != nil {
+				return nil, fmt.Errorf("database error")
+			}
+			if banned {
+				return &CommandResult{Notice: targetID + " is banned from this room"}, nil
+			}
+			if err := roomMemberDAO.AddMember(cc.Room.RoomID, targetID); err != nil {
+				return nil, fmt.Errorf("failed to invite user")
+			}
+			dao.NewRoomDAO(dao.DB).UpdateUserCount(cc.Room.RoomID, 1)
+
+			GlobalWSManager.BroadcastToRoom(cc.Room.RoomID, EventJoin, map[string]interface{}{
+				"user_id":    targetID,
+				"invited_by": cc.UserID,
+			})
+			content := displayName(cc.UserID) + " 邀请 " + displayName(targetID) + " 加入房间"
+			return &CommandResult{Msg: systemMessage(cc.Room.RoomID, cc.UserID, content)}, nil
+		},
+	})
+}
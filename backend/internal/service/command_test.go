@@ -0,0 +1,62 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIsCommand(t *testing.T) {
+	tests := []struct {
+		content string
+		want    bool
+	}{
+		{"/help", true},
+		{"/kick u_1", true},
+		{"/", false},
+		{"//not a command", false},
+		{"hello /help", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := IsCommand(tt.content); got != tt.want {
+			t.Errorf("IsCommand(%q) = %v, want %v", tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestParseCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		wantName string
+		wantArgs []string
+		wantRaw  string
+	}{
+		{"no args", "/help", "help", nil, ""},
+		{"uppercase name", "/HeLp", "help", nil, ""},
+		{"plain args", "/kick u_1 spam", "kick", []string{"u_1", "spam"}, "u_1 spam"},
+		{"extra whitespace", "/kick   u_1 \t spam  ", "kick", []string{"u_1", "spam"}, "u_1 \t spam"},
+		{"newline separator", "/topic\nhello", "topic", []string{"hello"}, "hello"},
+		{"quoted arg", `/topic "hello world" now`, "topic", []string{"hello world", "now"}, `"hello world" now`},
+		{"quote inside word", `/say a"b c"d`, "say", []string{"ab cd"}, `a"b c"d`},
+		{"empty quotes", `/topic "" x`, "topic", []string{"", "x"}, `"" x`},
+		{"unterminated quote", `/topic "hello world`, "topic", []string{"hello world"}, `"hello world`},
+		{"unterminated quote after args", `/say a "b  c`, "say", []string{"a", "b  c"}, `a "b  c`},
+		{"lone quote", `/say "`, "say", []string{""}, `"`},
+		{"unicode args", "/say 你好 世界", "say", []string{"你好", "世界"}, "你好 世界"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, args, raw := ParseCommand(tt.content)
+			if name != tt.wantName {
+				t.Errorf("name = %q, want %q", name, tt.wantName)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("args = %q, want %q", args, tt.wantArgs)
+			}
+			if raw != tt.wantRaw {
+				t.Errorf("raw = %q, want %q", raw, tt.wantRaw)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package service
+
+import (
+	"context"
+
+	"github.com/baijianruoli/bot_chat/backend/internal/dao"
+	"github.com/baijianruoli/bot_chat/backend/internal/model"
+	"github.com/baijianruoli/bot_chat/backend/internal/utils"
+	chat "github.com/baijianruoli/bot_chat/backend/kitex_gen/chat"
+)
+
+// OpenDirectChat 打开与某个用户的私聊，房间ID由双方用户ID确定，重复调用返回同一房间
+func (s *ChatServiceImpl) OpenDirectChat(ctx context.Context, req *chat.OpenDirectChatReq) (*chat.OpenDirectChatResp, error) {
+	userID, ok := authorize(ctx, req.UserId)
+	if !ok {
+		return &chat.OpenDirectChatResp{
+			Code:    utils.CodeUnauthorized,
+			Message: "unauthorized",
+		}, nil
+	}
+
+	if req.PeerUserId == "" || req.PeerUserId == userID {
+		return &chat.OpenDirectChatResp{
+			Code:    utils.CodeParamError,
+			Message: "invalid peer_user_id",
+		}, nil
+	}
+
+	peer, err := dao.NewUserDAO(dao.DB).GetByID(req.PeerUserId)
+	if err != nil {
+		return &chat.OpenDirectChatResp{
+			Code:    utils.CodeServerError,
+			Message: "database error",
+		}, nil
+	}
+	if peer == nil {
+		return &chat.OpenDirectChatResp{
+			Code:    utils.CodeNotFound,
+			Message: "user not found",
+		}, nil
+	}
+
+	// 私聊房间没有名称，客户端显示对方昵称
+	room := &model.Room{
+		RoomID:     utils.DirectRoomID(userID, peer.UserID),
+		CreatorID:  userID,
+		UserCount:  2,
+		Kind:       model.RoomKindDirect,
+		Visibility: model.RoomVisibilityPrivate,
+	}
+	joined, err := dao.NewRoomDAO(dao.DB).OpenDirect(room, userID, peer.UserID)
+	if err != nil {
+		return &chat.OpenDirectChatResp{
+			Code:    utils.CodeServerError,
+			Message: "failed to open direct chat",
+		}, nil
+	}
+
+	// 广播新成员加入，机器人据此得知自己所在的私聊
+	for _, memberID := range joined {
+		GlobalWSManager.BroadcastToRoom(room.RoomID, EventJoin, map[string]interface{}{
+			"user_id": memberID,
+		})
+	}
+
+	chats, err := directChatInfos(userID, []*model.Room{room})
+	if err != nil {
+		return &chat.OpenDirectChatResp{
+			Code:    utils.CodeServerError,
+			Message: "database error",
+		}, nil
+	}
+
+	return &chat.OpenDirectChatResp{
+		Code:    utils.CodeSuccess,
+		Message: "success",
+		Chat:    chats[0],
+	}, nil
+}
+
+// ListDirectChats 获取用户的私聊列表，附带最后一条消息与未读数，最近活跃的在前
+func (s *ChatServiceImpl) ListDirectChats(ctx context.Context, req *chat.ListDirectChatsReq) (*chat.ListDirectChatsResp, error) {
+	userID, ok := authorize(ctx, req.UserId)
+	if !ok {
+		return &chat.ListDirectChatsResp{
+			Code:    utils.CodeUnauthorized,
+			Message: "unauthorized",
+		}, nil
+	}
+
+	rooms, err := dao.NewRoomDAO(dao.DB).ListDirectByUser(userID)
+	if err != nil {
+		return &chat.ListDirectChatsResp{
+			Code:    utils.CodeServerError,
+			Message: "database error",
+		}, nil
+	}
+
+	chats, err := directChatInfos(userID, rooms)
+	if err != nil {
+		return &chat.ListDirectChatsResp{
+			Code:    utils.CodeServerError,
+			Message: "database error",
+		}, nil
+	}
+
+	return &chat.ListDirectChatsResp{
+		Code:    utils.CodeSuccess,
+		Message: "success",
+		Chats:   chats,
+	}, nil
+}
+
+// directChatInfos 组装私聊会话：对方用户、最后一条消息与 userID 的未读数
+func directChatInfos(userID string, rooms []*model.Room) ([]*chat.DirectChatInfo, error) {
+	roomIDs := make([]string, len(rooms))
+	for i, room := range rooms {
+		roomIDs[i] = room.RoomID
+	}
+
+	lastMessages, err := dao.NewMessageDAO(dao.DB).LastMessages(roomIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	roomMemberDAO := dao.NewRoomMemberDAO(dao.DB)
+	counts, err := roomMemberDAO.UnreadCounts(userID)
+	if err != nil {
+		return nil, err
+	}
+	unread := make(map[string]int64, len(counts))
+	for _, count := range counts {
+		unread[count.RoomID] = count.Count
+	}
+
+	userDAO := dao.NewUserDAO(dao.DB)
+	users := make(map[string]*model.User)
+	lookup := func(id string) *model.User {
+		user, ok := users[id]
+		if !ok {
+			user, _ = userDAO.GetByID(id)
+			users[id] = user
+		}
+		return user
+	}
+
+	chats := make([]*chat.DirectChatInfo, len(rooms))
+	for i, room := range rooms {
+		members, err := roomMemberDAO.GetMembers(room.RoomID)
+		if err != nil {
+			return nil, err
+		}
+
+		info := &chat.DirectChatInfo{
+			Room: &chat.RoomInfo{
+				RoomId:      room.RoomID,
+				Name:        room.Name,
+				Description: room.Description,
+				CreatorId:   room.CreatorID,
+				UserCount:   room.UserCount,
+				CreatedAt:   room.CreatedAt,
+				Visibility:  room.Visibility,
+			},
+			UnreadCount: unread[room.RoomID],
+		}
+		for _, memberID := range members {
+			if memberID == userID {
+				continue
+			}
+			if peer := lookup(memberID); peer != nil {
+				info.Peer = &chat.UserInfo{
+					UserId:    peer.UserID,
+					Username:  peer.Username,
+					Nickname:  peer.Nickname,
+					Avatar:    peer.Avatar,
+					IsBot:     peer.IsBot(),
+					CreatedAt: peer.CreatedAt,
+				}
+			}
+		}
+		if msg, ok := lastMessages[room.RoomID]; ok {
+			info.LastMessage = toMessageInfo(msg, lookup(msg.UserID))
+		}
+		chats[i] = info
+	}
+	return chats, nil
+}
@@ -0,0 +1,854 @@
+package service
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/baijianruoli/bot_chat/backend/internal/utils"
+	chat "github.com/baijianruoli/bot_chat/backend/kitex_gen/chat"
+)
+
+// HTTPGateway 将前端调用的 REST 接口映射到 ChatServiceImpl
+type HTTPGateway struct {
+	svc *ChatServiceImpl
+	mux *http.ServeMux
+}
+
+// NewHTTPGateway 创建 HTTP 网关
+func NewHTTPGateway(svc *ChatServiceImpl) *HTTPGateway {
+	g := &HTTPGateway{
+		svc: svc,
+		mux: http.NewServeMux(),
+	}
+
+	g.mux.HandleFunc("/api/register", g.handleRegister)
+	g.mux.HandleFunc("/api/login", g.handleLogin)
+	g.mux.HandleFunc("/api/token/refresh", g.handleRefreshToken)
+	g.mux.HandleFunc("/api/logout", g.auth(g.handleLogout))
+	g.mux.HandleFunc("/api/rooms", g.auth(g.handleRooms))
+	g.mux.HandleFunc("/api/rooms/", g.auth(g.handleRoomAction))
+	g.mux.HandleFunc("/api/invites/", g.auth(g.handleInvite))
+	g.mux.HandleFunc("/api/join-requests/", g.auth(g.handleJoinRequest))
+	g.mux.HandleFunc("/api/direct-chats", g.auth(g.handleDirectChats))
+	g.mux.HandleFunc("/api/unread", g.auth(g.handleUnread))
+	g.mux.HandleFunc("/api/messages", g.auth(g.handleMessages))
+	g.mux.HandleFunc("/api/messages/", g.auth(g.handleMessage))
+	g.mux.HandleFunc("/api/presence", g.auth(g.handlePresence))
+	g.mux.HandleFunc("/api/bots", g.auth(g.handleBots))
+	g.mux.HandleFunc("/api/webhooks/outgoing", g.auth(g.handleOutgoingWebhooks))
+	g.mux.HandleFunc("/api/webhooks/outgoing/", g.auth(g.handleOutgoingWebhook))
+	g.mux.HandleFunc("/api/webhooks/incoming", g.auth(g.handleIncomingWebhooks))
+	g.mux.HandleFunc("/api/webhooks/incoming/", g.auth(g.handleIncomingWebhook))
+
+	// 传入 Webhook 凭 URL 中的令牌认证
+	g.mux.HandleFunc("/api/hooks/", g.handlePostIncomingWebhook)
+
+	return g
+}
+
+// auth 要求请求携带有效的 Bearer 令牌
+func (g *HTTPGateway) auth(next http.HandlerFunc) http.HandlerFunc {
+	return requireAuth(g.svc.tokens, next)
+}
+
+// ServeHTTP 实现 http.Handler
+func (g *HTTPGateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.mux.ServeHTTP(w, r)
+}
+
+// handleRegister POST /api/register
+func (g *HTTPGateway) handleRegister(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	var req chat.RegisterReq
+	if err := decodeBody(r, &req); err != nil || req.Username == "" || req.Password == "" {
+		writeJSON(w, http.StatusBadRequest, utils.Error(utils.CodeParamError, "username and password required"))
+		return
+	}
+
+	resp, err := g.svc.Register(r.Context(), &req)
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+	writeResult(w, resp.Code, resp.Message, map[string]interface{}{
+		"user_id": resp.UserId,
+	})
+}
+
+// handleLogin POST /api/login
+func (g *HTTPGateway) handleLogin(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	var req chat.LoginReq
+	if err := decodeBody(r, &req); err != nil || req.Username == "" || req.Password == "" {
+		writeJSON(w, http.StatusBadRequest, utils.Error(utils.CodeParamError, "username and password required"))
+		return
+	}
+
+	resp, err := g.svc.Login(r.Context(), &req)
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+	writeResult(w, resp.Code, resp.Message, map[string]interface{}{
+		"token":         resp.Token,
+		"refresh_token": resp.RefreshToken,
+		"expires_at":    resp.ExpiresAt,
+		"user":          resp.User,
+	})
+}
+
+// handleRefreshToken POST /api/token/refresh
+func (g *HTTPGateway) handleRefreshToken(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	var req chat.RefreshTokenReq
+	if err := decodeBody(r, &req); err != nil || req.RefreshToken == "" {
+		writeJSON(w, http.StatusBadRequest, utils.Error(utils.CodeParamError, "refresh_token required"))
+		return
+	}
+
+	resp, err := g.svc.RefreshToken(r.Context(), &req)
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+	writeResult(w, resp.Code, resp.Message, map[string]interface{}{
+		"token":         resp.Token,
+		"refresh_token": resp.RefreshToken,
+		"expires_at":    resp.ExpiresAt,
+	})
+}
+
+// handleLogout POST /api/logout
+func (g *HTTPGateway) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	resp, err := g.svc.Logout(r.Context(), &chat.LogoutReq{})
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+	writeResult(w, resp.Code, resp.Message, nil)
+}
+
+// handleRooms GET/POST /api/rooms
+func (g *HTTPGateway) handleRooms(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		query := r.URL.Query()
+		resp, err := g.svc.ListRooms(r.Context(), &chat.ListRoomsReq{
+			Page:     int32(queryInt(query.Get("page"))),
+			PageSize: int32(queryInt(query.Get("page_size"))),
+		})
+		if err != nil {
+			writeServerError(w, err)
+			return
+		}
+		writeResult(w, resp.Code, resp.Message, map[string]interface{}{
+			"rooms": resp.Rooms,
+			"total": resp.Total,
+		})
+
+	case http.MethodPost:
+		var req chat.CreateRoomReq
+		if err := decodeBody(r, &req); err != nil || req.Name == "" {
+			writeJSON(w, http.StatusBadRequest, utils.Error(utils.CodeParamError, "room name required"))
+			return
+		}
+
+		resp, err := g.svc.CreateRoom(r.Context(), &req)
+		if err != nil {
+			writeServerError(w, err)
+			return
+		}
+		writeResult(w, resp.Code, resp.Message, map[string]interface{}{
+			"room": resp.Room,
+		})
+
+	default:
+		allowMethod(w, r, http.MethodGet, http.MethodPost)
+	}
+}
+
+// handleRoomAction POST /api/rooms/:id/{join,leave,read,invites,promote,demote,transfer} 与 GET /api/rooms/:id/join-requests
+func (g *HTTPGateway) handleRoomAction(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/rooms/"), "/"), "/")
+	if len(parts) != 2 || parts[0] == "" {
+		http.NotFound(w, r)
+		return
+	}
+	method := http.MethodPost
+	if parts[1] == "join-requests" {
+		method = http.MethodGet
+	}
+	if !allowMethod(w, r, method) {
+		return
+	}
+
+	roomID := parts[0]
+
+	switch parts[1] {
+	case "join":
+		// 请求体可省略，需审批的房间凭 invite_code 直接加入
+		var req chat.JoinRoomReq
+		if r.ContentLength != 0 {
+			if err := decodeBody(r, &req); err != nil {
+				writeJSON(w, http.StatusBadRequest, utils.Error(utils.CodeParamError, "invalid request body"))
+				return
+			}
+		}
+		req.RoomId = roomID
+
+		resp, err := g.svc.JoinRoomWithWS(r.Context(), &req, nil)
+		if err != nil {
+			writeServerError(w, err)
+			return
+		}
+		writeResult(w, resp.Code, resp.Message, map[string]interface{}{
+			"room":    resp.Room,
+			"request": resp.Request,
+		})
+
+	case "invites":
+		var req chat.CreateRoomInviteReq
+		if r.ContentLength != 0 {
+			if err := decodeBody(r, &req); err != nil {
+				writeJSON(w, http.StatusBadRequest, utils.Error(utils.CodeParamError, "invalid request body"))
+				return
+			}
+		}
+		req.RoomId = roomID
+
+		resp, err := g.svc.CreateRoomInvite(r.Context(), &req)
+		if err != nil {
+			writeServerError(w, err)
+			return
+		}
+		writeResult(w, resp.Code, resp.Message, map[string]interface{}{
+			"invite": resp.Invite,
+		})
+
+	case "join-requests":
+		resp, err := g.svc.ListJoinRequests(r.Context(), &chat.ListJoinRequestsReq{
+			RoomId: roomID,
+		})
+		if err != nil {
+			writeServerError(w, err)
+			return
+		}
+		writeResult(w, resp.Code, resp.Message, map[string]interface{}{
+			"requests": resp.Requests,
+		})
+
+	case "promote", "demote":
+		var req chat.PromoteMemberReq
+		if err := decodeBody(r, &req); err != nil {
+			writeJSON(w, http.StatusBadRequest, utils.Error(utils.CodeParamError, "invalid request body"))
+			return
+		}
+		req.RoomId = roomID
+
+		var code int32
+		var message string
+		var member *chat.RoomMemberInfo
+		if parts[1] == "promote" {
+			resp, err := g.svc.PromoteMemberWithWS(r.Context(), &req)
+			if err != nil {
+				writeServerError(w, err)
+				return
+			}
+			code, message, member = resp.Code, resp.Message, resp.Member
+		} else {
+			resp, err := g.svc.DemoteMemberWithWS(r.Context(), &chat.DemoteMemberReq{
+				RoomId:       roomID,
+				TargetUserId: req.TargetUserId,
+				Role:         req.Role,
+			})
+			if err != nil {
+				writeServerError(w, err)
+				return
+			}
+			code, message, member = resp.Code, resp.Message, resp.Member
+		}
+		writeResult(w, code, message, map[string]interface{}{
+			"member": member,
+		})
+
+	case "transfer":
+		var req chat.TransferOwnershipReq
+		if err := decodeBody(r, &req); err != nil {
+			writeJSON(w, http.StatusBadRequest, utils.Error(utils.CodeParamError, "invalid request body"))
+			return
+		}
+		req.RoomId = roomID
+
+		resp, err := g.svc.TransferOwnershipWithWS(r.Context(), &req)
+		if err != nil {
+			writeServerError(w, err)
+			return
+		}
+		writeResult(w, resp.Code, resp.Message, map[string]interface{}{
+			"members": resp.Members,
+		})
+
+	case "leave":
+		resp, err := g.svc.LeaveRoomWithWS(r.Context(), &chat.LeaveRoomReq{
+			RoomId: roomID,
+		})
+		if err != nil {
+			writeServerError(w, err)
+			return
+		}
+		writeResult(w, resp.Code, resp.Message, nil)
+
+	case "read":
+		// 请求体可省略，省略时标记到房间最新一条
+		var req chat.MarkReadReq
+		if r.ContentLength != 0 {
+			if err := decodeBody(r, &req); err != nil || req.Seq < 0 {
+				writeJSON(w, http.StatusBadRequest, utils.Error(utils.CodeParamError, "invalid seq"))
+				return
+			}
+		}
+		req.RoomId = roomID
+
+		resp, err := g.svc.MarkReadWithWS(r.Context(), &req)
+		if err != nil {
+			writeServerError(w, err)
+			return
+		}
+		writeResult(w, resp.Code, resp.Message, map[string]interface{}{
+			"last_read_seq": resp.LastReadSeq,
+			"cursors":       resp.Cursors,
+		})
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// handleInvite POST /api/invites/:code 凭邀请码加入房间，DELETE 撤销邀请链接
+func (g *HTTPGateway) handleInvite(w http.ResponseWriter, r *http.Request) {
+	code := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/invites/"), "/")
+	if code == "" || strings.Contains(code, "/") {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		resp, err := g.svc.JoinRoomWithWS(r.Context(), &chat.JoinRoomReq{
+			InviteCode: code,
+		}, nil)
+		if err != nil {
+			writeServerError(w, err)
+			return
+		}
+		writeResult(w, resp.Code, resp.Message, map[string]interface{}{
+			"room": resp.Room,
+		})
+
+	case http.MethodDelete:
+		resp, err := g.svc.RevokeRoomInvite(r.Context(), &chat.RevokeRoomInviteReq{
+			Code: code,
+		})
+		if err != nil {
+			writeServerError(w, err)
+			return
+		}
+		writeResult(w, resp.Code, resp.Message, nil)
+
+	default:
+		allowMethod(w, r, http.MethodPost, http.MethodDelete)
+	}
+}
+
+// handleJoinRequest POST /api/join-requests/:id 审批入群申请（{approve}）
+func (g *HTTPGateway) handleJoinRequest(w http.ResponseWriter, r *http.Request) {
+	requestID, err := strconv.ParseUint(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/join-requests/"), "/"), 10, 64)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	var req chat.ReviewJoinRequestReq
+	if err := decodeBody(r, &req); err != nil {
+		writeJSON(w, http.StatusBadRequest, utils.Error(utils.CodeParamError, "invalid request body"))
+		return
+	}
+	req.RequestId = requestID
+
+	resp, err := g.svc.ReviewJoinRequestWithWS(r.Context(), &req)
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+	writeResult(w, resp.Code, resp.Message, map[string]interface{}{
+		"request": resp.Request,
+	})
+}
+
+// handleDirectChats GET/POST /api/direct-chats
+func (g *HTTPGateway) handleDirectChats(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		resp, err := g.svc.ListDirectChats(r.Context(), &chat.ListDirectChatsReq{})
+		if err != nil {
+			writeServerError(w, err)
+			return
+		}
+		writeResult(w, resp.Code, resp.Message, map[string]interface{}{
+			"chats": resp.Chats,
+		})
+
+	case http.MethodPost:
+		var req chat.OpenDirectChatReq
+		if err := decodeBody(r, &req); err != nil || req.PeerUserId == "" {
+			writeJSON(w, http.StatusBadRequest, utils.Error(utils.CodeParamError, "peer_user_id required"))
+			return
+		}
+
+		resp, err := g.svc.OpenDirectChat(r.Context(), &req)
+		if err != nil {
+			writeServerError(w, err)
+			return
+		}
+		writeResult(w, resp.Code, resp.Message, map[string]interface{}{
+			"chat": resp.Chat,
+		})
+
+	default:
+		allowMethod(w, r, http.MethodGet, http.MethodPost)
+	}
+}
+
+// handleUnread GET /api/unread
+func (g *HTTPGateway) handleUnread(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	resp, err := g.svc.GetUnreadCounts(r.Context(), &chat.GetUnreadCountsReq{})
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+	writeResult(w, resp.Code, resp.Message, map[string]interface{}{
+		"unread": resp.Unread,
+	})
+}
+
+// handleMessages GET/POST /api/messages
+func (g *HTTPGateway) handleMessages(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		query := r.URL.Query()
+		req := &chat.GetHistoryReq{
+			RoomId:        query.Get("room_id"),
+			BeforeSeq:     int64(queryInt(query.Get("before_seq"))),
+			Limit:         int32(queryInt(query.Get("limit"))),
+			WithRevisions: query.Get("with_revisions") == "true",
+		}
+		if req.RoomId == "" {
+			writeJSON(w, http.StatusBadRequest, utils.Error(utils.CodeParamError, "room_id required"))
+			return
+		}
+
+		resp, err := g.svc.GetHistory(r.Context(), req)
+		if err != nil {
+			writeServerError(w, err)
+			return
+		}
+		writeResult(w, resp.Code, resp.Message, map[string]interface{}{
+			"messages": resp.Messages,
+			"has_more": resp.HasMore,
+		})
+
+	case http.MethodPost:
+		var req chat.SendMessageReq
+		if err := decodeBody(r, &req); err != nil || req.RoomId == "" || req.Content == "" {
+			writeJSON(w, http.StatusBadRequest, utils.Error(utils.CodeParamError, "room_id and content required"))
+			return
+		}
+		if req.MsgType == 0 {
+			req.MsgType = 1
+		}
+
+		resp, err := g.svc.SendMessageWithWS(r.Context(), &req)
+		if err != nil {
+			writeServerError(w, err)
+			return
+		}
+		writeResult(w, resp.Code, resp.Message, map[string]interface{}{
+			"msg":    resp.Msg,
+			"notice": resp.Notice,
+		})
+
+	default:
+		allowMethod(w, r, http.MethodGet, http.MethodPost)
+	}
+}
+
+// handleMessage PUT/DELETE /api/messages/:id 及 /api/messages/:id/{recall,thread,reactions}
+func (g *HTTPGateway) handleMessage(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/messages/"), "/"), "/")
+	if parts[0] == "" || len(parts) > 2 {
+		http.NotFound(w, r)
+		return
+	}
+	msgID := parts[0]
+
+	if len(parts) == 2 {
+		g.handleMessageAction(w, r, msgID, parts[1])
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		var req chat.EditMessageReq
+		if err := decodeBody(r, &req); err != nil || strings.TrimSpace(req.Content) == "" {
+			writeJSON(w, http.StatusBadRequest, utils.Error(utils.CodeParamError, "content required"))
+			return
+		}
+		req.MsgId = msgID
+
+		resp, err := g.svc.EditMessageWithWS(r.Context(), &req)
+		if err != nil {
+			writeServerError(w, err)
+			return
+		}
+		writeResult(w, resp.Code, resp.Message, map[string]interface{}{
+			"msg": resp.Msg,
+		})
+
+	case http.MethodDelete:
+		resp, err := g.svc.DeleteMessageWithWS(r.Context(), &chat.DeleteMessageReq{
+			MsgId: msgID,
+		})
+		if err != nil {
+			writeServerError(w, err)
+			return
+		}
+		writeResult(w, resp.Code, resp.Message, map[string]interface{}{
+			"msg": resp.Msg,
+		})
+
+	default:
+		allowMethod(w, r, http.MethodPut, http.MethodDelete)
+	}
+}
+
+// handleMessageAction POST /api/messages/:id/recall、GET /api/messages/:id/thread
+// 与 POST/DELETE /api/messages/:id/reactions
+func (g *HTTPGateway) handleMessageAction(w http.ResponseWriter, r *http.Request, msgID, action string) {
+	switch action {
+	case "recall":
+		if !allowMethod(w, r, http.MethodPost) {
+			return
+		}
+		resp, err := g.svc.RecallMessageWithWS(r.Context(), &chat.RecallMessageReq{
+			MsgId: msgID,
+		})
+		if err != nil {
+			writeServerError(w, err)
+			return
+		}
+		writeResult(w, resp.Code, resp.Message, map[string]interface{}{
+			"msg": resp.Msg,
+		})
+
+	case "thread":
+		if !allowMethod(w, r, http.MethodGet) {
+			return
+		}
+		query := r.URL.Query()
+		resp, err := g.svc.GetThread(r.Context(), &chat.GetThreadReq{
+			RootMsgId: msgID,
+			AfterSeq:  int64(queryInt(query.Get("after_seq"))),
+			Limit:     int32(queryInt(query.Get("limit"))),
+		})
+		if err != nil {
+			writeServerError(w, err)
+			return
+		}
+		writeResult(w, resp.Code, resp.Message, map[string]interface{}{
+			"root":     resp.Root,
+			"replies":  resp.Replies,
+			"has_more": resp.HasMore,
+		})
+
+	case "reactions":
+		switch r.Method {
+		case http.MethodPost:
+			var req chat.AddReactionReq
+			if err := decodeBody(r, &req); err != nil || req.Emoji == "" {
+				writeJSON(w, http.StatusBadRequest, utils.Error(utils.CodeParamError, "emoji required"))
+				return
+			}
+			req.MsgId = msgID
+
+			resp, err := g.svc.AddReactionWithWS(r.Context(), &req)
+			if err != nil {
+				writeServerError(w, err)
+				return
+			}
+			writeResult(w, resp.Code, resp.Message, map[string]interface{}{
+				"reactions": resp.Reactions,
+			})
+
+		case http.MethodDelete:
+			emoji := r.URL.Query().Get("emoji")
+			if emoji == "" {
+				writeJSON(w, http.StatusBadRequest, utils.Error(utils.CodeParamError, "emoji required"))
+				return
+			}
+
+			resp, err := g.svc.RemoveReactionWithWS(r.Context(), &chat.RemoveReactionReq{
+				MsgId: msgID,
+				Emoji: emoji,
+			})
+			if err != nil {
+				writeServerError(w, err)
+				return
+			}
+			writeResult(w, resp.Code, resp.Message, map[string]interface{}{
+				"reactions": resp.Reactions,
+			})
+
+		default:
+			allowMethod(w, r, http.MethodPost, http.MethodDelete)
+		}
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// handlePresence GET /api/presence?user_id=xxx
+func (g *HTTPGateway) handlePresence(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	userID := r.URL.Query().Get("user_id")
+	if userID == "" {
+		writeJSON(w, http.StatusBadRequest, utils.Error(utils.CodeParamError, "user_id required"))
+		return
+	}
+
+	code, message, presence := g.svc.GetPresence(r.Context(), userID)
+	writeResult(w, code, message, map[string]interface{}{
+		"presence": presence,
+	})
+}
+
+// handleBots POST /api/bots
+func (g *HTTPGateway) handleBots(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	var req chat.CreateBotReq
+	if err := decodeBody(r, &req); err != nil || req.Username == "" {
+		writeJSON(w, http.StatusBadRequest, utils.Error(utils.CodeParamError, "username required"))
+		return
+	}
+
+	resp, err := g.svc.CreateBot(r.Context(), &req)
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+	writeResult(w, resp.Code, resp.Message, map[string]interface{}{
+		"bot":       resp.Bot,
+		"api_token": resp.ApiToken,
+	})
+}
+
+// handleOutgoingWebhooks POST /api/webhooks/outgoing
+func (g *HTTPGateway) handleOutgoingWebhooks(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	var req chat.CreateOutgoingWebhookReq
+	if err := decodeBody(r, &req); err != nil || req.RoomId == "" || req.Url == "" || req.BotId == "" {
+		writeJSON(w, http.StatusBadRequest, utils.Error(utils.CodeParamError, "room_id, url and bot_id required"))
+		return
+	}
+
+	resp, err := g.svc.CreateOutgoingWebhook(r.Context(), &req)
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+	writeResult(w, resp.Code, resp.Message, map[string]interface{}{
+		"webhook": resp.Webhook,
+		"secret":  resp.Secret,
+	})
+}
+
+// handleOutgoingWebhook DELETE /api/webhooks/outgoing/:id
+func (g *HTTPGateway) handleOutgoingWebhook(w http.ResponseWriter, r *http.Request) {
+	webhookID := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/webhooks/outgoing/"), "/")
+	if webhookID == "" || strings.Contains(webhookID, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	if !allowMethod(w, r, http.MethodDelete) {
+		return
+	}
+
+	resp, err := g.svc.DeleteOutgoingWebhook(r.Context(), &chat.DeleteOutgoingWebhookReq{
+		WebhookId: webhookID,
+	})
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+	writeResult(w, resp.Code, resp.Message, nil)
+}
+
+// handleIncomingWebhooks POST /api/webhooks/incoming
+func (g *HTTPGateway) handleIncomingWebhooks(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	var req chat.CreateIncomingWebhookReq
+	if err := decodeBody(r, &req); err != nil || req.RoomId == "" {
+		writeJSON(w, http.StatusBadRequest, utils.Error(utils.CodeParamError, "room_id required"))
+		return
+	}
+
+	resp, err := g.svc.CreateIncomingWebhook(r.Context(), &req)
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+	writeResult(w, resp.Code, resp.Message, map[string]interface{}{
+		"webhook": resp.Webhook,
+		"token":   resp.Token,
+	})
+}
+
+// handleIncomingWebhook DELETE /api/webhooks/incoming/:id
+func (g *HTTPGateway) handleIncomingWebhook(w http.ResponseWriter, r *http.Request) {
+	webhookID := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/webhooks/incoming/"), "/")
+	if webhookID == "" || strings.Contains(webhookID, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	if !allowMethod(w, r, http.MethodDelete) {
+		return
+	}
+
+	resp, err := g.svc.DeleteIncomingWebhook(r.Context(), &chat.DeleteIncomingWebhookReq{
+		WebhookId: webhookID,
+	})
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+	writeResult(w, resp.Code, resp.Message, nil)
+}
+
+// handlePostIncomingWebhook POST /api/hooks/:id/:token（令牌也可放在 X-BotChat-Token 头）
+func (g *HTTPGateway) handlePostIncomingWebhook(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/hooks/"), "/"), "/")
+	if len(parts) > 2 || parts[0] == "" {
+		http.NotFound(w, r)
+		return
+	}
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	token := r.Header.Get("X-BotChat-Token")
+	if len(parts) == 2 {
+		token = parts[1]
+	}
+
+	var payload IncomingWebhookPayload
+	if err := decodeBody(r, &payload); err != nil {
+		writeJSON(w, http.StatusBadRequest, utils.Error(utils.CodeParamError, "invalid json"))
+		return
+	}
+
+	resp, err := g.svc.PostIncomingWebhook(r.Context(), parts[0], token, &payload)
+	if err != nil {
+		writeServerError(w, err)
+		return
+	}
+	if resp.Code == utils.CodeUnauthorized {
+		writeJSON(w, http.StatusUnauthorized, utils.Error(resp.Code, resp.Message))
+		return
+	}
+	writeResult(w, resp.Code, resp.Message, map[string]interface{}{
+		"msg": resp.Msg,
+	})
+}
+
+// allowMethod 校验请求方法，不匹配时返回 405
+func allowMethod(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, method := range methods {
+		if r.Method == method {
+			return true
+		}
+	}
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	writeJSON(w, http.StatusMethodNotAllowed, utils.Error(utils.CodeParamError, "method not allowed"))
+	return false
+}
+
+// decodeBody 解析 JSON 请求体
+func decodeBody(r *http.Request, v interface{}) error {
+	defer r.Body.Close()
+	return json.NewDecoder(r.Body).Decode(v)
+}
+
+// queryInt 解析整数参数，非法值视为 0
+func queryInt(val string) int {
+	n, _ := strconv.Atoi(val)
+	return n
+}
+
+// writeResult 按业务码输出 utils.Resp
+func writeResult(w http.ResponseWriter, code int32, message string, data interface{}) {
+	if code != utils.CodeSuccess {
+		writeJSON(w, http.StatusOK, utils.Error(code, message))
+		return
+	}
+	writeJSON(w, http.StatusOK, utils.Success(data))
+}
+
+// writeServerError 输出服务内部错误
+func writeServerError(w http.ResponseWriter, err error) {
+	log.Printf("HTTP gateway error: %v", err)
+	writeJSON(w, http.StatusInternalServerError, utils.Error(utils.CodeServerError, "internal error"))
+}
+
+// writeJSON 输出 JSON 响应
+func writeJSON(w http.ResponseWriter, status int, resp *utils.Resp) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Printf("Failed to write response: %v", err)
+	}
+}
@@ -0,0 +1,263 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/baijianruoli/bot_chat/backend/internal/dao"
+	"github.com/baijianruoli/bot_chat/backend/internal/model"
+	"github.com/baijianruoli/bot_chat/backend/internal/utils"
+	chat "github.com/baijianruoli/bot_chat/backend/kitex_gen/chat"
+)
+
+// roomVisibility 校验创建房间时指定的可见性，空值视为公开
+func roomVisibility(visibility string) (string, bool) {
+	switch visibility {
+	case "":
+		return model.RoomVisibilityPublic, true
+	case model.RoomVisibilityPublic, model.RoomVisibilityInvite, model.RoomVisibilityPrivate:
+		return visibility, true
+	default:
+		return "", false
+	}
+}
+
+// CreateRoomInvite 具备邀请权限的成员创建房间邀请链接，可限制有效期与使用次数
+func (s *ChatServiceImpl) CreateRoomInvite(ctx context.Context, req *chat.CreateRoomInviteReq) (*chat.CreateRoomInviteResp, error) {
+	access, code, message := authorizeRoom(ctx, req.UserId, req.RoomId, PermInvite)
+	if access == nil {
+		return &chat.CreateRoomInviteResp{
+			Code:    code,
+			Message: message,
+		}, nil
+	}
+
+	if req.MaxUses < 0 || req.ExpiresIn < 0 {
+		return &chat.CreateRoomInviteResp{
+			Code:    utils.CodeParamError,
+			Message: "invalid max_uses or expires_in",
+		}, nil
+	}
+
+	invite := &model.RoomInvite{
+		Code:      utils.GenerateInviteCode(),
+		RoomID:    access.Room.RoomID,
+		CreatorID: access.UserID,
+		MaxUses:   req.MaxUses,
+	}
+	if req.ExpiresIn > 0 {
+		invite.ExpiresAt = time.Now().Add(time.Duration(req.ExpiresIn) * time.Second).UnixMilli()
+	}
+	if err := dao.NewRoomInviteDAO(dao.DB).Create(invite); err != nil {
+		return &chat.CreateRoomInviteResp{
+			Code:    utils.CodeServerError,
+			Message: "failed to create invite",
+		}, nil
+	}
+
+	return &chat.CreateRoomInviteResp{
+		Code:    utils.CodeSuccess,
+		Message: "success",
+		Invite: &chat.RoomInviteInfo{
+			Code:      invite.Code,
+			RoomId:    invite.RoomID,
+			CreatorId: invite.CreatorID,
+			MaxUses:   invite.MaxUses,
+			Uses:      invite.Uses,
+			ExpiresAt: invite.ExpiresAt,
+			CreatedAt: invite.CreatedAt,
+		},
+	}, nil
+}
+
+// RevokeRoomInvite 具备邀请权限的成员撤销邀请链接，已加入的成员不受影响
+func (s *ChatServiceImpl) RevokeRoomInvite(ctx context.Context, req *chat.RevokeRoomInviteReq) (*chat.RevokeRoomInviteResp, error) {
+	userID, ok := authorize(ctx, req.UserId)
+	if !ok {
+		return &chat.RevokeRoomInviteResp{
+			Code:    utils.CodeUnauthorized,
+			Message: "unauthorized",
+		}, nil
+	}
+
+	inviteDAO := dao.NewRoomInviteDAO(dao.DB)
+	invite, err := inviteDAO.GetByCode(req.Code)
+	if err != nil {
+		return &chat.RevokeRoomInviteResp{
+			Code:    utils.CodeServerError,
+			Message: "database error",
+		}, nil
+	}
+	if invite == nil {
+		return &chat.RevokeRoomInviteResp{
+			Code:    utils.CodeInviteInvalid,
+			Message: "invite not found",
+		}, nil
+	}
+
+	if access, code, message := authorizeRoom(ctx, userID, invite.RoomID, PermInvite); access == nil {
+		return &chat.RevokeRoomInviteResp{
+			Code:    code,
+			Message: message,
+		}, nil
+	}
+
+	if err := inviteDAO.Revoke(invite.Code, time.Now().UnixMilli()); err != nil {
+		return &chat.RevokeRoomInviteResp{
+			Code:    utils.CodeServerError,
+			Message: "failed to revoke invite",
+		}, nil
+	}
+
+	return &chat.RevokeRoomInviteResp{
+		Code:    utils.CodeSuccess,
+		Message: "success",
+	}, nil
+}
+
+// ListJoinRequests 具备邀请权限的成员获取房间待审批的入群申请
+func (s *ChatServiceImpl) ListJoinRequests(ctx context.Context, req *chat.ListJoinRequestsReq) (*chat.ListJoinRequestsResp, error) {
+	access, code, message := authorizeRoom(ctx, req.UserId, req.RoomId, PermApproveJoin)
+	if access == nil {
+		return &chat.ListJoinRequestsResp{
+			Code:    code,
+			Message: message,
+		}, nil
+	}
+
+	requests, err := dao.NewJoinRequestDAO(dao.DB).ListPending(access.Room.RoomID)
+	if err != nil {
+		return &chat.ListJoinRequestsResp{
+			Code:    utils.CodeServerError,
+			Message: "database error",
+		}, nil
+	}
+
+	userDAO := dao.NewUserDAO(dao.DB)
+	infos := make([]*chat.JoinRequestInfo, len(requests))
+	for i, request := range requests {
+		user, _ := userDAO.GetByID(request.UserID)
+		infos[i] = toJoinRequestInfo(request, user)
+	}
+
+	return &chat.ListJoinRequestsResp{
+		Code:     utils.CodeSuccess,
+		Message:  "success",
+		Requests: infos,
+	}, nil
+}
+
+// ReviewJoinRequest 具备邀请权限的成员批准或拒绝入群申请，批准后申请人成为房间成员
+func (s *ChatServiceImpl) ReviewJoinRequest(ctx context.Context, req *chat.ReviewJoinRequestReq) (*chat.ReviewJoinRequestResp, error) {
+	userID, ok := authorize(ctx, req.UserId)
+	if !ok {
+		return &chat.ReviewJoinRequestResp{
+			Code:    utils.CodeUnauthorized,
+			Message: "unauthorized",
+		}, nil
+	}
+
+	joinRequestDAO := dao.NewJoinRequestDAO(dao.DB)
+	request, err := joinRequestDAO.GetByID(req.RequestId)
+	if err != nil {
+		return &chat.ReviewJoinRequestResp{
+			Code:    utils.CodeServerError,
+			Message: "database error",
+		}, nil
+	}
+	if request == nil {
+		return &chat.ReviewJoinRequestResp{
+			Code:    utils.CodeNotFound,
+			Message: "join request not found",
+		}, nil
+	}
+
+	if access, code, message := authorizeRoom(ctx, userID, request.RoomID, PermApproveJoin); access == nil {
+		return &chat.ReviewJoinRequestResp{
+			Code:    code,
+			Message: message,
+		}, nil
+	}
+
+	// 申请提交后被封禁的用户不能再被批准
+	if req.Approve {
+		banned, err := dao.NewRoomBanDAO(dao.DB).IsBanned(request.RoomID, request.UserID)
+		if err != nil {
+			return &chat.ReviewJoinRequestResp{
+				Code:    utils.CodeServerError,
+				Message: "database error",
+			}, nil
+		}
+		if banned {
+			return &chat.ReviewJoinRequestResp{
+				Code:    utils.CodeBannedFromRoom,
+				Message: "user is banned from room",
+			}, nil
+		}
+	}
+
+	status := model.JoinRequestRejected
+	if req.Approve {
+		status = model.JoinRequestApproved
+	}
+	if err := joinRequestDAO.Review(request, status, userID, time.Now().UnixMilli()); err != nil {
+		if err == dao.ErrRequestReviewed {
+			return &chat.ReviewJoinRequestResp{
+				Code:    utils.CodeParamError,
+				Message: "join request already reviewed",
+			}, nil
+		}
+		return &chat.ReviewJoinRequestResp{
+			Code:    utils.CodeServerError,
+			Message: "failed to review join request",
+		}, nil
+	}
+
+	// 申请人可能已凭邀请链接加入
+	if req.Approve {
+		roomMemberDAO := dao.NewRoomMemberDAO(dao.DB)
+		isMember, err := roomMemberDAO.IsMember(request.RoomID, request.UserID)
+		if err == nil && !isMember {
+			err = roomMemberDAO.AddMember(request.RoomID, request.UserID)
+			if err == nil {
+				dao.NewRoomDAO(dao.DB).UpdateUserCount(request.RoomID, 1)
+			}
+		}
+		if err != nil {
+			return &chat.ReviewJoinRequestResp{
+				Code:    utils.CodeServerError,
+				Message: "failed to add member",
+			}, nil
+		}
+	}
+
+	user, _ := dao.NewUserDAO(dao.DB).GetByID(request.UserID)
+	return &chat.ReviewJoinRequestResp{
+		Code:    utils.CodeSuccess,
+		Message: "success",
+		Request: toJoinRequestInfo(request, user),
+	}, nil
+}
+
+// toJoinRequestInfo 转换入群申请
+func toJoinRequestInfo(request *model.JoinRequest, user *model.User) *chat.JoinRequestInfo {
+	info := &chat.JoinRequestInfo{
+		Id:         request.ID,
+		RoomId:     request.RoomID,
+		User:       &chat.UserInfo{UserId: request.UserID},
+		Status:     request.Status,
+		ReviewerId: request.ReviewerID,
+		CreatedAt:  request.CreatedAt,
+		ReviewedAt: request.ReviewedAt,
+	}
+	if user != nil {
+		info.User = &chat.UserInfo{
+			UserId:   user.UserID,
+			Username: user.Username,
+			Nickname: user.Nickname,
+			Avatar:   user.Avatar,
+			IsBot:    user.IsBot(),
+		}
+	}
+	return info
+}
@@ -0,0 +1,28 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/baijianruoli/bot_chat/backend/internal/model"
+)
+
+func TestRoomVisibility(t *testing.T) {
+	tests := []struct {
+		in     string
+		want   string
+		wantOK bool
+	}{
+		{"", model.RoomVisibilityPublic, true},
+		{model.RoomVisibilityPublic, model.RoomVisibilityPublic, true},
+		{model.RoomVisibilityInvite, model.RoomVisibilityInvite, true},
+		{model.RoomVisibilityPrivate, model.RoomVisibilityPrivate, true},
+		{"secret", "", false},
+		{"PUBLIC", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := roomVisibility(tt.in)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("roomVisibility(%q) = (%q, %v), want (%q, %v)", tt.in, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
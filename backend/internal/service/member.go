@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+
+	"github.com/baijianruoli/bot_chat/backend/internal/dao"
+	"github.com/baijianruoli/bot_chat/backend/internal/model"
+	"github.com/baijianruoli/bot_chat/backend/internal/utils"
+	chat "github.com/baijianruoli/bot_chat/backend/kitex_gen/chat"
+)
+
+// PromoteMember 提升成员角色，只能提升到低于自己的角色
+func (s *ChatServiceImpl) PromoteMember(ctx context.Context, req *chat.PromoteMemberReq) (*chat.PromoteMemberResp, error) {
+	code, message, member := setMemberRole(ctx, req.UserId, req.RoomId, req.TargetUserId, req.Role, true)
+	return &chat.PromoteMemberResp{
+		Code:    code,
+		Message: message,
+		Member:  member,
+	}, nil
+}
+
+// DemoteMember 降低成员角色，只能管理角色低于自己的成员
+func (s *ChatServiceImpl) DemoteMember(ctx context.Context, req *chat.DemoteMemberReq) (*chat.DemoteMemberResp, error) {
+	code, message, member := setMemberRole(ctx, req.UserId, req.RoomId, req.TargetUserId, req.Role, false)
+	return &chat.DemoteMemberResp{
+		Code:    code,
+		Message: message,
+		Member:  member,
+	}, nil
+}
+
+// setMemberRole 校验权限后修改成员角色。禁言与解除禁言（member 与 muted 之间）需要 PermMute，
+// 其余调整需要 PermPromote；调用方的角色须高于成员的当前角色与目标角色
+func setMemberRole(ctx context.Context, claimed, roomID, targetID, role string, promote bool) (int32, string, *chat.RoomMemberInfo) {
+	access, code, message := authorizeRoom(ctx, claimed, roomID, PermView)
+	if access == nil {
+		return code, message, nil
+	}
+
+	if !isRoomRole(role) || role == model.RoomRoleOwner {
+		return utils.CodeParamError, "invalid role", nil
+	}
+	if targetID == "" || targetID == access.UserID {
+		return utils.CodeParamError, "invalid target_user_id", nil
+	}
+
+	roomMemberDAO := dao.NewRoomMemberDAO(dao.DB)
+	target, err := roomMemberDAO.GetMember(roomID, targetID)
+	if err != nil {
+		return utils.CodeServerError, "database error", nil
+	}
+	if target == nil {
+		return utils.CodeNotInRoom, "target not in room", nil
+	}
+
+	if promote && roleRanks[role] <= roleRanks[target.Role] {
+		return utils.CodeParamError, "role must be higher than " + target.Role, nil
+	}
+	if !promote && roleRanks[role] >= roleRanks[target.Role] {
+		return utils.CodeParamError, "role must be lower than " + target.Role, nil
+	}
+
+	perm := PermPromote
+	if roleRanks[role] <= roleRanks[model.RoomRoleMember] && roleRanks[target.Role] <= roleRanks[model.RoomRoleMember] {
+		perm = PermMute
+	}
+	if !access.Can(perm) || !access.Outranks(target.Role) || !access.Outranks(role) {
+		return utils.CodeUnauthorized, "permission denied", nil
+	}
+
+	if err := roomMemberDAO.SetRole(roomID, targetID, target.Role, role); err != nil {
+		if err == dao.ErrRoleChanged {
+			return utils.CodeParamError, "member role changed, please retry", nil
+		}
+		return utils.CodeServerError, "failed to update role", nil
+	}
+
+	return utils.CodeSuccess, "success", &chat.RoomMemberInfo{
+		RoomId: roomID,
+		UserId: targetID,
+		Role:   role,
+	}
+}
+
+// TransferOwnership 房主将房间转让给其他成员，原房主降为管理员
+func (s *ChatServiceImpl) TransferOwnership(ctx context.Context, req *chat.TransferOwnershipReq) (*chat.TransferOwnershipResp, error) {
+	access, code, message := authorizeRoom(ctx, req.UserId, req.RoomId, PermTransferOwnership)
+	if access == nil {
+		return &chat.TransferOwnershipResp{
+			Code:    code,
+			Message: message,
+		}, nil
+	}
+	if req.NewOwnerId == "" || req.NewOwnerId == access.UserID {
+		return &chat.TransferOwnershipResp{
+			Code:    utils.CodeParamError,
+			Message: "invalid new_owner_id",
+		}, nil
+	}
+
+	isMember, err := dao.NewRoomMemberDAO(dao.DB).IsMember(req.RoomId, req.NewOwnerId)
+	if err != nil {
+		return &chat.TransferOwnershipResp{
+			Code:    utils.CodeServerError,
+			Message: "database error",
+		}, nil
+	}
+	if !isMember {
+		return &chat.TransferOwnershipResp{
+			Code:    utils.CodeNotInRoom,
+			Message: "new owner not in room",
+		}, nil
+	}
+
+	if err := dao.NewRoomDAO(dao.DB).TransferOwner(req.RoomId, access.UserID, req.NewOwnerId); err != nil {
+		if err == dao.ErrRoleChanged {
+			return &chat.TransferOwnershipResp{
+				Code:    utils.CodeParamError,
+				Message: "member role changed, please retry",
+			}, nil
+		}
+		return &chat.TransferOwnershipResp{
+			Code:    utils.CodeServerError,
+			Message: "failed to transfer ownership",
+		}, nil
+	}
+
+	return &chat.TransferOwnershipResp{
+		Code:    utils.CodeSuccess,
+		Message: "success",
+		Members: []*chat.RoomMemberInfo{
+			{RoomId: req.RoomId, UserId: req.NewOwnerId, Role: model.RoomRoleOwner},
+			{RoomId: req.RoomId, UserId: access.UserID, Role: model.RoomRoleAdmin},
+		},
+	}, nil
+}
@@ -0,0 +1,224 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/baijianruoli/bot_chat/backend/internal/dao"
+	"github.com/baijianruoli/bot_chat/backend/internal/model"
+	"github.com/baijianruoli/bot_chat/backend/internal/utils"
+	chat "github.com/baijianruoli/bot_chat/backend/kitex_gen/chat"
+)
+
+// userMsgType 校验客户端可指定的消息类型（1:文本 2:图片），0 视为文本；系统消息（3）只能由服务端产生
+func userMsgType(msgType int32) (int32, bool) {
+	switch msgType {
+	case 0:
+		return 1, true
+	case 1, 2:
+		return msgType, true
+	default:
+		return 0, false
+	}
+}
+
+// withinWindow 判断 createdAt（毫秒）发送的消息在 now 时是否仍在时限 window 内，window 为 0 表示不限制
+func withinWindow(createdAt int64, window time.Duration, now time.Time) bool {
+	return window <= 0 || now.UnixMilli()-createdAt <= window.Milliseconds()
+}
+
+// EditMessage 作者在编辑时限内修改自己的文本消息，修改前的内容保存为历史版本
+func (s *ChatServiceImpl) EditMessage(ctx context.Context, req *chat.EditMessageReq) (*chat.EditMessageResp, error) {
+	userID, ok := authorize(ctx, req.UserId)
+	if !ok {
+		return &chat.EditMessageResp{
+			Code:    utils.CodeUnauthorized,
+			Message: "unauthorized",
+		}, nil
+	}
+
+	content := req.Content
+	if strings.TrimSpace(content) == "" {
+		return &chat.EditMessageResp{
+			Code:    utils.CodeParamError,
+			Message: "content required",
+		}, nil
+	}
+
+	messageDAO := dao.NewMessageDAO(dao.DB)
+	msg, err := messageDAO.GetByID(req.MsgId)
+	if err != nil {
+		return &chat.EditMessageResp{
+			Code:    utils.CodeServerError,
+			Message: "database error",
+		}, nil
+	}
+	if msg == nil || msg.RecalledAt > 0 {
+		return &chat.EditMessageResp{
+			Code:    utils.CodeMsgNotFound,
+			Message: "message not found",
+		}, nil
+	}
+
+	// 只有作者本人可以编辑，且作者仍需在房间中并可以发言
+	if msg.UserID != userID {
+		return &chat.EditMessageResp{
+			Code:    utils.CodeUnauthorized,
+			Message: "only the author can edit this message",
+		}, nil
+	}
+	if access, code, message := authorizeRoom(ctx, userID, msg.RoomID, PermSend); access == nil {
+		return &chat.EditMessageResp{
+			Code:    code,
+			Message: message,
+		}, nil
+	}
+	if msg.MsgType != 1 {
+		return &chat.EditMessageResp{
+			Code:    utils.CodeParamError,
+			Message: "only text messages can be edited",
+		}, nil
+	}
+
+	now := time.Now()
+	if !withinWindow(msg.CreatedAt, s.messages.EditWindow, now) {
+		return &chat.EditMessageResp{
+			Code:    utils.CodeMsgExpired,
+			Message: "edit window expired",
+		}, nil
+	}
+
+	user, err := dao.NewUserDAO(dao.DB).GetByID(userID)
+	if err != nil {
+		return &chat.EditMessageResp{
+			Code:    utils.CodeServerError,
+			Message: "database error",
+		}, nil
+	}
+
+	// 内容未变化时不产生新版本
+	if content != msg.Content {
+		if err := messageDAO.Edit(msg, content, userID, now.UnixMilli()); err != nil {
+			if err == dao.ErrMessageChanged {
+				return &chat.EditMessageResp{
+					Code:    utils.CodeMsgConflict,
+					Message: "message was edited concurrently, please retry",
+				}, nil
+			}
+			return &chat.EditMessageResp{
+				Code:    utils.CodeServerError,
+				Message: "failed to edit message",
+			}, nil
+		}
+	}
+
+	return &chat.EditMessageResp{
+		Code:    utils.CodeSuccess,
+		Message: "success",
+		Msg:     toMessageInfo(msg, user),
+	}, nil
+}
+
+// RecallMessage 作者在撤回时限内撤回自己的消息，消息保留为墓碑
+func (s *ChatServiceImpl) RecallMessage(ctx context.Context, req *chat.RecallMessageReq) (*chat.RecallMessageResp, error) {
+	code, message, info := s.recallMessage(ctx, req.UserId, req.MsgId, model.MessageActionRecall)
+	return &chat.RecallMessageResp{
+		Code:    code,
+		Message: message,
+		Msg:     info,
+	}, nil
+}
+
+// DeleteMessage 具备删除权限的成员随时删除房间内的消息，消息保留为墓碑
+func (s *ChatServiceImpl) DeleteMessage(ctx context.Context, req *chat.DeleteMessageReq) (*chat.DeleteMessageResp, error) {
+	code, message, info := s.recallMessage(ctx, req.UserId, req.MsgId, model.MessageActionDelete)
+	return &chat.DeleteMessageResp{
+		Code:    code,
+		Message: message,
+		Msg:     info,
+	}, nil
+}
+
+// recallMessage 校验撤回/删除权限并写入墓碑与审计记录，返回墓碑消息
+func (s *ChatServiceImpl) recallMessage(ctx context.Context, claimed, msgID, action string) (int32, string, *chat.MessageInfo) {
+	userID, ok := authorize(ctx, claimed)
+	if !ok {
+		return utils.CodeUnauthorized, "unauthorized", nil
+	}
+
+	messageDAO := dao.NewMessageDAO(dao.DB)
+	msg, err := messageDAO.GetByID(msgID)
+	if err != nil {
+		return utils.CodeServerError, "database error", nil
+	}
+	if msg == nil || msg.RecalledAt > 0 {
+		return utils.CodeMsgNotFound, "message not found", nil
+	}
+
+	now := time.Now()
+	switch action {
+	case model.MessageActionRecall:
+		if msg.UserID != userID {
+			return utils.CodeUnauthorized, "only the author can recall this message", nil
+		}
+		if access, code, message := authorizeRoom(ctx, userID, msg.RoomID, PermView); access == nil {
+			return code, message, nil
+		}
+		if !withinWindow(msg.CreatedAt, s.messages.RecallWindow, now) {
+			return utils.CodeMsgExpired, "recall window expired", nil
+		}
+
+	case model.MessageActionDelete:
+		if access, code, message := authorizeRoom(ctx, userID, msg.RoomID, PermDeleteMessage); access == nil {
+			return code, message, nil
+		}
+	}
+
+	if err := messageDAO.Recall(msg, action, userID, now.UnixMilli()); err != nil {
+		if err == dao.ErrMessageChanged {
+			return utils.CodeMsgNotFound, "message not found", nil
+		}
+		return utils.CodeServerError, "failed to recall message", nil
+	}
+
+	var author *model.User
+	if msg.UserID != "" {
+		author, _ = dao.NewUserDAO(dao.DB).GetByID(msg.UserID)
+	}
+	return utils.CodeSuccess, "success", toMessageInfo(msg, author)
+}
+
+// attachRevisions 为已编辑的消息填充历史版本，墓碑的历史版本只保留在审计中
+func attachRevisions(messages []*model.Message, infos []*chat.MessageInfo) error {
+	revisions, err := dao.NewMessageDAO(dao.DB).ListRevisions(revisedMsgIDs(messages))
+	if err != nil {
+		return err
+	}
+	fillRevisions(messages, infos, revisions)
+	return nil
+}
+
+// revisedMsgIDs 返回需要展示历史版本的消息：已编辑且未被撤回或删除
+func revisedMsgIDs(messages []*model.Message) []string {
+	var msgIDs []string
+	for _, msg := range messages {
+		if msg.EditedAt > 0 && msg.RecalledAt == 0 {
+			msgIDs = append(msgIDs, msg.MsgID)
+		}
+	}
+	return msgIDs
+}
+
+// fillRevisions 按 messages 的顺序为对应的 infos 填充历史版本
+func fillRevisions(messages []*model.Message, infos []*chat.MessageInfo, revisions map[string][]*model.MessageRevision) {
+	for i, msg := range messages {
+		for _, revision := range revisions[msg.MsgID] {
+			infos[i].Revisions = append(infos[i].Revisions, &chat.MessageRevision{
+				Content:    revision.Content,
+				EditorId:   revision.EditorID,
+				ReplacedAt: revision.CreatedAt,
+			})
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/baijianruoli/bot_chat/backend/internal/model"
+	chat "github.com/baijianruoli/bot_chat/backend/kitex_gen/chat"
+)
+
+func TestWithinWindow(t *testing.T) {
+	now := time.UnixMilli(1700000000000)
+	sentAt := now.Add(-15 * time.Minute).UnixMilli()
+
+	tests := []struct {
+		name      string
+		createdAt int64
+		window    time.Duration
+		want      bool
+	}{
+		{"unlimited", sentAt, 0, true},
+		{"inside", sentAt, 20 * time.Minute, true},
+		{"at the edge", sentAt, 15 * time.Minute, true},
+		{"expired", sentAt, 15*time.Minute - time.Millisecond, false},
+		{"sent in the future", now.Add(time.Second).UnixMilli(), time.Minute, true},
+	}
+	for _, tt := range tests {
+		if got := withinWindow(tt.createdAt, tt.window, now); got != tt.want {
+			t.Errorf("%s: withinWindow = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestRecallWindow 撤回沿用同一时限判断，默认时限 2m
+func TestRecallWindow(t *testing.T) {
+	now := time.UnixMilli(1700000000000)
+	window := 2 * time.Minute
+
+	tests := []struct {
+		sentAgo time.Duration
+		want    bool
+	}{
+		{time.Minute, true},
+		{2 * time.Minute, true},
+		{2*time.Minute + time.Millisecond, false},
+		{time.Hour, false},
+	}
+	for _, tt := range tests {
+		if got := withinWindow(now.Add(-tt.sentAgo).UnixMilli(), window, now); got != tt.want {
+			t.Errorf("recall %v after sending: withinWindow = %v, want %v", tt.sentAgo, got, tt.want)
+		}
+	}
+	if !withinWindow(now.Add(-time.Hour).UnixMilli(), 0, now) {
+		t.Error("MESSAGE_RECALL_WINDOW=0 should not limit recall")
+	}
+}
+
+func TestAttachRevisions(t *testing.T) {
+	messages := []*model.Message{
+		{MsgID: "m_plain"},
+		{MsgID: "m_edited", EditedAt: 2000},
+		{MsgID: "m_recalled", EditedAt: 2000, RecalledAt: 3000},
+	}
+
+	// 墓碑的历史版本只保留在审计中，不再查询
+	if got, want := revisedMsgIDs(messages), []string{"m_edited"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("revisedMsgIDs = %v, want %v", got, want)
+	}
+
+	infos := make([]*chat.MessageInfo, len(messages))
+	for i, msg := range messages {
+		infos[i] = &chat.MessageInfo{MsgId: msg.MsgID}
+	}
+	fillRevisions(messages, infos, map[string][]*model.MessageRevision{
+		"m_edited": {
+			{MsgID: "m_edited", Content: "v1", EditorID: "u_1", CreatedAt: 1500},
+			{MsgID: "m_edited", Content: "v2", EditorID: "u_1", CreatedAt: 2000},
+		},
+	})
+
+	if infos[0].Revisions != nil || infos[2].Revisions != nil {
+		t.Fatalf("unexpected revisions: %v, %v", infos[0].Revisions, infos[2].Revisions)
+	}
+	want := []*chat.MessageRevision{
+		{Content: "v1", EditorId: "u_1", ReplacedAt: 1500},
+		{Content: "v2", EditorId: "u_1", ReplacedAt: 2000},
+	}
+	if !reflect.DeepEqual(infos[1].Revisions, want) {
+		t.Fatalf("revisions = %v, want %v", infos[1].Revisions, want)
+	}
+}
@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/baijianruoli/bot_chat/backend/internal/utils"
+	"github.com/cloudwego/kitex/pkg/endpoint"
+	"github.com/cloudwego/kitex/pkg/remote/trans/nphttp2/metadata"
+	"github.com/cloudwego/kitex/pkg/rpcinfo"
+)
+
+type ctxKey int
+
+const claimsKey ctxKey = iota
+
+// publicMethods 无需登录即可调用的 RPC
+var publicMethods = map[string]bool{
+	"Register":     true,
+	"Login":        true,
+	"RefreshToken": true,
+}
+
+// WithClaims 将已认证的令牌载荷写入 context
+func WithClaims(ctx context.Context, claims *utils.TokenClaims) context.Context {
+	return context.WithValue(ctx, claimsKey, claims)
+}
+
+// ClaimsFromContext 读取已认证的令牌载荷
+func ClaimsFromContext(ctx context.Context) (*utils.TokenClaims, bool) {
+	claims, ok := ctx.Value(claimsKey).(*utils.TokenClaims)
+	return claims, ok && claims != nil
+}
+
+// CallerFromContext 读取已认证的调用方用户ID
+func CallerFromContext(ctx context.Context) (string, bool) {
+	claims, ok := ClaimsFromContext(ctx)
+	if !ok {
+		return "", false
+	}
+	return claims.UserID, true
+}
+
+// authorize 返回调用方身份；请求中声明的用户ID必须与之一致
+func authorize(ctx context.Context, claimed string) (string, bool) {
+	callerID, ok := CallerFromContext(ctx)
+	if !ok || (claimed != "" && claimed != callerID) {
+		return "", false
+	}
+	return callerID, true
+}
+
+// AuthMiddleware Kitex 服务端中间件，从 gRPC metadata 的 authorization 中校验令牌。
+// 校验失败时不写入身份，由各方法返回 utils.CodeUnauthorized。
+func AuthMiddleware(tokens *TokenService) endpoint.Middleware {
+	return func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, req, resp interface{}) error {
+			if ri := rpcinfo.GetRPCInfo(ctx); ri != nil && publicMethods[ri.To().Method()] {
+				return next(ctx, req, resp)
+			}
+
+			if md, ok := metadata.FromIncomingContext(ctx); ok {
+				for _, header := range md.Get("authorization") {
+					if claims, err := tokens.Verify(BearerToken(header)); err == nil {
+						ctx = WithClaims(ctx, claims)
+						break
+					}
+				}
+			}
+			return next(ctx, req, resp)
+		}
+	}
+}
+
+// requireAuth HTTP 中间件，校验 Bearer 令牌并写入调用方身份
+func requireAuth(tokens *TokenService, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, err := tokens.Verify(BearerToken(r.Header.Get("Authorization")))
+		if err != nil {
+			if code, _ := tokenErrorCode(err); code == utils.CodeServerError {
+				writeServerError(w, err)
+				return
+			}
+			writeJSON(w, http.StatusUnauthorized, utils.Error(utils.CodeUnauthorized, "unauthorized"))
+			return
+		}
+		next(w, r.WithContext(WithClaims(r.Context(), claims)))
+	}
+}
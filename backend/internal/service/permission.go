@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+
+	"github.com/baijianruoli/bot_chat/backend/internal/dao"
+	"github.com/baijianruoli/bot_chat/backend/internal/model"
+	"github.com/baijianruoli/bot_chat/backend/internal/utils"
+)
+
+// Permission 房间内的操作权限
+type Permission int
+
+const (
+	PermView              Permission = iota // 查看消息、标记已读等，房间成员均具备
+	PermSend                                // 发送消息与表情回应
+	PermInvite                              // 邀请用户、管理邀请链接
+	PermApproveJoin                         // 查看与审批入群申请，仅房主具备
+	PermKick                                // 将成员移出房间
+	PermMute                                // 禁言与解除禁言
+	PermBan                                 // 封禁与解除封禁，被封禁的用户不能重新加入
+	PermPin                                 // 置顶消息
+	PermEditRoom                            // 修改房间话题，管理他人创建的 Webhook
+	PermDeleteMessage                       // 删除他人的消息
+	PermPromote                             // 提升或降低成员角色
+	PermTransferOwnership                   // 转让房主，仅房主具备
+)
+
+// rolePermissions 角色权限矩阵
+var rolePermissions = map[string][]Permission{
+	model.RoomRoleOwner:     {PermView, PermSend, PermInvite, PermApproveJoin, PermKick, PermMute, PermBan, PermPin, PermEditRoom, PermDeleteMessage, PermPromote, PermTransferOwnership},
+	model.RoomRoleAdmin:     {PermView, PermSend, PermInvite, PermKick, PermMute, PermBan, PermPin, PermEditRoom, PermDeleteMessage, PermPromote},
+	model.RoomRoleModerator: {PermView, PermSend, PermInvite, PermMute, PermPin, PermDeleteMessage},
+	model.RoomRoleMember:    {PermView, PermSend},
+	model.RoomRoleMuted:     {PermView},
+}
+
+// directPermissions 私聊固定为两人且没有管理者，只能查看和发送
+var directPermissions = []Permission{PermView, PermSend}
+
+// roleRanks 角色等级，只能管理等级低于自己的成员
+var roleRanks = map[string]int{
+	model.RoomRoleMuted:     0,
+	model.RoomRoleMember:    1,
+	model.RoomRoleModerator: 2,
+	model.RoomRoleAdmin:     3,
+	model.RoomRoleOwner:     4,
+}
+
+// roomAccess 调用方在房间中的身份
+type roomAccess struct {
+	UserID string
+	Room   *model.Room
+	Role   string
+}
+
+// authorizeRoom 房间内操作的统一鉴权入口：校验调用方身份、房间存在、成员关系及角色是否具备 perm，
+// 失败时返回错误码
+func authorizeRoom(ctx context.Context, claimed, roomID string, perm Permission) (*roomAccess, int32, string) {
+	userID, ok := authorize(ctx, claimed)
+	if !ok {
+		return nil, utils.CodeUnauthorized, "unauthorized"
+	}
+
+	room, err := dao.NewRoomDAO(dao.DB).GetByID(roomID)
+	if err != nil {
+		return nil, utils.CodeServerError, "database error"
+	}
+	if room == nil {
+		return nil, utils.CodeRoomNotFound, "room not found"
+	}
+
+	member, err := dao.NewRoomMemberDAO(dao.DB).GetMember(roomID, userID)
+	if err != nil {
+		return nil, utils.CodeServerError, "database error"
+	}
+	if member == nil {
+		return nil, utils.CodeNotInRoom, "not in room"
+	}
+
+	access := &roomAccess{UserID: userID, Room: room, Role: member.Role}
+	if !access.Can(perm) {
+		return nil, utils.CodeUnauthorized, "permission denied"
+	}
+	return access, utils.CodeSuccess, ""
+}
+
+// Can 判断调用方是否具备 perm
+func (a *roomAccess) Can(perm Permission) bool {
+	return roleCan(a.Room, a.Role, perm)
+}
+
+// Outranks 判断调用方角色是否高于 role
+func (a *roomAccess) Outranks(role string) bool {
+	return roleRanks[a.Role] > roleRanks[role]
+}
+
+// roleCan 判断房间中的 role 是否具备 perm
+func roleCan(room *model.Room, role string, perm Permission) bool {
+	perms := rolePermissions[role]
+	if room.Kind == model.RoomKindDirect {
+		perms = directPermissions
+	}
+	for _, p := range perms {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// rolesWith 返回具备 perm 的角色
+func rolesWith(perm Permission) []string {
+	var roles []string
+	for role := range rolePermissions {
+		if roleCan(&model.Room{}, role, perm) {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// isRoomRole 判断是否为合法的成员角色
+func isRoomRole(role string) bool {
+	_, ok := roleRanks[role]
+	return ok
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/baijianruoli/bot_chat/backend/internal/model"
+)
+
+func TestRolePermissions(t *testing.T) {
+	group := &model.Room{Kind: model.RoomKindGroup}
+	direct := &model.Room{Kind: model.RoomKindDirect}
+
+	tests := []struct {
+		perm  Permission
+		roles []string // 群聊中具备该权限的角色
+	}{
+		{PermSend, []string{model.RoomRoleOwner, model.RoomRoleAdmin, model.RoomRoleModerator, model.RoomRoleMember}},
+		{PermMute, []string{model.RoomRoleOwner, model.RoomRoleAdmin, model.RoomRoleModerator}},
+		{PermPin, []string{model.RoomRoleOwner, model.RoomRoleAdmin, model.RoomRoleModerator}},
+		{PermInvite, []string{model.RoomRoleOwner, model.RoomRoleAdmin, model.RoomRoleModerator}},
+		{PermApproveJoin, []string{model.RoomRoleOwner}},
+		{PermKick, []string{model.RoomRoleOwner, model.RoomRoleAdmin}},
+		{PermBan, []string{model.RoomRoleOwner, model.RoomRoleAdmin}},
+		{PermPromote, []string{model.RoomRoleOwner, model.RoomRoleAdmin}},
+		{PermTransferOwnership, []string{model.RoomRoleOwner}},
+	}
+	for _, tt := range tests {
+		allowed := make(map[string]bool)
+		for _, role := range tt.roles {
+			allowed[role] = true
+		}
+		for role := range roleRanks {
+			if got := roleCan(group, role, tt.perm); got != allowed[role] {
+				t.Errorf("roleCan(group, %s, %d) = %v, want %v", role, tt.perm, got, allowed[role])
+			}
+		}
+
+		got := rolesWith(tt.perm)
+		sort.Strings(got)
+		want := append([]string(nil), tt.roles...)
+		sort.Strings(want)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("rolesWith(%d) = %v, want %v", tt.perm, got, want)
+		}
+	}
+
+	// 私聊中房主也只能查看与发送，不能转让或封禁
+	for _, perm := range []Permission{PermTransferOwnership, PermBan, PermKick, PermMute} {
+		if roleCan(direct, model.RoomRoleOwner, perm) {
+			t.Errorf("roleCan(direct, owner, %d) = true", perm)
+		}
+	}
+	if !roleCan(direct, model.RoomRoleMember, PermSend) {
+		t.Error("roleCan(direct, member, PermSend) = false")
+	}
+}
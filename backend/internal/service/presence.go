@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync/atomic"
+	"unicode/utf8"
+
+	"github.com/baijianruoli/bot_chat/backend/internal/dao"
+	"github.com/baijianruoli/bot_chat/backend/internal/utils"
+)
+
+// deviceNameMaxLen 设备名称上限（字符）
+const deviceNameMaxLen = 64
+
+// DevicePresence 单个连接（设备）的在线状态
+type DevicePresence struct {
+	ConnID      string `json:"conn_id"`
+	Device      string `json:"device"`
+	ConnectedAt int64  `json:"connected_at"`
+	LastSeen    int64  `json:"last_seen"`
+}
+
+// Presence 用户在线状态
+type Presence struct {
+	UserID   string            `json:"user_id"`
+	Online   bool              `json:"online"`
+	LastSeen int64             `json:"last_seen"`         // 在线时为各设备最近活跃时间的最大值
+	Devices  []*DevicePresence `json:"devices,omitempty"` // 只返回给本人
+}
+
+// Public 对其他用户可见的在线状态，不含设备、连接ID与 User-Agent
+func (p *Presence) Public() *Presence {
+	return &Presence{
+		UserID:   p.UserID,
+		Online:   p.Online,
+		LastSeen: p.LastSeen,
+	}
+}
+
+// GetPresence 获取用户各设备的在线状态
+func (m *WSManager) GetPresence(userID string) *Presence {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	presence := &Presence{
+		UserID:   userID,
+		LastSeen: m.lastSeen[userID],
+		Devices:  []*DevicePresence{},
+	}
+	for _, client := range m.users[userID] {
+		device := &DevicePresence{
+			ConnID:      client.connID,
+			Device:      client.device,
+			ConnectedAt: client.connected,
+			LastSeen:    atomic.LoadInt64(&client.lastSeen),
+		}
+		if device.LastSeen > presence.LastSeen {
+			presence.LastSeen = device.LastSeen
+		}
+		presence.Devices = append(presence.Devices, device)
+	}
+	presence.Online = len(presence.Devices) > 0
+
+	sort.Slice(presence.Devices, func(i, j int) bool {
+		return presence.Devices[i].ConnectedAt < presence.Devices[j].ConnectedAt
+	})
+	return presence
+}
+
+// GetPresence 查询用户在线状态。设备明细只对本人可见，其他人只能查询同房间用户是否在线
+func (s *ChatServiceImpl) GetPresence(ctx context.Context, userID string) (int32, string, *Presence) {
+	callerID, ok := authorize(ctx, "")
+	if !ok {
+		return utils.CodeUnauthorized, "unauthorized", nil
+	}
+
+	presence := GlobalWSManager.GetPresence(userID)
+	if userID == callerID {
+		return utils.CodeSuccess, "success", presence
+	}
+
+	shared, err := dao.NewRoomMemberDAO(dao.DB).SharesRoom(callerID, userID)
+	if err != nil {
+		return utils.CodeServerError, "database error", nil
+	}
+	if !shared {
+		return utils.CodeUserNotFound, "user not found", nil
+	}
+	return utils.CodeSuccess, "success", presence.Public()
+}
+
+// deviceName 取客户端通过 ?device= 声明的设备名，缺省使用 User-Agent
+func deviceName(r *http.Request) string {
+	name := r.URL.Query().Get("device")
+	if name == "" {
+		name = r.UserAgent()
+	}
+	if utf8.RuneCountInString(name) > deviceNameMaxLen {
+		name = string([]rune(name)[:deviceNameMaxLen])
+	}
+	return name
+}
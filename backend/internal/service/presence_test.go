@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/baijianruoli/bot_chat/backend/internal/utils"
+)
+
+func TestGetPresenceSelf(t *testing.T) {
+	client := &WSClient{
+		connID:    "c_1",
+		userID:    "u_presence",
+		device:    "Mozilla/5.0 (X11; Linux x86_64)",
+		connected: 1000,
+		lastSeen:  2000,
+		send:      make(chan []byte, 1),
+	}
+	GlobalWSManager.handleRegister(client)
+	defer GlobalWSManager.handleUnregister(client)
+
+	s := &ChatServiceImpl{}
+	if code, _, _ := s.GetPresence(context.Background(), "u_presence"); code != utils.CodeUnauthorized {
+		t.Fatalf("anonymous GetPresence code = %d, want %d", code, utils.CodeUnauthorized)
+	}
+
+	ctx := WithClaims(context.Background(), &utils.TokenClaims{UserID: "u_presence", Type: utils.TokenTypeAccess})
+	code, _, presence := s.GetPresence(ctx, "u_presence")
+	if code != utils.CodeSuccess {
+		t.Fatalf("GetPresence code = %d", code)
+	}
+	if !presence.Online || presence.LastSeen != 2000 || len(presence.Devices) != 1 || presence.Devices[0].ConnID != "c_1" {
+		t.Fatalf("unexpected presence: %+v", presence)
+	}
+}
+
+func TestPresencePublic(t *testing.T) {
+	presence := &Presence{
+		UserID:   "u_1",
+		Online:   true,
+		LastSeen: 2000,
+		Devices: []*DevicePresence{
+			{ConnID: "c_1", Device: "Mozilla/5.0", ConnectedAt: 1000, LastSeen: 2000},
+		},
+	}
+
+	data, err := json.Marshal(presence.Public())
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if got := string(data); got != `{"user_id":"u_1","online":true,"last_seen":2000}` {
+		t.Fatalf("public presence = %s", got)
+	}
+	if strings.Contains(string(data), "c_1") || strings.Contains(string(data), "Mozilla") {
+		t.Fatalf("public presence leaks device details: %s", data)
+	}
+}
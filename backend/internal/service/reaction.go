@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/baijianruoli/bot_chat/backend/internal/dao"
+	"github.com/baijianruoli/bot_chat/backend/internal/utils"
+	chat "github.com/baijianruoli/bot_chat/backend/kitex_gen/chat"
+)
+
+// maxEmojiLength 表情最多字符数（组合 emoji 由多个码点组成）
+const maxEmojiLength = 16
+
+// AddReaction 为房间内的消息添加表情回应，重复添加不报错
+func (s *ChatServiceImpl) AddReaction(ctx context.Context, req *chat.AddReactionReq) (*chat.AddReactionResp, error) {
+	code, message, reactions := s.react(ctx, req.UserId, req.MsgId, req.Emoji, true)
+	return &chat.AddReactionResp{
+		Code:      code,
+		Message:   message,
+		Reactions: reactions,
+	}, nil
+}
+
+// RemoveReaction 移除自己的表情回应，未回应过不报错
+func (s *ChatServiceImpl) RemoveReaction(ctx context.Context, req *chat.RemoveReactionReq) (*chat.RemoveReactionResp, error) {
+	code, message, reactions := s.react(ctx, req.UserId, req.MsgId, req.Emoji, false)
+	return &chat.RemoveReactionResp{
+		Code:      code,
+		Message:   message,
+		Reactions: reactions,
+	}, nil
+}
+
+// react 校验消息与成员身份后添加或移除回应，返回消息最新的回应汇总
+func (s *ChatServiceImpl) react(ctx context.Context, claimed, msgID, emoji string, add bool) (int32, string, []*chat.ReactionInfo) {
+	userID, ok := authorize(ctx, claimed)
+	if !ok {
+		return utils.CodeUnauthorized, "unauthorized", nil
+	}
+
+	if emoji == "" || strings.ContainsAny(emoji, " \t\r\n") || utf8.RuneCountInString(emoji) > maxEmojiLength {
+		return utils.CodeParamError, "invalid emoji", nil
+	}
+
+	msg, err := dao.NewMessageDAO(dao.DB).GetByID(msgID)
+	if err != nil {
+		return utils.CodeServerError, "database error", nil
+	}
+	if msg == nil || msg.RecalledAt > 0 {
+		return utils.CodeMsgNotFound, "message not found", nil
+	}
+
+	// 被禁言的成员仍可移除自己的回应
+	perm := PermView
+	if add {
+		perm = PermSend
+	}
+	if access, code, message := authorizeRoom(ctx, userID, msg.RoomID, perm); access == nil {
+		return code, message, nil
+	}
+
+	reactionDAO := dao.NewReactionDAO(dao.DB)
+	if add {
+		err = reactionDAO.Add(msgID, userID, emoji)
+	} else {
+		err = reactionDAO.Remove(msgID, userID, emoji)
+	}
+	if err != nil {
+		return utils.CodeServerError, "failed to update reaction", nil
+	}
+
+	summaries, err := reactionDAO.Summaries([]string{msgID}, userID)
+	if err != nil {
+		return utils.CodeServerError, "database error", nil
+	}
+	return utils.CodeSuccess, "success", toReactionInfos(summaries[msgID])
+}
+
+// attachReactions 为消息填充表情回应汇总，reacted 以 userID 为准
+func attachReactions(infos []*chat.MessageInfo, userID string) error {
+	msgIDs := make([]string, 0, len(infos))
+	for _, info := range infos {
+		if info.RecalledAt == 0 {
+			msgIDs = append(msgIDs, info.MsgId)
+		}
+	}
+
+	summaries, err := dao.NewReactionDAO(dao.DB).Summaries(msgIDs, userID)
+	if err != nil {
+		return err
+	}
+	for _, info := range infos {
+		info.Reactions = toReactionInfos(summaries[info.MsgId])
+	}
+	return nil
+}
+
+// toReactionInfos 转换回应汇总
+func toReactionInfos(counts []*dao.ReactionCount) []*chat.ReactionInfo {
+	reactions := make([]*chat.ReactionInfo, len(counts))
+	for i, count := range counts {
+		reactions[i] = &chat.ReactionInfo{
+			Emoji:   count.Emoji,
+			Count:   count.Count,
+			Reacted: count.Reacted,
+		}
+	}
+	return reactions
+}
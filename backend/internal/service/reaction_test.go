@@ -0,0 +1,28 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/baijianruoli/bot_chat/backend/internal/dao"
+	chat "github.com/baijianruoli/bot_chat/backend/kitex_gen/chat"
+)
+
+func TestToReactionInfos(t *testing.T) {
+	got := toReactionInfos([]*dao.ReactionCount{
+		{MsgID: "m_1", Emoji: "👍", Count: 3, Reacted: true},
+		{MsgID: "m_1", Emoji: "🎉", Count: 1},
+	})
+	want := []*chat.ReactionInfo{
+		{Emoji: "👍", Count: 3, Reacted: true},
+		{Emoji: "🎉", Count: 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("toReactionInfos = %v, want %v", got, want)
+	}
+
+	// 没有回应的消息返回空列表而不是 nil，客户端收到 []
+	if got := toReactionInfos(nil); got == nil || len(got) != 0 {
+		t.Fatalf("toReactionInfos(nil) = %#v, want empty slice", got)
+	}
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/baijianruoli/bot_chat/backend/internal/dao"
+	"github.com/baijianruoli/bot_chat/backend/internal/utils"
+	chat "github.com/baijianruoli/bot_chat/backend/kitex_gen/chat"
+)
+
+// clampReadSeq 返回实际标记的已读序号：0 表示房间最新一条，不能标记尚未产生的消息
+func clampReadSeq(seq, lastSeq int64) int64 {
+	if seq == 0 || seq > lastSeq {
+		return lastSeq
+	}
+	return seq
+}
+
+// MarkRead 将成员在房间的已读游标推进到指定序号，游标只前进不后退
+func (s *ChatServiceImpl) MarkRead(ctx context.Context, req *chat.MarkReadReq) (*chat.MarkReadResp, error) {
+	access, code, message := authorizeRoom(ctx, req.UserId, req.RoomId, PermView)
+	if access == nil {
+		return &chat.MarkReadResp{
+			Code:    code,
+			Message: message,
+		}, nil
+	}
+	userID := access.UserID
+
+	if req.Seq < 0 {
+		return &chat.MarkReadResp{
+			Code:    utils.CodeParamError,
+			Message: "invalid seq",
+		}, nil
+	}
+
+	memberDAO := dao.NewRoomMemberDAO(dao.DB)
+
+	seq := clampReadSeq(req.Seq, access.Room.LastSeq)
+	advanced, err := memberDAO.MarkRead(req.RoomId, userID, seq, time.Now().UnixMilli())
+	if err != nil {
+		return &chat.MarkReadResp{
+			Code:    utils.CodeServerError,
+			Message: "failed to mark read",
+		}, nil
+	}
+
+	cursors, err := memberDAO.ListReadCursors(req.RoomId)
+	if err != nil {
+		return &chat.MarkReadResp{
+			Code:    utils.CodeServerError,
+			Message: "database error",
+		}, nil
+	}
+
+	resp := &chat.MarkReadResp{
+		Code:     utils.CodeSuccess,
+		Message:  "success",
+		Advanced: advanced,
+		Cursors:  make([]*chat.ReadCursor, len(cursors)),
+	}
+	for i, cursor := range cursors {
+		resp.Cursors[i] = &chat.ReadCursor{
+			UserId:      cursor.UserID,
+			LastReadSeq: cursor.LastReadSeq,
+			LastReadAt:  cursor.LastReadAt,
+		}
+		if cursor.UserID == userID {
+			resp.LastReadSeq = cursor.LastReadSeq
+		}
+	}
+	return resp, nil
+}
+
+// GetUnreadCounts 获取用户在所有已加入房间的未读数，不含自己发送的、话题内的和已撤回的消息
+func (s *ChatServiceImpl) GetUnreadCounts(ctx context.Context, req *chat.GetUnreadCountsReq) (*chat.GetUnreadCountsResp, error) {
+	userID, ok := authorize(ctx, req.UserId)
+	if !ok {
+		return &chat.GetUnreadCountsResp{
+			Code:    utils.CodeUnauthorized,
+			Message: "unauthorized",
+		}, nil
+	}
+
+	counts, err := dao.NewRoomMemberDAO(dao.DB).UnreadCounts(userID)
+	if err != nil {
+		return &chat.GetUnreadCountsResp{
+			Code:    utils.CodeServerError,
+			Message: "database error",
+		}, nil
+	}
+
+	unread := make([]*chat.RoomUnread, len(counts))
+	for i, count := range counts {
+		unread[i] = &chat.RoomUnread{
+			RoomId:      count.RoomID,
+			Count:       count.Count,
+			LastReadSeq: count.LastReadSeq,
+		}
+	}
+	return &chat.GetUnreadCountsResp{
+		Code:    utils.CodeSuccess,
+		Message: "success",
+		Unread:  unread,
+	}, nil
+}
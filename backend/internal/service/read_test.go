@@ -0,0 +1,20 @@
+package service
+
+import "testing"
+
+func TestClampReadSeq(t *testing.T) {
+	tests := []struct {
+		seq, lastSeq, want int64
+	}{
+		{0, 42, 42},  // 0 表示房间最新一条
+		{10, 42, 10}, // 已产生的消息按原值标记
+		{42, 42, 42},
+		{100, 42, 42}, // 不能标记尚未产生的消息
+		{0, 0, 0},     // 空房间
+	}
+	for _, tt := range tests {
+		if got := clampReadSeq(tt.seq, tt.lastSeq); got != tt.want {
+			t.Errorf("clampReadSeq(%d, %d) = %d, want %d", tt.seq, tt.lastSeq, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package service
+
+import (
+	"context"
+
+	"github.com/baijianruoli/bot_chat/backend/internal/dao"
+	"github.com/baijianruoli/bot_chat/backend/internal/model"
+	"github.com/baijianruoli/bot_chat/backend/internal/utils"
+	chat "github.com/baijianruoli/bot_chat/backend/kitex_gen/chat"
+)
+
+// GetThread 按序号升序分页获取话题下的回复
+func (s *ChatServiceImpl) GetThread(ctx context.Context, req *chat.GetThreadReq) (*chat.GetThreadResp, error) {
+	userID, ok := authorize(ctx, req.UserId)
+	if !ok {
+		return &chat.GetThreadResp{
+			Code:    utils.CodeUnauthorized,
+			Message: "unauthorized",
+		}, nil
+	}
+
+	messageDAO := dao.NewMessageDAO(dao.DB)
+	userDAO := dao.NewUserDAO(dao.DB)
+
+	root, err := messageDAO.GetByID(req.RootMsgId)
+	if err != nil {
+		return &chat.GetThreadResp{
+			Code:    utils.CodeServerError,
+			Message: "database error",
+		}, nil
+	}
+	if root == nil || root.ThreadRootID != "" {
+		return &chat.GetThreadResp{
+			Code:    utils.CodeMsgNotFound,
+			Message: "thread not found",
+		}, nil
+	}
+
+	if access, code, message := authorizeRoom(ctx, userID, root.RoomID, PermView); access == nil {
+		return &chat.GetThreadResp{
+			Code:    code,
+			Message: message,
+		}, nil
+	}
+
+	if req.Limit <= 0 {
+		req.Limit = 50
+	}
+	if req.Limit > 100 {
+		req.Limit = 100
+	}
+
+	replies, err := messageDAO.ListThread(root.MsgID, req.AfterSeq, int(req.Limit))
+	if err != nil {
+		return &chat.GetThreadResp{
+			Code:    utils.CodeServerError,
+			Message: "database error",
+		}, nil
+	}
+
+	users := make(map[string]*model.User)
+	lookup := func(msg *model.Message) *model.User {
+		if msg.UserID == "" {
+			return nil
+		}
+		user, ok := users[msg.UserID]
+		if !ok {
+			user, _ = userDAO.GetByID(msg.UserID)
+			users[msg.UserID] = user
+		}
+		return user
+	}
+
+	infos := make([]*chat.MessageInfo, len(replies)+1)
+	infos[0] = toMessageInfo(root, lookup(root))
+	for i, msg := range replies {
+		infos[i+1] = toMessageInfo(msg, lookup(msg))
+	}
+	err = attachReplyTo(infos)
+	if err == nil {
+		err = attachReactions(infos, userID)
+	}
+	if err != nil {
+		return &chat.GetThreadResp{
+			Code:    utils.CodeServerError,
+			Message: "database error",
+		}, nil
+	}
+
+	return &chat.GetThreadResp{
+		Code:    utils.CodeSuccess,
+		Message: "success",
+		Root:    infos[0],
+		Replies: infos[1:],
+		HasMore: len(replies) == int(req.Limit),
+	}, nil
+}
+
+// resolveReply 校验引用的消息与话题根消息都在同一房间，返回被引用的消息及话题根消息ID。
+// 以话题中的回复作为根时归入其所在的话题
+func resolveReply(roomID, replyToID, threadRootID string) (*model.Message, string, int32, string) {
+	return resolveReplyWith(dao.NewMessageDAO(dao.DB).GetByID, roomID, replyToID, threadRootID)
+}
+
+// resolveReplyWith 同 resolveReply，消息通过 getMessage 读取，不存在时返回 nil
+func resolveReplyWith(getMessage func(msgID string) (*model.Message, error), roomID, replyToID, threadRootID string) (*model.Message, string, int32, string) {
+	if threadRootID != "" {
+		root, err := getMessage(threadRootID)
+		if err != nil {
+			return nil, "", utils.CodeServerError, "database error"
+		}
+		if root != nil && root.ThreadRootID != "" {
+			threadRootID = root.ThreadRootID
+			root, err = getMessage(threadRootID)
+			if err != nil {
+				return nil, "", utils.CodeServerError, "database error"
+			}
+		}
+		if root == nil || root.RoomID != roomID || root.RecalledAt > 0 {
+			return nil, "", utils.CodeMsgNotFound, "thread not found"
+		}
+	}
+
+	var quoted *model.Message
+	if replyToID != "" {
+		var err error
+		quoted, err = getMessage(replyToID)
+		if err != nil {
+			return nil, "", utils.CodeServerError, "database error"
+		}
+		if quoted == nil || quoted.RoomID != roomID || quoted.RecalledAt > 0 {
+			return nil, "", utils.CodeMsgNotFound, "quoted message not found"
+		}
+	}
+
+	return quoted, threadRootID, utils.CodeSuccess, ""
+}
+
+// attachReplyTo 为引用了其他消息的消息填充被引用消息
+func attachReplyTo(infos []*chat.MessageInfo) error {
+	var msgIDs []string
+	for _, info := range infos {
+		if info.ReplyToMsgId != "" {
+			msgIDs = append(msgIDs, info.ReplyToMsgId)
+		}
+	}
+	if len(msgIDs) == 0 {
+		return nil
+	}
+
+	quoted, err := dao.NewMessageDAO(dao.DB).GetByIDs(msgIDs)
+	if err != nil {
+		return err
+	}
+
+	userDAO := dao.NewUserDAO(dao.DB)
+	users := make(map[string]*model.User)
+	for _, info := range infos {
+		msg, ok := quoted[info.ReplyToMsgId]
+		if !ok {
+			continue
+		}
+		user, ok := users[msg.UserID]
+		if !ok && msg.UserID != "" {
+			user, _ = userDAO.GetByID(msg.UserID)
+			users[msg.UserID] = user
+		}
+		info.ReplyTo = toMessageInfo(msg, user)
+	}
+	return nil
+}
+
+// threadReplyMessage 构造发给话题参与者（根消息作者与回复过的用户）的 thread_reply 帧
+func threadReplyMessage(reply *chat.MessageInfo) (*WSMessage, error) {
+	messageDAO := dao.NewMessageDAO(dao.DB)
+	root, err := messageDAO.GetByID(reply.ThreadRootId)
+	if err != nil || root == nil {
+		return nil, err
+	}
+	participants, err := messageDAO.ThreadParticipants(root.MsgID)
+	if err != nil {
+		return nil, err
+	}
+	if root.UserID != "" {
+		participants = append(participants, root.UserID)
+	}
+
+	return &WSMessage{
+		Type:   EventThreadReply,
+		Seq:    reply.Seq,
+		RoomID: reply.RoomId,
+		UserID: reply.Sender.UserId,
+		To:     participants,
+		Data: map[string]interface{}{
+			"root_msg_id":   root.MsgID,
+			"msg":           reply,
+			"reply_count":   root.ReplyCount,
+			"last_reply_at": root.LastReplyAt,
+		},
+	}, nil
+}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/baijianruoli/bot_chat/backend/internal/model"
+	"github.com/baijianruoli/bot_chat/backend/internal/utils"
+)
+
+func TestResolveReply(t *testing.T) {
+	messages := map[string]*model.Message{
+		"m_root":     {MsgID: "m_root", RoomID: "r_1"},
+		"m_reply":    {MsgID: "m_reply", RoomID: "r_1", ThreadRootID: "m_root"},
+		"m_recalled": {MsgID: "m_recalled", RoomID: "r_1", RecalledAt: 1000},
+		"m_other":    {MsgID: "m_other", RoomID: "r_2"},
+		"m_orphan":   {MsgID: "m_orphan", RoomID: "r_1", ThreadRootID: "m_gone"},
+	}
+	getMessage := func(msgID string) (*model.Message, error) {
+		if msgID == "m_broken" {
+			return nil, errors.New("connection reset")
+		}
+		return messages[msgID], nil
+	}
+
+	tests := []struct {
+		name         string
+		replyToID    string
+		threadRootID string
+		wantQuoted   string
+		wantRoot     string
+		wantCode     int32
+	}{
+		{"main timeline", "", "", "", "", utils.CodeSuccess},
+		{"reply in thread", "", "m_root", "", "m_root", utils.CodeSuccess},
+		// 以话题中的回复为根时归入原话题，话题只有一层
+		{"flattened to root", "", "m_reply", "", "m_root", utils.CodeSuccess},
+		{"quote in thread", "m_reply", "m_reply", "m_reply", "m_root", utils.CodeSuccess},
+		{"quote only", "m_root", "", "m_root", "", utils.CodeSuccess},
+		{"unknown root", "", "m_missing", "", "", utils.CodeMsgNotFound},
+		{"root in other room", "", "m_other", "", "", utils.CodeMsgNotFound},
+		{"recalled root", "", "m_recalled", "", "", utils.CodeMsgNotFound},
+		{"root of reply gone", "", "m_orphan", "", "", utils.CodeMsgNotFound},
+		{"quote in other room", "m_other", "", "", "", utils.CodeMsgNotFound},
+		{"recalled quote", "m_recalled", "", "", "", utils.CodeMsgNotFound},
+		{"database error", "m_broken", "", "", "", utils.CodeServerError},
+	}
+	for _, tt := range tests {
+		quoted, rootID, code, _ := resolveReplyWith(getMessage, "r_1", tt.replyToID, tt.threadRootID)
+		if code != tt.wantCode {
+			t.Errorf("%s: code = %d, want %d", tt.name, code, tt.wantCode)
+			continue
+		}
+		if code != utils.CodeSuccess {
+			continue
+		}
+		gotQuoted := ""
+		if quoted != nil {
+			gotQuoted = quoted.MsgID
+		}
+		if gotQuoted != tt.wantQuoted || rootID != tt.wantRoot {
+			t.Errorf("%s: got (%q, %q), want (%q, %q)", tt.name, gotQuoted, rootID, tt.wantQuoted, tt.wantRoot)
+		}
+	}
+}
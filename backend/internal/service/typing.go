@@ -0,0 +1,97 @@
+package service
+
+import "time"
+
+// typingSweepInterval 检查输入状态是否过期的间隔
+const typingSweepInterval = time.Second
+
+// StartTyping 标记用户在房间正在输入并通知房间内其他用户。
+// 输入状态仍有效时，同一连接在 TypingThrottle 内重复上报只延长过期时间，不再广播；
+// 状态已被 typing_stop 或过期清除时立即广播。返回是否已广播
+func (m *WSManager) StartTyping(client *WSClient, roomID string) (bool, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !client.rooms[roomID] {
+		return false, false
+	}
+
+	now := m.clock.Now().UnixMilli()
+	_, active := m.typing[roomID][client.userID]
+	if active && now-client.typingAt[roomID] < m.cfg.TypingThrottle.Milliseconds() {
+		m.typing[roomID][client.userID] = now + m.cfg.TypingTTL.Milliseconds()
+		return false, true
+	}
+
+	if m.typing[roomID] == nil {
+		m.typing[roomID] = make(map[string]int64)
+	}
+	m.typing[roomID][client.userID] = now + m.cfg.TypingTTL.Milliseconds()
+	client.typingAt[roomID] = now
+
+	m.emit(&WSMessage{
+		Type:   EventTypingStart,
+		RoomID: roomID,
+		UserID: client.userID,
+		Except: client.userID,
+		Data: map[string]interface{}{
+			"user_id":    client.userID,
+			"expires_in": m.cfg.TypingTTL.Milliseconds(),
+		},
+	})
+	return true, true
+}
+
+// StopTyping 清除用户在房间的输入状态，未在输入时忽略
+func (m *WSManager) StopTyping(roomID, userID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.stopTyping(roomID, userID, false)
+}
+
+// stopTyping 清除输入状态并通知房间内其他用户，调用方需持有写锁
+func (m *WSManager) stopTyping(roomID, userID string, expired bool) {
+	users, ok := m.typing[roomID]
+	if !ok {
+		return
+	}
+	if _, ok := users[userID]; !ok {
+		return
+	}
+	delete(users, userID)
+	if len(users) == 0 {
+		delete(m.typing, roomID)
+	}
+
+	m.emit(&WSMessage{
+		Type:   EventTypingStop,
+		RoomID: roomID,
+		UserID: userID,
+		Except: userID,
+		Data: map[string]interface{}{
+			"user_id": userID,
+			"expired": expired,
+		},
+	})
+}
+
+// expireTyping 定期清除超过 TypingTTL 未刷新且未收到 typing_stop 的输入状态
+func (m *WSManager) expireTyping() {
+	_, clock := m.config()
+	ticker := clock.NewTicker(typingSweepInterval)
+	defer ticker.Stop()
+
+	for range ticker.C() {
+		m.mu.Lock()
+		now := m.clock.Now().UnixMilli()
+		for roomID, users := range m.typing {
+			for userID, expiresAt := range users {
+				if expiresAt <= now {
+					m.stopTyping(roomID, userID, true)
+				}
+			}
+		}
+		m.mu.Unlock()
+	}
+}
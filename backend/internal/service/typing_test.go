@@ -0,0 +1,77 @@
+package service
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// typingEvents 取出发送队列中的输入状态帧，返回事件类型序列
+func typingEvents(t *testing.T, client *WSClient) []string {
+	t.Helper()
+
+	var events []string
+	for {
+		select {
+		case data := <-client.send:
+			var message WSMessage
+			if err := json.Unmarshal(data, &message); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if message.Type == EventTypingStart || message.Type == EventTypingStop {
+				events = append(events, message.Type)
+			}
+		default:
+			return events
+		}
+	}
+}
+
+func TestStartTypingThrottle(t *testing.T) {
+	cfg := testWSConfig()
+	cfg.TypingThrottle = 2 * time.Second
+	cfg.TypingTTL = 6 * time.Second
+	m, clock := newTestManager(t, cfg)
+
+	typist, _ := registerIdleClient(t, m, "u_1")
+	observer, _ := registerIdleClient(t, m, "u_2")
+	m.SubscribeRoom(typist, "r_1")
+	m.SubscribeRoom(observer, "r_1")
+	typingEvents(t, observer)
+
+	start := func(want bool) {
+		t.Helper()
+		if broadcast, ok := m.StartTyping(typist, "r_1"); !ok || broadcast != want {
+			t.Fatalf("StartTyping = (%v, %v), want (%v, true)", broadcast, ok, want)
+		}
+	}
+
+	// 状态有效时节流窗口内的重复上报不广播
+	start(true)
+	clock.Advance(500 * time.Millisecond)
+	start(false)
+
+	// typing_stop 之后节流窗口内再次开始输入，必须重新广播
+	m.StopTyping("r_1", "u_1")
+	clock.Advance(500 * time.Millisecond)
+	start(true)
+	if events := typingEvents(t, observer); !reflect.DeepEqual(events, []string{EventTypingStart, EventTypingStop, EventTypingStart}) {
+		t.Fatalf("observer events = %v", events)
+	}
+	if events := typingEvents(t, typist); len(events) != 0 {
+		t.Fatalf("typist received own typing events %v", events)
+	}
+
+	// 节流窗口过后再次上报会重新广播
+	clock.Advance(2 * time.Second)
+	start(true)
+	if events := typingEvents(t, observer); !reflect.DeepEqual(events, []string{EventTypingStart}) {
+		t.Fatalf("observer events after throttle = %v", events)
+	}
+
+	// 未订阅的房间不处理
+	if broadcast, ok := m.StartTyping(typist, "r_2"); broadcast || ok {
+		t.Fatalf("StartTyping in unsubscribed room = (%v, %v)", broadcast, ok)
+	}
+}
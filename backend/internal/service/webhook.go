@@ -0,0 +1,594 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/baijianruoli/bot_chat/backend/internal/conf"
+	"github.com/baijianruoli/bot_chat/backend/internal/dao"
+	"github.com/baijianruoli/bot_chat/backend/internal/model"
+	"github.com/baijianruoli/bot_chat/backend/internal/utils"
+	chat "github.com/baijianruoli/bot_chat/backend/kitex_gen/chat"
+)
+
+// Webhook 请求头
+const (
+	WebhookHeaderEvent     = "X-BotChat-Event"
+	WebhookHeaderID        = "X-BotChat-Webhook-Id"
+	WebhookHeaderTimestamp = "X-BotChat-Timestamp"
+	WebhookHeaderSignature = "X-BotChat-Signature"
+)
+
+// webhookEvents 外发 Webhook 支持的事件
+var webhookEvents = []string{"message", "join", "leave"}
+
+// webhookMaxReplySize 回复体上限
+const webhookMaxReplySize = 64 * 1024
+
+// Webhook 地址校验错误
+var (
+	errWebhookURLInvalid     = errors.New("invalid url")
+	errWebhookHostUnresolved = errors.New("url host cannot be resolved")
+	errWebhookAddrForbidden  = errors.New("url must resolve to a public address")
+)
+
+// webhookBlockedNets 标准库判断之外不允许投递的地址段
+var webhookBlockedNets = parseCIDRs(
+	"0.0.0.0/8",     // 本网络
+	"100.64.0.0/10", // 运营商级 NAT
+	"192.0.0.0/24",  // IETF 协议分配
+	"198.18.0.0/15", // 基准测试
+	"240.0.0.0/4",   // 保留地址及广播地址
+	"64:ff9b::/96",  // NAT64，可映射到任意 IPv4 地址
+)
+
+func parseCIDRs(cidrs ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, len(cidrs))
+	for i, cidr := range cidrs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets[i] = ipNet
+	}
+	return nets
+}
+
+// publicIP 是否为允许投递的公网地址，拒绝内网、回环、链路本地（含云厂商元数据地址 169.254.169.254）等
+func publicIP(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsMulticast() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+		return false
+	}
+	for _, ipNet := range webhookBlockedNets {
+		if ipNet.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkWebhookURL 校验外发 Webhook 地址：只允许 http/https，主机解析出的地址都必须是公网地址。
+// 解析结果可能在投递前变化，投递时由 newWebhookClient 再次校验实际连接的地址
+func checkWebhookURL(ctx context.Context, raw string) error {
+	target, err := url.Parse(raw)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Hostname() == "" || target.User != nil {
+		return errWebhookURLInvalid
+	}
+
+	host := target.Hostname()
+	if ip := net.ParseIP(host); ip != nil {
+		if !publicIP(ip) {
+			return errWebhookAddrForbidden
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil || len(addrs) == 0 {
+		return errWebhookHostUnresolved
+	}
+	for _, addr := range addrs {
+		if !publicIP(addr.IP) {
+			return errWebhookAddrForbidden
+		}
+	}
+	return nil
+}
+
+// newWebhookClient 创建投递用的 HTTP 客户端。建立连接前校验实际连接的地址，
+// 防止 DNS 重绑定或重定向到内网；不使用环境变量中的代理，否则校验的是代理地址
+func newWebhookClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+				return errWebhookAddrForbidden
+			}
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+// CreateOutgoingWebhook 为房间创建外发 Webhook
+func (s *ChatServiceImpl) CreateOutgoingWebhook(ctx context.Context, req *chat.CreateOutgoingWebhookReq) (*chat.CreateOutgoingWebhookResp, error) {
+	userID, ok := authorize(ctx, "")
+	if !ok {
+		return &chat.CreateOutgoingWebhookResp{
+			Code:    utils.CodeUnauthorized,
+			Message: "unauthorized",
+		}, nil
+	}
+
+	if err := checkWebhookURL(ctx, req.Url); err != nil {
+		return &chat.CreateOutgoingWebhookResp{
+			Code:    utils.CodeParamError,
+			Message: err.Error(),
+		}, nil
+	}
+
+	events := req.Events
+	if len(events) == 0 {
+		events = webhookEvents
+	}
+	for _, event := range events {
+		if !containsString(webhookEvents, event) {
+			return &chat.CreateOutgoingWebhookResp{
+				Code:    utils.CodeParamError,
+				Message: "unsupported event: " + event,
+			}, nil
+		}
+	}
+
+	// 只有可以发言的房间成员可以配置 Webhook
+	if access, code, message := authorizeRoom(ctx, userID, req.RoomId, PermSend); access == nil {
+		return &chat.CreateOutgoingWebhookResp{
+			Code:    code,
+			Message: message,
+		}, nil
+	}
+
+	// 回复以调用方名下的机器人身份发出，机器人自动加入房间
+	if code, message := ensureBotMember(userID, req.BotId, req.RoomId); code != utils.CodeSuccess {
+		return &chat.CreateOutgoingWebhookResp{
+			Code:    code,
+			Message: message,
+		}, nil
+	}
+
+	hook := &model.OutgoingWebhook{
+		WebhookID: "wh_" + utils.GenerateUUID()[:12],
+		RoomID:    req.RoomId,
+		BotID:     req.BotId,
+		URL:       req.Url,
+		Secret:    utils.RandomToken(24),
+		Events:    strings.Join(events, ","),
+		CreatorID: userID,
+	}
+	if err := dao.NewWebhookDAO(dao.DB).CreateOutgoing(hook); err != nil {
+		return &chat.CreateOutgoingWebhookResp{
+			Code:    utils.CodeServerError,
+			Message: "failed to create webhook",
+		}, nil
+	}
+
+	return &chat.CreateOutgoingWebhookResp{
+		Code:    utils.CodeSuccess,
+		Message: "success",
+		Webhook: &chat.WebhookInfo{
+			WebhookId: hook.WebhookID,
+			RoomId:    hook.RoomID,
+			BotId:     hook.BotID,
+			Url:       hook.URL,
+			Events:    events,
+			CreatedAt: hook.CreatedAt,
+		},
+		Secret: hook.Secret,
+	}, nil
+}
+
+// DeleteOutgoingWebhook 删除外发 Webhook
+func (s *ChatServiceImpl) DeleteOutgoingWebhook(ctx context.Context, req *chat.DeleteOutgoingWebhookReq) (*chat.DeleteOutgoingWebhookResp, error) {
+	userID, ok := authorize(ctx, "")
+	if !ok {
+		return &chat.DeleteOutgoingWebhookResp{
+			Code:    utils.CodeUnauthorized,
+			Message: "unauthorized",
+		}, nil
+	}
+
+	webhookDAO := dao.NewWebhookDAO(dao.DB)
+	hook, err := webhookDAO.GetOutgoing(req.WebhookId)
+	if err != nil {
+		return &chat.DeleteOutgoingWebhookResp{
+			Code:    utils.CodeServerError,
+			Message: "database error",
+		}, nil
+	}
+	if hook == nil {
+		return &chat.DeleteOutgoingWebhookResp{
+			Code:    utils.CodeNotFound,
+			Message: "webhook not found",
+		}, nil
+	}
+
+	if code, message := canManageWebhook(ctx, userID, hook.CreatorID, hook.RoomID); code != utils.CodeSuccess {
+		return &chat.DeleteOutgoingWebhookResp{
+			Code:    code,
+			Message: message,
+		}, nil
+	}
+
+	if err := webhookDAO.DeleteOutgoing(hook.WebhookID); err != nil {
+		return &chat.DeleteOutgoingWebhookResp{
+			Code:    utils.CodeServerError,
+			Message: "failed to delete webhook",
+		}, nil
+	}
+
+	return &chat.DeleteOutgoingWebhookResp{
+		Code:    utils.CodeSuccess,
+		Message: "success",
+	}, nil
+}
+
+// canManageWebhook Webhook 创建者或具备修改房间权限的成员可以管理
+func canManageWebhook(ctx context.Context, userID, creatorID, roomID string) (int32, string) {
+	if userID == creatorID {
+		return utils.CodeSuccess, ""
+	}
+	_, code, message := authorizeRoom(ctx, userID, roomID, PermEditRoom)
+	return code, message
+}
+
+// creatorCanSend 判断 Webhook 创建者是否仍可在房间发言。创建者离开、被移出或被禁言后，
+// 其创建的 Webhook 随之失效，重新获得发言权限后自动恢复
+func creatorCanSend(roomID, creatorID string) (bool, error) {
+	room, err := dao.NewRoomDAO(dao.DB).GetByID(roomID)
+	if err != nil || room == nil {
+		return false, err
+	}
+	member, err := dao.NewRoomMemberDAO(dao.DB).GetMember(roomID, creatorID)
+	if err != nil || member == nil {
+		return false, err
+	}
+	return roleCan(room, member.Role, PermSend), nil
+}
+
+// ensureBotMember 校验机器人归属调用方，并确保其在房间中
+func ensureBotMember(ownerID, botID, roomID string) (int32, string) {
+	bot, err := dao.NewUserDAO(dao.DB).GetByID(botID)
+	if err != nil {
+		return utils.CodeServerError, "database error"
+	}
+	if bot == nil || !bot.IsBot() || bot.OwnerID != ownerID {
+		return utils.CodeParamError, "bot not found"
+	}
+
+	roomMemberDAO := dao.NewRoomMemberDAO(dao.DB)
+	isMember, err := roomMemberDAO.IsMember(roomID, botID)
+	if err != nil {
+		return utils.CodeServerError, "database error"
+	}
+	if !isMember {
+		banned, err := dao.NewRoomBanDAO(dao.DB).IsBanned(roomID, botID)
+		if err != nil {
+			return utils.CodeServerError, "database error"
+		}
+		if banned {
+			return utils.CodeBannedFromRoom, "bot is banned from room"
+		}
+		if err := roomMemberDAO.AddMember(roomID, botID); err != nil {
+			return utils.CodeServerError, "failed to join room"
+		}
+		dao.NewRoomDAO(dao.DB).UpdateUserCount(roomID, 1)
+	}
+	return utils.CodeSuccess, "success"
+}
+
+// WebhookEvent 外发 Webhook 的请求体
+type WebhookEvent struct {
+	WebhookID string      `json:"webhook_id"`
+	Event     string      `json:"event"`
+	RoomID    string      `json:"room_id"`
+	Timestamp int64       `json:"timestamp"`
+	Data      interface{} `json:"data"`
+}
+
+// WebhookReply Webhook 响应体，content 非空时以机器人身份发送到房间
+type WebhookReply struct {
+	Content string `json:"content"`
+	MsgType int32  `json:"msg_type"`
+}
+
+// Webhook 投递并发与队列长度
+const (
+	webhookWorkers   = 32
+	webhookQueueSize = 1024
+)
+
+// WebhookDispatcher 订阅房间广播并投递到外发 Webhook
+type WebhookDispatcher struct {
+	svc     *ChatServiceImpl
+	cfg     conf.WebhookConfig
+	client  *http.Client
+	events  chan *WSMessage       // 房间广播订阅
+	queue   chan *WSMessage       // 待投递的事件，由 worker 查找 Webhook 并投递
+	dropped uint64                // 投递队列满被丢弃的事件数，原子读写
+	sleep   func(d time.Duration) // 重试等待，测试时可替换
+	now     func() time.Time      // 签名时间戳，测试时可替换
+
+	// 以下为数据库与消息发送操作，测试时可替换
+	listHooks      func(roomID string) ([]*model.OutgoingWebhook, error)
+	creatorActive  func(roomID, creatorID string) (bool, error)
+	saveDeadLetter func(letter *model.WebhookDeadLetter) error
+	sendReply      func(ctx context.Context, req *chat.SendMessageReq) (*chat.SendMessageResp, error)
+}
+
+// NewWebhookDispatcher 创建 Webhook 投递器
+func NewWebhookDispatcher(svc *ChatServiceImpl, cfg conf.WebhookConfig) *WebhookDispatcher {
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 1
+	}
+	d := &WebhookDispatcher{
+		svc:    svc,
+		cfg:    cfg,
+		client: newWebhookClient(cfg.Timeout),
+		events: make(chan *WSMessage, 256),
+		queue:  make(chan *WSMessage, webhookQueueSize),
+		sleep:  time.Sleep,
+		now:    time.Now,
+		listHooks: func(roomID string) ([]*model.OutgoingWebhook, error) {
+			return dao.NewWebhookDAO(dao.DB).ListOutgoingByRoom(roomID)
+		},
+		creatorActive: creatorCanSend,
+		saveDeadLetter: func(letter *model.WebhookDeadLetter) error {
+			return dao.NewWebhookDAO(dao.DB).CreateDeadLetter(letter)
+		},
+	}
+	if svc != nil {
+		d.sendReply = svc.SendMessageWithWS
+	}
+	return d
+}
+
+// Run 订阅房间广播并投递事件
+func (d *WebhookDispatcher) Run() {
+	GlobalWSManager.Subscribe(d.events)
+
+	for i := 0; i < webhookWorkers; i++ {
+		go d.work()
+	}
+	d.forward()
+}
+
+// forward 将订阅到的事件转入投递队列。这里不做任何阻塞操作，避免订阅队列积压导致 hub 丢弃事件；
+// 投递队列满时丢弃并计数
+func (d *WebhookDispatcher) forward() {
+	for message := range d.events {
+		if !containsString(webhookEvents, message.Type) || message.RoomID == "" {
+			continue
+		}
+		select {
+		case d.queue <- message:
+		default:
+			dropped := atomic.AddUint64(&d.dropped, 1)
+			log.Printf("Webhook queue full, event dropped: room=%s, type=%s, dropped=%d", message.RoomID, message.Type, dropped)
+		}
+	}
+}
+
+// work 依次处理投递队列中的事件，worker 数量即并发投递上限
+func (d *WebhookDispatcher) work() {
+	for message := range d.queue {
+		d.dispatch(message)
+	}
+}
+
+// Dropped 返回因投递队列满被丢弃的事件数
+func (d *WebhookDispatcher) Dropped() uint64 {
+	return atomic.LoadUint64(&d.dropped)
+}
+
+// dispatch 查找房间内订阅该事件的 Webhook 并依次投递，创建者已不能在房间发言的 Webhook 跳过。
+// 机器人与未绑定机器人的传入 Webhook 发出的消息不投递，避免 Webhook 与机器人之间无限互相回复
+func (d *WebhookDispatcher) dispatch(message *WSMessage) {
+	senderID := eventUserID(message)
+	if msg, ok := message.Data.(*chat.MessageInfo); ok && msg.Sender != nil {
+		if msg.Sender.IsBot || msg.Sender.UserId == "" {
+			return
+		}
+		senderID = msg.Sender.UserId
+	} else if message.Type == "message" {
+		return
+	}
+
+	hooks, err := d.listHooks(message.RoomID)
+	if err != nil {
+		log.Printf("Failed to list webhooks: room=%s, err=%v", message.RoomID, err)
+		return
+	}
+
+	active := make(map[string]bool) // creatorID -> 是否仍可发言
+	for _, hook := range hooks {
+		// 不把机器人自己的回复再投递给它
+		if hook.BotID == senderID || !containsString(strings.Split(hook.Events, ","), message.Type) {
+			continue
+		}
+
+		canSend, checked := active[hook.CreatorID]
+		if !checked {
+			if canSend, err = d.creatorActive(message.RoomID, hook.CreatorID); err != nil {
+				log.Printf("Failed to check webhook creator: webhook=%s, err=%v", hook.WebhookID, err)
+				continue
+			}
+			active[hook.CreatorID] = canSend
+		}
+		if !canSend {
+			continue
+		}
+
+		body, err := json.Marshal(&WebhookEvent{
+			WebhookID: hook.WebhookID,
+			Event:     message.Type,
+			RoomID:    message.RoomID,
+			Timestamp: utils.GetCurrentTimestamp(),
+			Data:      message.Data,
+		})
+		if err != nil {
+			log.Printf("Failed to marshal webhook event: %v", err)
+			continue
+		}
+		d.Deliver(hook, message.Type, body)
+	}
+}
+
+// Deliver 按指数退避重试投递，全部失败后写入死信表
+func (d *WebhookDispatcher) Deliver(hook *model.OutgoingWebhook, event string, body []byte) {
+	backoff := d.cfg.InitialBackoff
+	var lastErr error
+
+	attempt := 1
+	for ; attempt <= d.cfg.MaxAttempts; attempt++ {
+		reply, retry, err := d.post(hook, event, body)
+		if err == nil {
+			d.handleReply(hook, reply)
+			return
+		}
+		lastErr = err
+		if !retry || attempt == d.cfg.MaxAttempts {
+			break
+		}
+
+		log.Printf("Webhook delivery failed, retrying: webhook=%s, attempt=%d, err=%v", hook.WebhookID, attempt, err)
+		d.sleep(backoff)
+		if backoff *= 2; d.cfg.MaxBackoff > 0 && backoff > d.cfg.MaxBackoff {
+			backoff = d.cfg.MaxBackoff
+		}
+	}
+
+	log.Printf("Webhook delivery dead-lettered: webhook=%s, err=%v", hook.WebhookID, lastErr)
+	err := d.saveDeadLetter(&model.WebhookDeadLetter{
+		WebhookID: hook.WebhookID,
+		Event:     event,
+		Payload:   string(body),
+		Attempts:  attempt,
+		LastError: lastErr.Error(),
+	})
+	if err != nil {
+		log.Printf("Failed to save dead letter: webhook=%s, err=%v", hook.WebhookID, err)
+	}
+}
+
+// post 发送一次请求；retry 表示失败是否可重试（网络错误、429、5xx）
+func (d *WebhookDispatcher) post(hook *model.OutgoingWebhook, event string, body []byte) (*WebhookReply, bool, error) {
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, false, err
+	}
+
+	timestamp := strconv.FormatInt(d.now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookHeaderEvent, event)
+	req.Header.Set(WebhookHeaderID, hook.WebhookID)
+	req.Header.Set(WebhookHeaderTimestamp, timestamp)
+	req.Header.Set(WebhookHeaderSignature, SignWebhook(hook.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		// 目标地址不合法时重试也不会成功
+		return nil, !errors.Is(err, errWebhookAddrForbidden), err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, webhookMaxReplySize))
+	if err != nil {
+		return nil, true, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		return nil, retry, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var reply WebhookReply
+	if len(bytes.TrimSpace(data)) > 0 {
+		if err := json.Unmarshal(data, &reply); err != nil {
+			log.Printf("Ignoring non-JSON webhook reply: webhook=%s", hook.WebhookID)
+		}
+	}
+	return &reply, false, nil
+}
+
+// handleReply 将 Webhook 回复以机器人身份保存并广播
+func (d *WebhookDispatcher) handleReply(hook *model.OutgoingWebhook, reply *WebhookReply) {
+	if reply == nil || strings.TrimSpace(reply.Content) == "" {
+		return
+	}
+	msgType, ok := userMsgType(reply.MsgType)
+	if !ok {
+		log.Printf("Ignoring webhook reply with invalid msg_type: webhook=%s, msg_type=%d", hook.WebhookID, reply.MsgType)
+		return
+	}
+
+	ctx := WithClaims(context.Background(), &utils.TokenClaims{
+		UserID: hook.BotID,
+		Type:   utils.TokenTypeBot,
+	})
+	resp, err := d.sendReply(ctx, &chat.SendMessageReq{
+		RoomId:  hook.RoomID,
+		Content: reply.Content,
+		MsgType: msgType,
+	})
+	if err != nil || resp.Code != utils.CodeSuccess {
+		log.Printf("Failed to post webhook reply: webhook=%s, resp=%v, err=%v", hook.WebhookID, resp, err)
+	}
+}
+
+// SignWebhook 计算签名：sha256=hex(HMAC-SHA256(secret, timestamp + "." + body))
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func containsString(list []string, target string) bool {
+	for _, item := range list {
+		if item == target {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,230 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/baijianruoli/bot_chat/backend/internal/dao"
+	"github.com/baijianruoli/bot_chat/backend/internal/model"
+	"github.com/baijianruoli/bot_chat/backend/internal/utils"
+	chat "github.com/baijianruoli/bot_chat/backend/kitex_gen/chat"
+)
+
+// incomingMaxContentLen 传入消息内容上限（字符）
+const incomingMaxContentLen = 4000
+
+// IncomingWebhookPayload 传入 Webhook 的请求体
+type IncomingWebhookPayload struct {
+	Content          string `json:"content"`
+	MsgType          int32  `json:"msg_type"`
+	UsernameOverride string `json:"username_override"`
+}
+
+// CreateIncomingWebhook 为房间创建传入 Webhook
+func (s *ChatServiceImpl) CreateIncomingWebhook(ctx context.Context, req *chat.CreateIncomingWebhookReq) (*chat.CreateIncomingWebhookResp, error) {
+	userID, ok := authorize(ctx, "")
+	if !ok {
+		return &chat.CreateIncomingWebhookResp{
+			Code:    utils.CodeUnauthorized,
+			Message: "unauthorized",
+		}, nil
+	}
+
+	// 只有可以发言的房间成员可以配置 Webhook
+	if access, code, message := authorizeRoom(ctx, userID, req.RoomId, PermSend); access == nil {
+		return &chat.CreateIncomingWebhookResp{
+			Code:    code,
+			Message: message,
+		}, nil
+	}
+
+	// 指定集成用户时以该机器人身份发言，否则以系统消息发出
+	if req.BotId != "" {
+		if code, message := ensureBotMember(userID, req.BotId, req.RoomId); code != utils.CodeSuccess {
+			return &chat.CreateIncomingWebhookResp{
+				Code:    code,
+				Message: message,
+			}, nil
+		}
+	}
+
+	token := utils.RandomToken(24)
+	hook := &model.IncomingWebhook{
+		WebhookID: "wh_" + utils.GenerateUUID()[:12],
+		RoomID:    req.RoomId,
+		Name:      req.Name,
+		BotID:     req.BotId,
+		TokenHash: utils.SHA256(token),
+		CreatorID: userID,
+	}
+	if err := dao.NewWebhookDAO(dao.DB).CreateIncoming(hook); err != nil {
+		return &chat.CreateIncomingWebhookResp{
+			Code:    utils.CodeServerError,
+			Message: "failed to create webhook",
+		}, nil
+	}
+
+	return &chat.CreateIncomingWebhookResp{
+		Code:    utils.CodeSuccess,
+		Message: "success",
+		Webhook: &chat.WebhookInfo{
+			WebhookId: hook.WebhookID,
+			RoomId:    hook.RoomID,
+			BotId:     hook.BotID,
+			Url:       "/api/hooks/" + hook.WebhookID + "/" + token,
+			Name:      hook.Name,
+			CreatedAt: hook.CreatedAt,
+		},
+		Token: token,
+	}, nil
+}
+
+// DeleteIncomingWebhook 删除传入 Webhook
+func (s *ChatServiceImpl) DeleteIncomingWebhook(ctx context.Context, req *chat.DeleteIncomingWebhookReq) (*chat.DeleteIncomingWebhookResp, error) {
+	userID, ok := authorize(ctx, "")
+	if !ok {
+		return &chat.DeleteIncomingWebhookResp{
+			Code:    utils.CodeUnauthorized,
+			Message: "unauthorized",
+		}, nil
+	}
+
+	webhookDAO := dao.NewWebhookDAO(dao.DB)
+	hook, err := webhookDAO.GetIncoming(req.WebhookId)
+	if err != nil {
+		return &chat.DeleteIncomingWebhookResp{
+			Code:    utils.CodeServerError,
+			Message: "database error",
+		}, nil
+	}
+	if hook == nil {
+		return &chat.DeleteIncomingWebhookResp{
+			Code:    utils.CodeNotFound,
+			Message: "webhook not found",
+		}, nil
+	}
+
+	if code, message := canManageWebhook(ctx, userID, hook.CreatorID, hook.RoomID); code != utils.CodeSuccess {
+		return &chat.DeleteIncomingWebhookResp{
+			Code:    code,
+			Message: message,
+		}, nil
+	}
+
+	if err := webhookDAO.DeleteIncoming(hook.WebhookID); err != nil {
+		return &chat.DeleteIncomingWebhookResp{
+			Code:    utils.CodeServerError,
+			Message: "failed to delete webhook",
+		}, nil
+	}
+
+	return &chat.DeleteIncomingWebhookResp{
+		Code:    utils.CodeSuccess,
+		Message: "success",
+	}, nil
+}
+
+// PostIncomingWebhook 凭令牌向房间写入一条消息并广播
+func (s *ChatServiceImpl) PostIncomingWebhook(ctx context.Context, webhookID, token string, payload *IncomingWebhookPayload) (*chat.SendMessageResp, error) {
+	hook, err := dao.NewWebhookDAO(dao.DB).GetIncoming(webhookID)
+	if err != nil {
+		return &chat.SendMessageResp{
+			Code:    utils.CodeServerError,
+			Message: "database error",
+		}, nil
+	}
+	if hook == nil || subtle.ConstantTimeCompare([]byte(hook.TokenHash), []byte(utils.SHA256(token))) != 1 {
+		return &chat.SendMessageResp{
+			Code:    utils.CodeUnauthorized,
+			Message: "invalid webhook token",
+		}, nil
+	}
+
+	// 创建者不能再在房间发言时 Webhook 停用
+	canSend, err := creatorCanSend(hook.RoomID, hook.CreatorID)
+	if err != nil {
+		return &chat.SendMessageResp{
+			Code:    utils.CodeServerError,
+			Message: "database error",
+		}, nil
+	}
+	if !canSend {
+		return &chat.SendMessageResp{
+			Code:    utils.CodeUnauthorized,
+			Message: "webhook disabled",
+		}, nil
+	}
+
+	content := strings.TrimSpace(payload.Content)
+	if content == "" || utf8.RuneCountInString(content) > incomingMaxContentLen {
+		return &chat.SendMessageResp{
+			Code:    utils.CodeParamError,
+			Message: "invalid content",
+		}, nil
+	}
+
+	msg := &model.Message{
+		MsgID:      utils.GenerateMsgID(),
+		RoomID:     hook.RoomID,
+		UserID:     hook.BotID,
+		Content:    content,
+		MsgType:    3,
+		SenderName: payload.UsernameOverride,
+	}
+
+	var user *model.User
+	if hook.BotID != "" {
+		// 绑定的机器人须仍是房间成员且能发言：被移出、封禁或禁言后 Webhook 停用
+		botCtx := WithClaims(ctx, &utils.TokenClaims{UserID: hook.BotID, Type: utils.TokenTypeBot})
+		if _, code, message := authorizeRoom(botCtx, hook.BotID, hook.RoomID, PermSend); code != utils.CodeSuccess {
+			return &chat.SendMessageResp{
+				Code:    code,
+				Message: message,
+			}, nil
+		}
+
+		// 集成用户发言，消息类型由调用方决定，但不能伪造系统消息
+		msgType, ok := userMsgType(payload.MsgType)
+		if !ok {
+			return &chat.SendMessageResp{
+				Code:    utils.CodeParamError,
+				Message: "invalid msg_type",
+			}, nil
+		}
+		msg.MsgType = msgType
+
+		user, err = dao.NewUserDAO(dao.DB).GetByID(hook.BotID)
+		if err != nil {
+			return &chat.SendMessageResp{
+				Code:    utils.CodeServerError,
+				Message: "database error",
+			}, nil
+		}
+	} else if msg.SenderName == "" {
+		msg.SenderName = hook.Name
+	}
+
+	if err := dao.NewMessageDAO(dao.DB).Create(msg); err != nil {
+		return &chat.SendMessageResp{
+			Code:    utils.CodeServerError,
+			Message: "failed to send message",
+		}, nil
+	}
+
+	info := toMessageInfo(msg, user)
+	GlobalWSManager.Broadcast(&WSMessage{
+		Type:   EventMessage,
+		Seq:    msg.Seq,
+		RoomID: hook.RoomID,
+		UserID: msg.UserID,
+		Data:   info,
+	})
+
+	return &chat.SendMessageResp{
+		Code:    utils.CodeSuccess,
+		Message: "success",
+		Msg:     info,
+	}, nil
+}
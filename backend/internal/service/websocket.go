@@ -1,54 +1,147 @@
 package service
 
 import (
+	"context"
 	"encoding/json"
 	"log"
 	"net/http"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/baijianruoli/bot_chat/backend/internal/conf"
+	"github.com/baijianruoli/bot_chat/backend/internal/utils"
 	"github.com/gorilla/websocket"
 )
 
 // WebSocket 连接管理器
 type WSManager struct {
-	clients    map[string]*WSClient // userID -> client
-	rooms      map[string]map[string]*WSClient // roomID -> userID -> client
-	broadcast  chan *WSMessage
-	register   chan *WSClient
-	unregister chan *WSClient
-	mu         sync.RWMutex
+	conns       map[string]*WSClient            // connID -> client
+	users       map[string]map[string]*WSClient // userID -> connID -> client，同一用户可有多个设备
+	rooms       map[string]map[string]*WSClient // roomID -> connID -> client
+	lastSeen    map[string]int64                // userID -> 最后一个连接断开的时间（毫秒）
+	typing      map[string]map[string]int64     // roomID -> userID -> 输入状态过期时间（毫秒）
+	broadcast   chan *WSMessage
+	register    chan *WSClient
+	unregister  chan *WSClient
+	subscribers []chan<- *WSMessage // 进程内订阅者（机器人等）
+	cfg         conf.WebSocketConfig
+	clock       Clock
+	stats       wsCounters
+	broker      Broker          // 跨节点消息总线
+	nodeID      string          // 本节点ID
+	outbox      chan *WSMessage // 待发布到消息总线的本节点消息
+	dirty       chan string     // 本节点在线用户有变化的房间
+	mu          sync.RWMutex
+}
+
+// WSStats 慢消费者统计
+type WSStats struct {
+	DroppedFrames   uint64 `json:"dropped_frames"`   // 因发送队列满被丢弃的帧
+	SlowDisconnects uint64 `json:"slow_disconnects"` // 因发送队列满被断开的连接
+	SubscriberDrops uint64 `json:"subscriber_drops"` // 因进程内订阅者队列满被丢弃的事件
+}
+
+// wsCounters WSStats 的原子计数器
+type wsCounters struct {
+	droppedFrames   uint64
+	slowDisconnects uint64
+	subscriberDrops uint64
 }
 
 // WSClient WebSocket 客户端
 type WSClient struct {
-	manager *WSManager
-	conn    *websocket.Conn
-	send    chan []byte
-	userID  string
-	roomID  string
+	manager   *WSManager
+	conn      wsConn
+	send      chan []byte
+	connID    string
+	userID    string
+	device    string
+	connected int64                   // 建立连接的时间（毫秒）
+	lastSeen  int64                   // 最近一次收到客户端应用帧的时间（毫秒），pong 不计入，原子读写
+	dropped   uint64                  // 该连接被丢弃的帧数，原子读写
+	closing   int32                   // 已因慢消费被断开，原子读写
+	rooms     map[string]bool         // 已订阅的房间，以下五项由 manager.mu 保护
+	focus     string                  // 当前正在查看的房间
+	unread    map[string]int          // 已订阅但未在查看的房间的未读数
+	resuming  map[string][]*WSMessage // 正在补齐历史的房间，期间的新消息先缓存
+	typingAt  map[string]int64        // roomID -> 上次广播 typing_start 的时间（毫秒）
+	claims    *utils.TokenClaims
+	onRequest WSRequestHandler
 }
 
-// WSMessage WebSocket 消息
+// wsConn 客户端连接用到的操作，由 *websocket.Conn 实现，测试时可替换
+type wsConn interface {
+	SetReadLimit(limit int64)
+	SetReadDeadline(t time.Time) error
+	SetWriteDeadline(t time.Time) error
+	SetPongHandler(h func(appData string) error)
+	ReadMessage() (messageType int, p []byte, err error)
+	WriteMessage(messageType int, data []byte) error
+	Close() error
+}
+
+// WSRequestHandler 处理已通过校验的客户端操作
+type WSRequestHandler func(c *WSClient, req *WSRequest)
+
+// WSMessage 服务端下发的 WebSocket 帧
 type WSMessage struct {
-	Type    string      `json:"type"`    // message, join, leave, system
-	RoomID  string      `json:"room_id"`
-	UserID  string      `json:"user_id"`
-	Data    interface{} `json:"data"`
+	V      int         `json:"v"`
+	Type   string      `json:"type"`          // 见 Event* 常量
+	ID     string      `json:"id,omitempty"`  // ack/error 帧对应的客户端请求ID
+	Seq    int64       `json:"seq,omitempty"` // message 帧的房间消息序号
+	RoomID string      `json:"room_id"`
+	UserID string      `json:"user_id"`
+	Data   interface{} `json:"data"`
+
+	To     []string `json:"-"` // 非空时只投递给房间内这些用户的连接
+	Except string   `json:"-"` // 不投递给该用户的连接
+	remote bool     // 来自其他节点，只投递给本节点的连接
 }
 
 // NewWSManager 创建 WebSocket 管理器
 func NewWSManager() *WSManager {
 	return &WSManager{
-		clients:    make(map[string]*WSClient),
+		conns:      make(map[string]*WSClient),
+		users:      make(map[string]map[string]*WSClient),
 		rooms:      make(map[string]map[string]*WSClient),
+		lastSeen:   make(map[string]int64),
+		typing:     make(map[string]map[string]int64),
 		broadcast:  make(chan *WSMessage, 256),
 		register:   make(chan *WSClient),
 		unregister: make(chan *WSClient),
+		cfg:        conf.DefaultWebSocketConfig(),
+		clock:      realClock{},
+		broker:     NewMemoryBroker(),
+		nodeID:     "n_" + utils.GenerateUUID()[:8],
+		outbox:     make(chan *WSMessage, 1024),
+		dirty:      make(chan string, 1024),
 	}
 }
 
+// Configure 设置心跳与超时，需在接受连接之前调用
+func (m *WSManager) Configure(cfg conf.WebSocketConfig) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.cfg = cfg
+}
+
+// UseBroker 替换跨节点消息总线，需在 Run 之前调用
+func (m *WSManager) UseBroker(broker Broker) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.broker = broker
+}
+
 // Run 启动 WebSocket 管理器
 func (m *WSManager) Run() {
+	go m.receiveRemote()
+	go m.publishLocal()
+	go m.syncOnline()
+	go m.expireTyping()
+
 	for {
 		select {
 		case client := <-m.register:
@@ -68,18 +161,14 @@ func (m *WSManager) handleRegister(client *WSClient) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	// 注册到全局客户端
-	m.clients[client.userID] = client
-
-	// 注册到房间
-	if client.roomID != "" {
-		if m.rooms[client.roomID] == nil {
-			m.rooms[client.roomID] = make(map[string]*WSClient)
-		}
-		m.rooms[client.roomID][client.userID] = client
+	// 注册连接并建立用户索引，房间通过 SubscribeRoom 订阅
+	m.conns[client.connID] = client
+	if m.users[client.userID] == nil {
+		m.users[client.userID] = make(map[string]*WSClient)
 	}
+	m.users[client.userID][client.connID] = client
 
-	log.Printf("WebSocket client registered: user=%s, room=%s", client.userID, client.roomID)
+	log.Printf("WebSocket client registered: user=%s, conn=%s, device=%s", client.userID, client.connID, client.device)
 }
 
 // handleUnregister 处理客户端注销
@@ -87,81 +176,500 @@ func (m *WSManager) handleUnregister(client *WSClient) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	// 从全局客户端移除
-	if _, ok := m.clients[client.userID]; ok {
-		delete(m.clients, client.userID)
-		close(client.send)
+	// 只有 hub 在此处关闭发送队列，重复注销直接忽略
+	if m.conns[client.connID] != client {
+		return
 	}
-
-	// 从房间移除
-	if client.roomID != "" {
-		if room, ok := m.rooms[client.roomID]; ok {
-			delete(room, client.userID)
-			if len(room) == 0 {
-				delete(m.rooms, client.roomID)
-			}
+	delete(m.conns, client.connID)
+	close(client.send)
+
+	if devices, ok := m.users[client.userID]; ok {
+		delete(devices, client.connID)
+		if len(devices) == 0 {
+			delete(m.users, client.userID)
+			m.lastSeen[client.userID] = m.clock.Now().UnixMilli()
 		}
 	}
 
-	log.Printf("WebSocket client unregistered: user=%s", client.userID)
+	// 从已订阅的房间移除
+	for roomID := range client.rooms {
+		m.removeFromRoom(client, roomID)
+	}
+
+	log.Printf("WebSocket client unregistered: user=%s, conn=%s, dropped=%d",
+		client.userID, client.connID, atomic.LoadUint64(&client.dropped))
 }
 
 // handleBroadcast 处理广播消息
 func (m *WSManager) handleBroadcast(message *WSMessage) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.fanout(message)
+}
 
+// fanout 向房间内所有连接及进程内订阅者投递消息，调用方需持有写锁
+func (m *WSManager) fanout(message *WSMessage) {
+	if message.V == 0 {
+		message.V = WSProtocolVersion
+	}
 	data, err := json.Marshal(message)
 	if err != nil {
 		log.Printf("Failed to marshal message: %v", err)
 		return
 	}
 
-	// 如果是房间消息，只广播给房间内的客户端
+	// 如果是房间消息，只广播给房间内的连接（同一用户的每个设备都会收到）
 	if message.RoomID != "" {
+		var to map[string]bool
+		if len(message.To) > 0 {
+			to = make(map[string]bool, len(message.To))
+			for _, userID := range message.To {
+				to[userID] = true
+			}
+		}
+
 		if room, ok := m.rooms[message.RoomID]; ok {
 			for _, client := range room {
-				select {
-				case client.send <- data:
-				default:
-					// 客户端发送缓冲区满，关闭连接
-					close(client.send)
+				if (to != nil && !to[client.userID]) || client.userID == message.Except {
+					continue
+				}
+				// 正在补齐历史的连接先缓存新消息，补齐后按序号投递
+				if pending, ok := client.resuming[message.RoomID]; ok && message.Type == EventMessage {
+					client.resuming[message.RoomID] = append(pending, message)
+				} else if !m.enqueue(client, data) {
+					continue
+				}
+
+				// 未在查看该房间的连接累加未读数并推送角标
+				if message.Type == EventMessage && client.focus != message.RoomID && client.userID != message.UserID {
+					client.unread[message.RoomID]++
+					m.pushUnread(client, message.RoomID)
 				}
 			}
 		}
+
+		// 进程内订阅者只处理本节点产生的消息，避免机器人与 Webhook 在每个节点重复执行
+		if message.remote {
+			return
+		}
+		for _, ch := range m.subscribers {
+			select {
+			case ch <- message:
+			default:
+				atomic.AddUint64(&m.stats.subscriberDrops, 1)
+				log.Printf("Subscriber queue full, message dropped: room=%s, type=%s", message.RoomID, message.Type)
+			}
+		}
+	}
+}
+
+// Subscribe 订阅所有房间广播，订阅者处理不及时的消息会被丢弃
+func (m *WSManager) Subscribe(ch chan<- *WSMessage) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.subscribers = append(m.subscribers, ch)
+}
+
+// SendToClient 只向指定连接发送消息，连接已注销时丢弃
+func (m *WSManager) SendToClient(client *WSClient, message *WSMessage) {
+	message.V = WSProtocolVersion
+	payload, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("Failed to marshal message: %v", err)
+		return
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.conns[client.connID] != client {
+		return
+	}
+	m.enqueue(client, payload)
+}
+
+// enqueue 向连接的发送队列投递一帧，队列满时按慢消费者策略处理，调用方需持有锁（读锁即可）。
+// 这里从不关闭发送队列：断开时只关闭底层连接，由 readPump 经 hub 统一注销
+func (m *WSManager) enqueue(client *WSClient, data []byte) bool {
+	select {
+	case client.send <- data:
+		return true
+	default:
+	}
+
+	if m.cfg.SlowConsumer == conf.SlowConsumerDropOldest {
+		// 丢弃最旧的一帧后重试一次
+		select {
+		case <-client.send:
+			m.countDropped(client)
+		default:
+		}
+		select {
+		case client.send <- data:
+			return true
+		default:
+			m.countDropped(client)
+			return false
+		}
+	}
+
+	m.countDropped(client)
+	if atomic.CompareAndSwapInt32(&client.closing, 0, 1) {
+		atomic.AddUint64(&m.stats.slowDisconnects, 1)
+		log.Printf("Slow consumer disconnected: user=%s, conn=%s", client.userID, client.connID)
+		client.conn.Close()
+	}
+	return false
+}
+
+// countDropped 记录丢弃的帧
+func (m *WSManager) countDropped(client *WSClient) {
+	atomic.AddUint64(&m.stats.droppedFrames, 1)
+	atomic.AddUint64(&client.dropped, 1)
+}
+
+// Stats 返回慢消费者统计
+func (m *WSManager) Stats() WSStats {
+	return WSStats{
+		DroppedFrames:   atomic.LoadUint64(&m.stats.droppedFrames),
+		SlowDisconnects: atomic.LoadUint64(&m.stats.slowDisconnects),
+		SubscriberDrops: atomic.LoadUint64(&m.stats.subscriberDrops),
+	}
+}
+
+// SubscribeRoom 连接订阅房间事件，一个连接可同时订阅多个房间
+func (m *WSManager) SubscribeRoom(client *WSClient, roomID string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.conns[client.connID] != client {
+		return false
+	}
+	m.subscribe(client, roomID)
+	return true
+}
+
+// BeginResume 订阅房间并开始缓存该房间的新消息，调用方补齐历史后需调用 EndResume
+func (m *WSManager) BeginResume(client *WSClient, roomID string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.conns[client.connID] != client {
+		return false
+	}
+	client.resuming[roomID] = []*WSMessage{}
+	m.subscribe(client, roomID)
+	return true
+}
+
+// EndResume 结束补齐并投递缓存的帧，之后恢复实时投递。
+// 只丢弃已补齐（序号不大于 lastSeq）的新消息帧，其余无序号的事件帧照常投递
+func (m *WSManager) EndResume(client *WSClient, roomID string, lastSeq int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	pending := client.resuming[roomID]
+	delete(client.resuming, roomID)
+	if m.conns[client.connID] != client {
+		return
+	}
+
+	for _, message := range pending {
+		if message.Type == EventMessage && message.Seq > 0 && message.Seq <= lastSeq {
+			continue
+		}
+		data, err := json.Marshal(message)
+		if err != nil {
+			log.Printf("Failed to marshal message: %v", err)
+			continue
+		}
+		m.enqueue(client, data)
+	}
+}
+
+const (
+	resumeMaxMessages = 1000 // 单次 resume 最多补发的消息数，超出时客户端应改用 GetHistory 重新加载
+	resumePageSize    = 100  // 每次从存储读取的消息数
+)
+
+// ReplayPage 按序号升序返回 afterSeq 之后最多 limit 条待补发的消息
+type ReplayPage func(afterSeq int64, limit int) ([]*WSMessage, error)
+
+// Replay 订阅房间并补发 afterSeq 之后的消息，补发完成后才恢复实时投递。
+// 补发不走慢消费者策略：发送队列将满或超过 resumeMaxMessages 时停止，truncated 为 true，
+// 客户端可从返回的 lastSeq（已补发的最后一条）继续 resume
+func (m *WSManager) Replay(client *WSClient, roomID string, afterSeq int64, page ReplayPage) (lastSeq int64, truncated bool, err error) {
+	if !m.BeginResume(client, roomID) {
+		return afterSeq, false, nil
+	}
+
+	lastSeq = afterSeq
+	defer func() {
+		m.EndResume(client, roomID, lastSeq)
+	}()
+
+	for replayed := 0; replayed < resumeMaxMessages; {
+		messages, err := page(lastSeq, resumePageSize)
+		if err != nil {
+			return lastSeq, false, err
+		}
+		for _, message := range messages {
+			if !m.sendReplay(client, message) {
+				return lastSeq, true, nil
+			}
+			lastSeq = message.Seq
+		}
+
+		replayed += len(messages)
+		if len(messages) < resumePageSize {
+			return lastSeq, false, nil
+		}
+	}
+	return lastSeq, true, nil
+}
+
+// sendReplay 发送队列有空位时投递一条补发消息，并为 resume 的应答保留一个空位；队列将满时返回 false
+func (m *WSManager) sendReplay(client *WSClient, message *WSMessage) bool {
+	message.V = WSProtocolVersion
+	data, err := json.Marshal(message)
+	if err != nil {
+		log.Printf("Failed to marshal message: %v", err)
+		return false
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.conns[client.connID] != client {
+		return false
+	}
+	if cap(client.send) > 1 && len(client.send) >= cap(client.send)-1 {
+		return false
+	}
+	select {
+	case client.send <- data:
+		return true
+	default:
+		return false
+	}
+}
+
+// subscribe 将连接加入房间索引，用户的第一个设备进入时通知房间，调用方需持有写锁
+func (m *WSManager) subscribe(client *WSClient, roomID string) {
+	firstDevice := !m.userInRoom(client.userID, roomID)
+	if firstDevice {
+		m.markDirty(roomID)
+	}
+	client.rooms[roomID] = true
+	if m.rooms[roomID] == nil {
+		m.rooms[roomID] = make(map[string]*WSClient)
+	}
+	m.rooms[roomID][client.connID] = client
+
+	// 用户的第一个设备进入房间时通知房间内其他人
+	if firstDevice {
+		m.emit(&WSMessage{
+			Type:   EventPresence,
+			RoomID: roomID,
+			UserID: client.userID,
+			Data: map[string]interface{}{
+				"user_id": client.userID,
+				"online":  true,
+			},
+		})
+	}
+}
+
+// UnsubscribeRoom 连接取消订阅房间
+func (m *WSManager) UnsubscribeRoom(client *WSClient, roomID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.unsubscribe(client, roomID)
+}
+
+// UnsubscribeUser 取消用户所有设备在房间的订阅（离开房间、被移出时调用）
+func (m *WSManager) UnsubscribeUser(userID, roomID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, client := range m.users[userID] {
+		m.unsubscribe(client, roomID)
+	}
+}
+
+// FocusRoom 设置连接当前查看的房间并清零其未读数，返回其余房间的未读数
+func (m *WSManager) FocusRoom(client *WSClient, roomID string) map[string]int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	client.focus = roomID
+	delete(client.unread, roomID)
+
+	unread := make(map[string]int, len(client.unread))
+	for id, count := range client.unread {
+		unread[id] = count
+	}
+	return unread
+}
+
+// IsSubscribed 连接是否订阅了房间
+func (m *WSManager) IsSubscribed(client *WSClient, roomID string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return client.rooms[roomID]
+}
+
+// FocusedRoom 连接当前查看的房间
+func (m *WSManager) FocusedRoom(client *WSClient) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return client.focus
+}
+
+// unsubscribe 清除连接的房间订阅及未读状态，调用方需持有写锁
+func (m *WSManager) unsubscribe(client *WSClient, roomID string) {
+	delete(client.rooms, roomID)
+	delete(client.unread, roomID)
+	delete(client.resuming, roomID)
+	delete(client.typingAt, roomID)
+	if client.focus == roomID {
+		client.focus = ""
+	}
+	m.removeFromRoom(client, roomID)
+}
+
+// removeFromRoom 从房间索引中移除连接，用户的最后一个设备离开时通知房间，调用方需持有写锁
+func (m *WSManager) removeFromRoom(client *WSClient, roomID string) {
+	room, ok := m.rooms[roomID]
+	if !ok || room[client.connID] != client {
+		return
+	}
+	delete(room, client.connID)
+	if len(room) == 0 {
+		delete(m.rooms, roomID)
+	}
+
+	if !m.userInRoom(client.userID, roomID) {
+		m.markDirty(roomID)
+		m.stopTyping(roomID, client.userID, false)
+		m.emit(&WSMessage{
+			Type:   EventPresence,
+			RoomID: roomID,
+			UserID: client.userID,
+			Data: map[string]interface{}{
+				"user_id":   client.userID,
+				"online":    false,
+				"last_seen": atomic.LoadInt64(&client.lastSeen),
+			},
+		})
+	}
+}
+
+// userInRoom 用户是否有设备订阅了房间，调用方需持有锁
+func (m *WSManager) userInRoom(userID, roomID string) bool {
+	for _, client := range m.rooms[roomID] {
+		if client.userID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// pushUnread 推送房间未读数，调用方需持有锁
+func (m *WSManager) pushUnread(client *WSClient, roomID string) {
+	payload, err := json.Marshal(&WSMessage{
+		V:      WSProtocolVersion,
+		Type:   EventUnread,
+		RoomID: roomID,
+		UserID: client.userID,
+		Data: map[string]interface{}{
+			"count": client.unread[roomID],
+		},
+	})
+	if err != nil {
+		return
 	}
+	m.enqueue(client, payload)
 }
 
 // BroadcastToRoom 向房间广播消息
 func (m *WSManager) BroadcastToRoom(roomID string, msgType string, data interface{}) {
-	message := &WSMessage{
+	m.Broadcast(&WSMessage{
 		Type:   msgType,
 		RoomID: roomID,
 		Data:   data,
-	}
+	})
+}
+
+// emit 在 hub 内部向本节点投递并发布到其他节点，调用方需持有写锁
+func (m *WSManager) emit(message *WSMessage) {
+	m.fanout(message)
+	m.publish(message)
+}
+
+// Broadcast 向本节点广播并发布到其他节点
+func (m *WSManager) Broadcast(message *WSMessage) {
+	// 消息会被 hub 与发布协程同时读取，投递前填好版本号
+	message.V = WSProtocolVersion
 	m.broadcast <- message
+	m.publish(message)
+}
+
+// publish 投递到发布队列，队列满时丢弃
+func (m *WSManager) publish(message *WSMessage) {
+	select {
+	case m.outbox <- message:
+	default:
+		log.Printf("Broker outbox full, message not published: room=%s, type=%s", message.RoomID, message.Type)
+	}
 }
 
-// GetOnlineCount 获取房间在线人数
+// GetOnlineCount 获取房间在线人数（所有节点，按用户去重）
 func (m *WSManager) GetOnlineCount(roomID string) int {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+	return len(m.GetOnlineUsers(roomID))
+}
 
-	if room, ok := m.rooms[roomID]; ok {
-		return len(room)
+// GetOnlineUsers 获取房间在所有节点的在线用户列表，消息总线不可用时只返回本节点
+func (m *WSManager) GetOnlineUsers(roomID string) []string {
+	users := m.localUsers(roomID)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	remote, err := m.currentBroker().RoomUsers(ctx, roomID)
+	if err != nil {
+		log.Printf("Failed to load cluster online users: room=%s, err=%v", roomID, err)
+		return users
+	}
+
+	seen := make(map[string]bool, len(users))
+	for _, userID := range users {
+		seen[userID] = true
+	}
+	for _, userID := range remote {
+		if !seen[userID] {
+			seen[userID] = true
+			users = append(users, userID)
+		}
 	}
-	return 0
+	return users
 }
 
-// GetOnlineUsers 获取房间在线用户列表
-func (m *WSManager) GetOnlineUsers(roomID string) []string {
+// localUsers 本节点房间内的在线用户
+func (m *WSManager) localUsers(roomID string) []string {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
 	var users []string
-	if room, ok := m.rooms[roomID]; ok {
-		for userID := range room {
-			users = append(users, userID)
+	seen := make(map[string]bool)
+	for _, client := range m.rooms[roomID] {
+		if !seen[client.userID] {
+			seen[client.userID] = true
+			users = append(users, client.userID)
 		}
 	}
 	return users
@@ -176,25 +684,55 @@ var upgrader = websocket.Upgrader{
 }
 
 // HandleWebSocket WebSocket 连接处理
-func (m *WSManager) HandleWebSocket(w http.ResponseWriter, r *http.Request, userID string) {
+func (m *WSManager) HandleWebSocket(w http.ResponseWriter, r *http.Request, claims *utils.TokenClaims, onRequest WSRequestHandler) {
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Printf("Failed to upgrade connection: %v", err)
 		return
 	}
 
+	m.attach(conn, claims, deviceName(r), onRequest)
+}
+
+// attach 为已建立的连接创建客户端，写入 hello 帧后注册到 hub 并启动读写 goroutine
+func (m *WSManager) attach(conn wsConn, claims *utils.TokenClaims, device string, onRequest WSRequestHandler) *WSClient {
+	cfg, clock := m.config()
+	now := clock.Now().UnixMilli()
 	client := &WSClient{
-		manager: m,
-		conn:    conn,
-		send:    make(chan []byte, 256),
-		userID:  userID,
+		manager:   m,
+		conn:      conn,
+		send:      make(chan []byte, cfg.SendBuffer),
+		connID:    "c_" + utils.GenerateUUID()[:12],
+		userID:    claims.UserID,
+		device:    device,
+		connected: now,
+		lastSeen:  now,
+		rooms:     make(map[string]bool),
+		unread:    make(map[string]int),
+		resuming:  make(map[string][]*WSMessage),
+		typingAt:  make(map[string]int64),
+		claims:    claims,
+		onRequest: onRequest,
 	}
 
+	// 注册前写入 hello 帧，告知客户端协议版本
+	hello, _ := json.Marshal(&WSMessage{
+		V:      WSProtocolVersion,
+		Type:   EventHello,
+		UserID: client.userID,
+		Data: map[string]interface{}{
+			"version": WSProtocolVersion,
+			"conn_id": client.connID,
+		},
+	})
+	client.send <- hello
+
 	m.register <- client
 
 	// 启动读写 goroutine
 	go client.writePump()
 	go client.readPump()
+	return client
 }
 
 // readPump 读取客户端消息
@@ -204,8 +742,16 @@ func (c *WSClient) readPump() {
 		c.conn.Close()
 	}()
 
+	cfg, clock := c.manager.config()
 	c.conn.SetReadLimit(512 * 1024) // 512KB
 
+	// 超过 PongWait 未收到 pong 或任何帧视为连接已断开，ReadMessage 返回错误后注销。
+	// pong 只延长读超时，不更新 lastSeen，见 idle
+	c.conn.SetReadDeadline(clock.Now().Add(cfg.PongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(clock.Now().Add(cfg.PongWait))
+	})
+
 	for {
 		_, message, err := c.conn.ReadMessage()
 		if err != nil {
@@ -214,26 +760,65 @@ func (c *WSClient) readPump() {
 			}
 			break
 		}
-
-		// 解析消息
-		var msg WSMessage
-		if err := json.Unmarshal(message, &msg); err != nil {
-			log.Printf("Failed to unmarshal message: %v", err)
+		now := clock.Now()
+		c.conn.SetReadDeadline(now.Add(cfg.PongWait))
+		atomic.StoreInt64(&c.lastSeen, now.UnixMilli())
+
+		// 只接受当前版本的已知操作，非法帧回复 error 且不会转发
+		var req WSRequest
+		if err := json.Unmarshal(message, &req); err != nil {
+			c.replyError(req.ID, utils.CodeParamError, "malformed frame")
+			continue
+		}
+		if req.V != WSProtocolVersion {
+			c.replyError(req.ID, utils.CodeParamError, "unsupported protocol version")
+			continue
+		}
+		if !wsOps[req.Op] {
+			c.replyError(req.ID, utils.CodeParamError, "unknown op: "+req.Op)
+			continue
+		}
+		if req.ID == "" {
+			c.replyError(req.ID, utils.CodeParamError, "missing request id")
 			continue
 		}
 
-		// 设置发送者
-		msg.UserID = c.userID
-		c.roomID = msg.RoomID
+		if c.onRequest != nil {
+			c.onRequest(c, &req)
+		}
+	}
+}
 
-		// 广播消息
-		c.manager.broadcast <- &msg
+// reply 回复客户端操作结果
+func (c *WSClient) reply(id string, code int32, message string, data interface{}) {
+	msgType := EventAck
+	if code != utils.CodeSuccess {
+		msgType = EventError
 	}
+	c.manager.SendToClient(c, &WSMessage{
+		Type:   msgType,
+		ID:     id,
+		UserID: c.userID,
+		Data: &WSReply{
+			Code:    code,
+			Message: message,
+			Data:    data,
+		},
+	})
 }
 
-// writePump 向客户端写入消息
+// replyError 回复 error 帧
+func (c *WSClient) replyError(id string, code int32, message string) {
+	c.reply(id, code, message, nil)
+}
+
+// writePump 向客户端写入消息并定期发送 ping。
+// 写入失败或客户端空闲超时时关闭连接，由 readPump 完成注销
 func (c *WSClient) writePump() {
+	cfg, clock := c.manager.config()
+	ticker := clock.NewTicker(cfg.PingInterval)
 	defer func() {
+		ticker.Stop()
 		c.conn.Close()
 	}()
 
@@ -241,18 +826,53 @@ func (c *WSClient) writePump() {
 		select {
 		case message, ok := <-c.send:
 			if !ok {
-				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				c.write(cfg, clock, websocket.CloseMessage, []byte{})
+				return
+			}
+			if err := c.write(cfg, clock, websocket.TextMessage, message); err != nil {
+				log.Printf("WebSocket write failed: user=%s, conn=%s, err=%v", c.userID, c.connID, err)
 				return
 			}
 
-			c.conn.WriteMessage(websocket.TextMessage, message)
+		case now := <-ticker.C():
+			if c.idle(cfg, now) {
+				log.Printf("WebSocket idle timeout: user=%s, conn=%s", c.userID, c.connID)
+				c.write(cfg, clock, websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "idle timeout"))
+				return
+			}
+			if err := c.write(cfg, clock, websocket.PingMessage, nil); err != nil {
+				return
+			}
 		}
 	}
 }
 
-// Global WSManager instance
-var GlobalWSManager = NewWSManager()
+// write 带写超时地写入一帧
+func (c *WSClient) write(cfg conf.WebSocketConfig, clock Clock, messageType int, data []byte) error {
+	c.conn.SetWriteDeadline(clock.Now().Add(cfg.WriteTimeout))
+	return c.conn.WriteMessage(messageType, data)
+}
 
-func init() {
-	go GlobalWSManager.Run()
+// idle 客户端是否超过空闲上限没有发送任何应用帧。浏览器会自动回复 pong，
+// 因此 pong 只用于判断连接存活，不算作活跃；机器人连接使用单独的上限
+func (c *WSClient) idle(cfg conf.WebSocketConfig, now time.Time) bool {
+	maxIdle := cfg.MaxIdle
+	if c.claims != nil && c.claims.Type == utils.TokenTypeBot {
+		maxIdle = cfg.BotMaxIdle
+	}
+	if maxIdle <= 0 {
+		return false
+	}
+	return now.Sub(time.UnixMilli(atomic.LoadInt64(&c.lastSeen))) > maxIdle
 }
+
+// config 读取当前配置与时钟
+func (m *WSManager) config() (conf.WebSocketConfig, Clock) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.cfg, m.clock
+}
+
+// Global WSManager instance，由 main 启动 Run
+var GlobalWSManager = NewWSManager()
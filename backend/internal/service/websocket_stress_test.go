@@ -0,0 +1,217 @@
+package service
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/baijianruoli/bot_chat/backend/internal/conf"
+)
+
+// stressConn 只记录 Close 次数的连接，读写协程不启动
+type stressConn struct {
+	closes int32
+}
+
+func (c *stressConn) SetReadLimit(limit int64)                    {}
+func (c *stressConn) SetReadDeadline(t time.Time) error           { return nil }
+func (c *stressConn) SetWriteDeadline(t time.Time) error          { return nil }
+func (c *stressConn) SetPongHandler(h func(appData string) error) {}
+func (c *stressConn) ReadMessage() (int, []byte, error)           { return 0, nil, io.EOF }
+func (c *stressConn) WriteMessage(messageType int, data []byte) error {
+	return nil
+}
+func (c *stressConn) Close() error {
+	atomic.AddInt32(&c.closes, 1)
+	return nil
+}
+
+// TestWSManagerStress 数千个连接并发注册、订阅、接收广播与重复注销，配合 -race 运行。
+// 一半连接从不读取发送队列，用于触发两种慢消费者策略
+func TestWSManagerStress(t *testing.T) {
+	for _, policy := range []string{conf.SlowConsumerDropOldest, conf.SlowConsumerDisconnect} {
+		t.Run(policy, func(t *testing.T) {
+			stressWSManager(t, policy)
+		})
+	}
+}
+
+func stressWSManager(t *testing.T, policy string) {
+	const (
+		clients     = 2000
+		users       = 500
+		rooms       = 20
+		senders     = 8
+		perSender   = 100
+		sendBuffer  = 4
+		directSends = 2000
+	)
+
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	cfg := conf.DefaultWebSocketConfig()
+	cfg.SendBuffer = sendBuffer
+	cfg.SlowConsumer = policy
+	m, _ := newTestManager(t, cfg)
+
+	// 本测试不涉及消息总线，丢弃待发布的消息与在线变化
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for {
+			select {
+			case <-m.outbox:
+			case <-m.dirty:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	conns := make([]*stressConn, clients)
+	list := make([]*WSClient, clients)
+	for i := range list {
+		conns[i] = &stressConn{}
+		list[i] = &WSClient{
+			manager:  m,
+			conn:     conns[i],
+			send:     make(chan []byte, sendBuffer),
+			connID:   fmt.Sprintf("c_%d", i),
+			userID:   fmt.Sprintf("u_%d", i%users),
+			rooms:    make(map[string]bool),
+			unread:   make(map[string]int),
+			resuming: make(map[string][]*WSMessage),
+			typingAt: make(map[string]int64),
+		}
+	}
+
+	// 及时读取的连接一直读到发送队列被关闭
+	var readers sync.WaitGroup
+	var received uint64
+	for i := 0; i < clients; i += 2 {
+		readers.Add(1)
+		go func(client *WSClient) {
+			defer readers.Done()
+			for range client.send {
+				atomic.AddUint64(&received, 1)
+			}
+		}(list[i])
+	}
+
+	// 注册并订阅，同时并发广播与单播
+	var wg sync.WaitGroup
+	for i, client := range list {
+		wg.Add(1)
+		go func(i int, client *WSClient) {
+			defer wg.Done()
+			m.register <- client
+			// register 只保证 hub 已收到，注册完成前订阅会失败
+			for !m.SubscribeRoom(client, fmt.Sprintf("r_%d", i%rooms)) {
+				runtime.Gosched()
+			}
+			if i%3 == 0 {
+				m.SubscribeRoom(client, fmt.Sprintf("r_%d", (i+1)%rooms))
+			}
+		}(i, client)
+	}
+	for s := 0; s < senders; s++ {
+		wg.Add(1)
+		go func(s int) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(int64(s)))
+			for n := 0; n < perSender; n++ {
+				m.broadcast <- &WSMessage{
+					V:      WSProtocolVersion,
+					Type:   EventMessage,
+					RoomID: fmt.Sprintf("r_%d", r.Intn(rooms)),
+					UserID: fmt.Sprintf("u_%d", r.Intn(users)),
+					Data:   map[string]interface{}{"n": n},
+				}
+			}
+		}(s)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		r := rand.New(rand.NewSource(99))
+		for n := 0; n < directSends; n++ {
+			m.SendToClient(list[r.Intn(clients)], &WSMessage{Type: EventAck, Data: n})
+		}
+	}()
+	wg.Wait()
+
+	// 每个连接并发注销两次，发送队列只能被关闭一次，同时继续广播
+	for _, client := range list {
+		wg.Add(2)
+		for k := 0; k < 2; k++ {
+			go func(client *WSClient) {
+				defer wg.Done()
+				m.unregister <- client
+			}(client)
+		}
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for n := 0; n < perSender; n++ {
+			m.broadcast <- &WSMessage{Type: EventMessage, RoomID: fmt.Sprintf("r_%d", n%rooms), Data: n}
+		}
+	}()
+	wg.Wait()
+
+	waitFor(t, "all clients unregistered", func() bool {
+		m.mu.RLock()
+		defer m.mu.RUnlock()
+		return len(m.conns) == 0 && len(m.users) == 0 && len(m.rooms) == 0
+	})
+
+	// 所有发送队列都已关闭，读取的连接全部退出
+	for i, client := range list {
+		if i%2 == 1 {
+			for range client.send {
+			}
+		}
+	}
+	readers.Wait()
+	if atomic.LoadUint64(&received) == 0 {
+		t.Fatal("no frames delivered to reading clients")
+	}
+
+	// 注销后再投递会被忽略
+	m.SendToClient(list[0], &WSMessage{Type: EventAck})
+
+	stats := m.Stats()
+	var closed uint64
+	for i, conn := range conns {
+		closes := atomic.LoadInt32(&conn.closes)
+		if closes > 1 {
+			t.Fatalf("conn %d closed %d times", i, closes)
+		}
+		closed += uint64(closes)
+	}
+
+	switch policy {
+	case conf.SlowConsumerDropOldest:
+		if stats.DroppedFrames == 0 {
+			t.Fatal("drop_oldest: no frames dropped")
+		}
+		if closed != 0 || stats.SlowDisconnects != 0 {
+			t.Fatalf("drop_oldest: closed = %d, slow disconnects = %d, want 0", closed, stats.SlowDisconnects)
+		}
+	case conf.SlowConsumerDisconnect:
+		if stats.SlowDisconnects == 0 {
+			t.Fatal("disconnect: no slow consumer disconnected")
+		}
+		if closed != stats.SlowDisconnects {
+			t.Fatalf("disconnect: closed = %d, slow disconnects = %d", closed, stats.SlowDisconnects)
+		}
+	}
+}
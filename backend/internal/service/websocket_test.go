@@ -0,0 +1,512 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/baijianruoli/bot_chat/backend/internal/conf"
+	"github.com/baijianruoli/bot_chat/backend/internal/utils"
+	"github.com/gorilla/websocket"
+)
+
+var (
+	errFakeConnClosed = errors.New("fake conn closed")
+	errFakeTimeout    = errors.New("fake conn i/o timeout")
+)
+
+// fakeFrame fakeConn 写出的一帧
+type fakeFrame struct {
+	messageType int
+	data        []byte
+}
+
+// fakeConn 内存中的 WebSocket 连接，读写超时按 fakeClock 判断
+type fakeConn struct {
+	clock     *fakeClock
+	incoming  chan []byte // 客户端发来的应用帧
+	pongs     chan string // 客户端回复的 pong
+	closed    chan struct{}
+	closeOnce sync.Once
+
+	mu            sync.Mutex
+	readDeadline  time.Time
+	writeDeadline time.Time
+	pongHandler   func(string) error
+	written       []fakeFrame
+	autoPong      bool // 收到 ping 时自动回复 pong，与浏览器行为一致
+	stallWrites   bool // 写入一直阻塞到写超时，模拟对端不再读取
+}
+
+func newFakeConn(clock *fakeClock) *fakeConn {
+	return &fakeConn{
+		clock:    clock,
+		incoming: make(chan []byte, 16),
+		pongs:    make(chan string, 16),
+		closed:   make(chan struct{}),
+		autoPong: true,
+	}
+}
+
+func (c *fakeConn) SetReadLimit(limit int64) {}
+
+func (c *fakeConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.readDeadline = t
+	return nil
+}
+
+func (c *fakeConn) SetWriteDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.writeDeadline = t
+	return nil
+}
+
+func (c *fakeConn) SetPongHandler(h func(appData string) error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.pongHandler = h
+}
+
+// ReadMessage 与 gorilla 一样在读取的 goroutine 中调用 pong 回调
+func (c *fakeConn) ReadMessage() (int, []byte, error) {
+	poll := time.NewTicker(time.Millisecond)
+	defer poll.Stop()
+
+	for {
+		select {
+		case data := <-c.incoming:
+			return websocket.TextMessage, data, nil
+		case appData := <-c.pongs:
+			c.mu.Lock()
+			handler := c.pongHandler
+			c.mu.Unlock()
+			if handler != nil {
+				if err := handler(appData); err != nil {
+					return 0, nil, err
+				}
+			}
+		case <-c.closed:
+			return 0, nil, errFakeConnClosed
+		case <-poll.C:
+			if c.expired(func() time.Time { return c.readDeadline }) {
+				return 0, nil, errFakeTimeout
+			}
+		}
+	}
+}
+
+func (c *fakeConn) WriteMessage(messageType int, data []byte) error {
+	for c.stalled() {
+		if c.isClosed() {
+			return errFakeConnClosed
+		}
+		if c.expired(func() time.Time { return c.writeDeadline }) {
+			return errFakeTimeout
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if c.isClosed() {
+		return errFakeConnClosed
+	}
+
+	c.mu.Lock()
+	c.written = append(c.written, fakeFrame{messageType, append([]byte(nil), data...)})
+	autoPong := c.autoPong
+	c.mu.Unlock()
+
+	if messageType == websocket.PingMessage && autoPong {
+		c.pongs <- ""
+	}
+	return nil
+}
+
+func (c *fakeConn) Close() error {
+	c.closeOnce.Do(func() { close(c.closed) })
+	return nil
+}
+
+// expired 时钟是否已到达 deadline 返回的超时时间
+func (c *fakeConn) expired(deadline func() time.Time) bool {
+	c.mu.Lock()
+	d := deadline()
+	c.mu.Unlock()
+	return !d.IsZero() && !c.clock.Now().Before(d)
+}
+
+func (c *fakeConn) stalled() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.stallWrites
+}
+
+func (c *fakeConn) isClosed() bool {
+	select {
+	case <-c.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *fakeConn) setAutoPong(autoPong bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.autoPong = autoPong
+}
+
+func (c *fakeConn) deadlines() (read, write time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.readDeadline, c.writeDeadline
+}
+
+// frames 返回写出的指定类型的帧
+func (c *fakeConn) frames(messageType int) []fakeFrame {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var frames []fakeFrame
+	for _, frame := range c.written {
+		if frame.messageType == messageType {
+			frames = append(frames, frame)
+		}
+	}
+	return frames
+}
+
+// newTestManager 创建使用 fakeClock 的管理器，并启动只处理注册、注销与广播的 hub
+func newTestManager(t *testing.T, cfg conf.WebSocketConfig) (*WSManager, *fakeClock) {
+	m := NewWSManager()
+	clock := newFakeClock()
+	m.clock = clock
+	m.cfg = cfg
+
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case client := <-m.register:
+				m.handleRegister(client)
+			case client := <-m.unregister:
+				m.handleUnregister(client)
+			case message := <-m.broadcast:
+				m.handleBroadcast(message)
+			case <-done:
+				return
+			}
+		}
+	}()
+	t.Cleanup(func() { close(done) })
+	return m, clock
+}
+
+// registered 连接是否仍在 hub 中
+func (m *WSManager) registered(client *WSClient) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.conns[client.connID] == client
+}
+
+// waitFor 等待异步条件成立
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// attachTestClient 接入一个 fakeConn 客户端并等待写协程启动
+func attachTestClient(t *testing.T, m *WSManager, clock *fakeClock, claims *utils.TokenClaims) (*WSClient, *fakeConn) {
+	t.Helper()
+
+	tickers := clock.Tickers()
+	conn := newFakeConn(clock)
+	client := m.attach(conn, claims, "test", nil)
+	waitFor(t, "client registered", func() bool { return m.registered(client) && clock.Tickers() == tickers+1 })
+	return client, conn
+}
+
+func testWSConfig() conf.WebSocketConfig {
+	cfg := conf.DefaultWebSocketConfig()
+	cfg.PingInterval = 10 * time.Second
+	cfg.PongWait = 15 * time.Second
+	cfg.WriteTimeout = 5 * time.Second
+	cfg.MaxIdle = 0
+	return cfg
+}
+
+func TestWSPingPongDeadline(t *testing.T) {
+	m, clock := newTestManager(t, testWSConfig())
+	start := clock.Now()
+	client, conn := attachTestClient(t, m, clock, &utils.TokenClaims{UserID: "u_1", Type: utils.TokenTypeAccess})
+
+	readDeadline := func() time.Time { read, _ := conn.deadlines(); return read }
+	waitFor(t, "initial read deadline", func() bool { return readDeadline().Equal(start.Add(15 * time.Second)) })
+
+	// 每次 ping 得到 pong 后读超时顺延 PongWait
+	for i := 1; i <= 2; i++ {
+		clock.Advance(10 * time.Second)
+		want := start.Add(time.Duration(i)*10*time.Second + 15*time.Second)
+		waitFor(t, "read deadline extended by pong", func() bool { return readDeadline().Equal(want) })
+		if _, write := conn.deadlines(); !write.Equal(clock.Now().Add(5 * time.Second)) {
+			t.Fatalf("ping write deadline = %v, want %v", write, clock.Now().Add(5*time.Second))
+		}
+	}
+	if !m.registered(client) {
+		t.Fatal("client evicted although pongs arrived")
+	}
+	if got := len(conn.frames(websocket.PingMessage)); got != 2 {
+		t.Fatalf("pings = %d, want 2", got)
+	}
+	// pong 只证明连接存活，不算作活跃
+	if got := atomic.LoadInt64(&client.lastSeen); got != start.UnixMilli() {
+		t.Fatalf("lastSeen = %d, want %d", got, start.UnixMilli())
+	}
+
+	// 不再回复 pong：t=30s 仍在 t=35s 的期限内，t=40s 超时断开
+	conn.setAutoPong(false)
+	clock.Advance(10 * time.Second)
+	waitFor(t, "third ping", func() bool { return len(conn.frames(websocket.PingMessage)) == 3 })
+	time.Sleep(10 * time.Millisecond)
+	if !m.registered(client) {
+		t.Fatal("client evicted before read deadline")
+	}
+
+	clock.Advance(10 * time.Second)
+	waitFor(t, "client unregistered after missing pong", func() bool { return !m.registered(client) && conn.isClosed() })
+}
+
+func TestWSMaxIdleEviction(t *testing.T) {
+	cfg := testWSConfig()
+	cfg.PongWait = time.Minute
+	cfg.MaxIdle = 25 * time.Second
+	cfg.BotMaxIdle = 0
+	m, clock := newTestManager(t, cfg)
+
+	user, userConn := attachTestClient(t, m, clock, &utils.TokenClaims{UserID: "u_1", Type: utils.TokenTypeAccess})
+	bot, botConn := attachTestClient(t, m, clock, &utils.TokenClaims{UserID: "u_bot", Type: utils.TokenTypeBot})
+
+	advance := func(pings int) {
+		t.Helper()
+		clock.Advance(10 * time.Second)
+		waitFor(t, "ping", func() bool {
+			return len(botConn.frames(websocket.PingMessage)) == pings &&
+				(userConn.isClosed() || len(userConn.frames(websocket.PingMessage)) == pings)
+		})
+	}
+
+	// t=10s 用户发送一帧操作，空闲计时从此开始
+	advance(1)
+	userConn.incoming <- []byte(`{}`)
+	waitFor(t, "lastSeen updated", func() bool { return atomic.LoadInt64(&user.lastSeen) == clock.Now().UnixMilli() })
+
+	// t=20s、t=30s 空闲 10s、20s，未超过 25s
+	advance(2)
+	advance(3)
+	if !m.registered(user) {
+		t.Fatal("user evicted before MaxIdle")
+	}
+
+	// t=40s 空闲 30s，用户被断开，机器人不受 MaxIdle 限制
+	clock.Advance(10 * time.Second)
+	waitFor(t, "idle user unregistered", func() bool { return !m.registered(user) && userConn.isClosed() })
+	closes := userConn.frames(websocket.CloseMessage)
+	if len(closes) != 1 || !strings.Contains(string(closes[0].data), "idle timeout") {
+		t.Fatalf("close frames = %q, want idle timeout", closes)
+	}
+	waitFor(t, "bot ping", func() bool { return len(botConn.frames(websocket.PingMessage)) == 4 })
+	if !m.registered(bot) {
+		t.Fatal("bot evicted although BotMaxIdle is 0")
+	}
+
+	botConn.Close()
+	waitFor(t, "bot unregistered", func() bool { return !m.registered(bot) })
+}
+
+func TestWSWriteTimeout(t *testing.T) {
+	m, clock := newTestManager(t, testWSConfig())
+	start := clock.Now()
+
+	// 对端不再读取：hello 帧的写入一直阻塞
+	conn := newFakeConn(clock)
+	conn.stallWrites = true
+	client := m.attach(conn, &utils.TokenClaims{UserID: "u_1", Type: utils.TokenTypeAccess}, "test", nil)
+
+	waitFor(t, "write deadline", func() bool {
+		_, write := conn.deadlines()
+		return write.Equal(start.Add(5 * time.Second))
+	})
+
+	clock.Advance(4 * time.Second)
+	time.Sleep(10 * time.Millisecond)
+	if !m.registered(client) || conn.isClosed() {
+		t.Fatal("client closed before write timeout")
+	}
+
+	clock.Advance(time.Second)
+	waitFor(t, "client unregistered after write timeout", func() bool { return !m.registered(client) && conn.isClosed() })
+}
+
+// replayHistory 模拟房间历史，序号从 1 到 total
+func replayHistory(roomID string, total int64) ReplayPage {
+	return func(afterSeq int64, limit int) ([]*WSMessage, error) {
+		var messages []*WSMessage
+		for seq := afterSeq + 1; seq <= total && len(messages) < limit; seq++ {
+			messages = append(messages, &WSMessage{Type: EventMessage, Seq: seq, RoomID: roomID, Data: seq})
+		}
+		return messages, nil
+	}
+}
+
+// drainSeqs 取出发送队列中的所有帧并返回 message 帧的序号
+func drainSeqs(t *testing.T, client *WSClient) []int64 {
+	t.Helper()
+
+	var seqs []int64
+	for {
+		select {
+		case data := <-client.send:
+			var message WSMessage
+			if err := json.Unmarshal(data, &message); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			if message.Type == EventMessage {
+				seqs = append(seqs, message.Seq)
+			}
+		default:
+			return seqs
+		}
+	}
+}
+
+// registerIdleClient 注册一个不启动读写协程的客户端，发送队列只在测试中被读取
+func registerIdleClient(t *testing.T, m *WSManager, userID string) (*WSClient, *fakeConn) {
+	t.Helper()
+
+	conn := newFakeConn(newFakeClock())
+	client := &WSClient{
+		manager:  m,
+		conn:     conn,
+		send:     make(chan []byte, m.cfg.SendBuffer),
+		connID:   "c_" + utils.GenerateUUID()[:12],
+		userID:   userID,
+		rooms:    make(map[string]bool),
+		unread:   make(map[string]int),
+		resuming: make(map[string][]*WSMessage),
+		typingAt: make(map[string]int64),
+	}
+	m.register <- client
+	waitFor(t, "client registered", func() bool { return m.registered(client) })
+	return client, conn
+}
+
+func TestWSReplayLargerThanSendBuffer(t *testing.T) {
+	for _, policy := range []string{conf.SlowConsumerDropOldest, conf.SlowConsumerDisconnect} {
+		t.Run(policy, func(t *testing.T) {
+			cfg := testWSConfig()
+			cfg.SendBuffer = 8
+			cfg.SlowConsumer = policy
+			m, _ := newTestManager(t, cfg)
+			client, conn := registerIdleClient(t, m, "u_1")
+
+			// 缺口 20 条大于发送队列：补发到只剩应答的空位为止，不丢帧也不断开
+			lastSeq, truncated, err := m.Replay(client, "r_1", 0, replayHistory("r_1", 20))
+			if err != nil || !truncated || lastSeq == 0 {
+				t.Fatalf("Replay = (%d, %v, %v), want truncated", lastSeq, truncated, err)
+			}
+			if len(client.send) != cfg.SendBuffer-1 {
+				t.Fatalf("queued frames = %d, want %d", len(client.send), cfg.SendBuffer-1)
+			}
+			seqs := drainSeqs(t, client)
+			for i, seq := range seqs {
+				if seq != int64(i+1) {
+					t.Fatalf("replayed seqs = %v, want 1..%d", seqs, lastSeq)
+				}
+			}
+			if int64(len(seqs)) != lastSeq {
+				t.Fatalf("replayed %d frames, lastSeq = %d", len(seqs), lastSeq)
+			}
+			if stats := m.Stats(); stats.DroppedFrames != 0 || stats.SlowDisconnects != 0 {
+				t.Fatalf("stats = %+v, want no drops", stats)
+			}
+			if conn.isClosed() || !m.registered(client) {
+				t.Fatal("client disconnected during replay")
+			}
+
+			// 客户端从 lastSeq 继续 resume，直到补齐
+			for truncated {
+				lastSeq, truncated, err = m.Replay(client, "r_1", lastSeq, replayHistory("r_1", 20))
+				if err != nil {
+					t.Fatalf("Replay: %v", err)
+				}
+				seqs = append(seqs, drainSeqs(t, client)...)
+			}
+			if lastSeq != 20 || len(seqs) != 20 || seqs[19] != 20 {
+				t.Fatalf("continued replay: lastSeq = %d, seqs = %v", lastSeq, seqs)
+			}
+		})
+	}
+}
+
+func TestWSReplayMaxMessages(t *testing.T) {
+	cfg := testWSConfig()
+	cfg.SendBuffer = 2 * resumeMaxMessages
+	m, _ := newTestManager(t, cfg)
+	client, _ := registerIdleClient(t, m, "u_1")
+	total := int64(resumeMaxMessages + 50)
+
+	lastSeq, truncated, err := m.Replay(client, "r_1", 0, replayHistory("r_1", total))
+	if err != nil || !truncated || lastSeq != resumeMaxMessages {
+		t.Fatalf("Replay = (%d, %v, %v), want (%d, true, nil)", lastSeq, truncated, err, resumeMaxMessages)
+	}
+	drainSeqs(t, client)
+
+	lastSeq, truncated, err = m.Replay(client, "r_1", lastSeq, replayHistory("r_1", total))
+	if err != nil || truncated || lastSeq != total {
+		t.Fatalf("Replay = (%d, %v, %v), want (%d, false, nil)", lastSeq, truncated, err, total)
+	}
+}
+
+func TestWSEndResumeKeepsFramesWithoutSeq(t *testing.T) {
+	m, _ := newTestManager(t, testWSConfig())
+	client, _ := registerIdleClient(t, m, "u_1")
+	if !m.BeginResume(client, "r_1") {
+		t.Fatal("BeginResume failed")
+	}
+	drainSeqs(t, client)
+
+	for _, seq := range []int64{0, 4, 6} {
+		m.Broadcast(&WSMessage{Type: EventMessage, Seq: seq, RoomID: "r_1", UserID: "u_2", Data: seq})
+	}
+	waitFor(t, "frames buffered", func() bool {
+		m.mu.RLock()
+		defer m.mu.RUnlock()
+		return len(client.resuming["r_1"]) == 3
+	})
+
+	// 已补齐到 5：序号 4 已补发过，无序号的帧与序号 6 照常投递
+	m.EndResume(client, "r_1", 5)
+	seqs := drainSeqs(t, client)
+	if len(seqs) != 2 || seqs[0] != 0 || seqs[1] != 6 {
+		t.Fatalf("delivered seqs = %v, want [0 6]", seqs)
+	}
+}
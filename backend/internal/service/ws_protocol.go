@@ -0,0 +1,125 @@
+package service
+
+import "encoding/json"
+
+// WSProtocolVersion WebSocket 协议版本，帧中的 v 字段必须与之一致
+const WSProtocolVersion = 1
+
+// 客户端 -> 服务端操作
+const (
+	OpJoin        = "join"         // 加入房间并订阅房间事件，data 为 WSJoinData
+	OpLeave       = "leave"        // 离开房间
+	OpSubscribe   = "subscribe"    // 订阅已加入房间的事件
+	OpResume      = "resume"       // 断线重连后订阅房间并补齐 last_seq 之后的消息，data 为 WSResumeData
+	OpUnsubscribe = "unsubscribe"  // 取消订阅房间事件（不离开房间）
+	OpFocus       = "focus"        // 切换当前查看的房间，清零其未读数
+	OpSend        = "send"         // 发送消息，data 为 WSSendData
+	OpEdit        = "edit"         // 编辑自己的消息，data 为 WSEditData
+	OpReact       = "react"        // 添加表情回应，data 为 WSReactData
+	OpUnreact     = "unreact"      // 移除表情回应，data 为 WSReactData
+	OpRead        = "read"         // 标记已读，data 为 WSReadData
+	OpTypingStart = "typing_start" // 开始输入，服务端节流并在未收到 typing_stop 时自动过期
+	OpTypingStop  = "typing_stop"  // 停止输入
+	OpTyping      = "typing"       // 兼容旧客户端，等同 typing_start
+	OpAck         = "ack"          // 确认已收到消息，data 为 WSAckData
+	OpPing        = "ping"         // 心跳
+)
+
+// 服务端 -> 客户端事件
+const (
+	EventHello       = "hello"            // 连接建立
+	EventAck         = "ack"              // 客户端操作成功
+	EventError       = "error"            // 客户端操作失败或帧非法
+	EventMessage     = "message"          // 新消息
+	EventEdited      = "message_edited"   // 消息被编辑
+	EventRecalled    = "message_recalled" // 消息被撤回或删除，data 为墓碑
+	EventThreadReply = "thread_reply"     // 话题有新回复，只发给话题参与者
+	EventReaction    = "reaction_updated" // 消息的表情回应变化
+	EventReadReceipt = "read_receipt"     // 成员的已读游标前进
+	EventJoin        = "join"             // 用户加入房间
+	EventJoinRequest = "join_request"     // 有新的入群申请，只发给房主
+	EventLeave       = "leave"            // 用户离开房间
+	EventOnlineCount = "online_count"     // 房间在线人数
+	EventTypingStart = "typing_start"     // 用户开始输入，不发给输入者本人
+	EventTypingStop  = "typing_stop"      // 用户停止输入或输入状态过期
+	EventTopic       = "topic"            // 房间话题变更
+	EventRoleChanged = "role_changed"     // 成员角色变更
+	EventUnread      = "unread"           // 未在查看的房间的未读数
+	EventPresence    = "presence"         // 用户在房间内上线/下线
+)
+
+// wsOps 支持的客户端操作
+var wsOps = map[string]bool{
+	OpJoin:        true,
+	OpLeave:       true,
+	OpSubscribe:   true,
+	OpResume:      true,
+	OpUnsubscribe: true,
+	OpFocus:       true,
+	OpSend:        true,
+	OpEdit:        true,
+	OpReact:       true,
+	OpUnreact:     true,
+	OpRead:        true,
+	OpTypingStart: true,
+	OpTypingStop:  true,
+	OpTyping:      true,
+	OpAck:         true,
+	OpPing:        true,
+}
+
+// WSRequest 客户端上行帧
+type WSRequest struct {
+	V      int             `json:"v"`
+	Op     string          `json:"op"`
+	ID     string          `json:"id"`                // 客户端请求ID，原样带回 ack/error 帧
+	RoomID string          `json:"room_id,omitempty"` // 为空时使用当前查看的房间
+	Data   json.RawMessage `json:"data,omitempty"`
+}
+
+// WSJoinData join 操作的数据
+type WSJoinData struct {
+	InviteCode string `json:"invite_code,omitempty"` // 凭邀请链接加入，此时 room_id 可为空
+}
+
+// WSSendData send 操作的数据
+type WSSendData struct {
+	Content      string `json:"content"`
+	MsgType      int32  `json:"msg_type"`
+	ReplyToMsgID string `json:"reply_to_msg_id,omitempty"` // 引用的消息
+	ThreadRootID string `json:"thread_root_id,omitempty"`  // 回复到该话题
+}
+
+// WSEditData edit 操作的数据
+type WSEditData struct {
+	MsgID   string `json:"msg_id"`
+	Content string `json:"content"`
+}
+
+// WSReactData react/unreact 操作的数据
+type WSReactData struct {
+	MsgID string `json:"msg_id"`
+	Emoji string `json:"emoji"`
+}
+
+// WSReadData read 操作的数据
+type WSReadData struct {
+	Seq int64 `json:"seq"` // 已读到的消息序号，0 表示房间最新一条
+}
+
+// WSResumeData resume 操作的数据
+type WSResumeData struct {
+	LastSeq int64 `json:"last_seq"` // 客户端已收到的最后一条消息序号
+}
+
+// WSAckData ack 操作的数据
+type WSAckData struct {
+	MsgID string `json:"msg_id"`
+}
+
+// WSReply ack/error 帧的数据
+type WSReply struct {
+	Code    int32       `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Token 类型
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+	TokenTypeBot     = "bot"
+)
+
+var (
+	ErrTokenInvalid = errors.New("token invalid")
+	ErrTokenExpired = errors.New("token expired")
+)
+
+// TokenClaims JWT 载荷
+type TokenClaims struct {
+	UserID    string `json:"sub"`
+	SessionID string `json:"sid"`
+	Type      string `json:"typ"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// jwtHeader 固定使用 HS256
+var jwtHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// SignToken 使用 HMAC-SHA256 签发 JWT
+func SignToken(claims *TokenClaims, secret []byte) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := jwtHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return signingInput + "." + signHS256(signingInput, secret), nil
+}
+
+// ParseToken 校验签名与过期时间并解析载荷
+func ParseToken(token string, secret []byte) (*TokenClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != jwtHeader {
+		return nil, ErrTokenInvalid
+	}
+
+	expected := signHS256(parts[0]+"."+parts[1], secret)
+	if !hmac.Equal([]byte(expected), []byte(parts[2])) {
+		return nil, ErrTokenInvalid
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrTokenInvalid
+	}
+
+	var claims TokenClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, ErrTokenInvalid
+	}
+	if claims.UserID == "" {
+		return nil, ErrTokenInvalid
+	}
+	if claims.ExpiresAt <= time.Now().Unix() {
+		return nil, ErrTokenExpired
+	}
+
+	return &claims, nil
+}
+
+func signHS256(input string, secret []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(input))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// RandomToken 生成 n 字节随机串（十六进制）
+func RandomToken(n int) string {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(buf)
+}
+
+// SHA256 计算SHA256哈希
+func SHA256(text string) string {
+	hash := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseToken(t *testing.T) {
+	secret := []byte("test-secret")
+	now := time.Now()
+
+	valid, err := SignToken(&TokenClaims{
+		UserID:    "u_1",
+		SessionID: "s_1",
+		Type:      TokenTypeAccess,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(time.Hour).Unix(),
+	}, secret)
+	if err != nil {
+		t.Fatalf("SignToken: %v", err)
+	}
+	expired, _ := SignToken(&TokenClaims{
+		UserID:    "u_1",
+		Type:      TokenTypeAccess,
+		IssuedAt:  now.Add(-2 * time.Hour).Unix(),
+		ExpiresAt: now.Add(-time.Hour).Unix(),
+	}, secret)
+	noSubject, _ := SignToken(&TokenClaims{
+		Type:      TokenTypeAccess,
+		ExpiresAt: now.Add(time.Hour).Unix(),
+	}, secret)
+
+	parts := strings.Split(valid, ".")
+	// 篡改载荷中的用户后沿用原签名
+	forgedPayload := base64.RawURLEncoding.EncodeToString([]byte(
+		`{"sub":"u_admin","sid":"s_1","typ":"access","exp":` + "9999999999" + `}`))
+	noneHeader := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`))
+
+	tests := []struct {
+		name    string
+		token   string
+		secret  []byte
+		wantErr error
+	}{
+		{"valid", valid, secret, nil},
+		{"wrong secret", valid, []byte("other-secret"), ErrTokenInvalid},
+		{"tampered payload", parts[0] + "." + forgedPayload + "." + parts[2], secret, ErrTokenInvalid},
+		{"tampered signature", parts[0] + "." + parts[1] + "." + strings.Repeat("A", len(parts[2])), secret, ErrTokenInvalid},
+		{"alg none", noneHeader + "." + parts[1] + ".", secret, ErrTokenInvalid},
+		{"missing signature", parts[0] + "." + parts[1], secret, ErrTokenInvalid},
+		{"empty", "", secret, ErrTokenInvalid},
+		{"missing subject", noSubject, secret, ErrTokenInvalid},
+		{"expired", expired, secret, ErrTokenExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := ParseToken(tt.token, tt.secret)
+			if err != tt.wantErr {
+				t.Fatalf("ParseToken error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (claims.UserID != "u_1" || claims.SessionID != "s_1" || claims.Type != TokenTypeAccess) {
+				t.Fatalf("unexpected claims: %+v", claims)
+			}
+		})
+	}
+}
@@ -2,11 +2,14 @@ package utils
 
 import (
 	"crypto/md5"
+	"crypto/subtle"
 	"encoding/hex"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
 )
 
 // GenerateUUID 生成唯一ID
@@ -24,6 +27,19 @@ func GenerateRoomID() string {
 	return "r_" + GenerateUUID()[:8]
 }
 
+// DirectRoomID 两个用户的私聊房间ID，与参数顺序无关
+func DirectRoomID(userA, userB string) string {
+	if userA > userB {
+		userA, userB = userB, userA
+	}
+	return "d_" + MD5(userA+":"+userB)[:16]
+}
+
+// GenerateInviteCode 生成房间邀请码
+func GenerateInviteCode() string {
+	return strings.ReplaceAll(GenerateUUID(), "-", "")[:12]
+}
+
 // GenerateMsgID 生成消息ID
 func GenerateMsgID() string {
 	return "m_" + GenerateUUID()[:12]
@@ -35,15 +51,35 @@ func MD5(text string) string {
 	return hex.EncodeToString(hash[:])
 }
 
-// HashPassword 密码哈希
-func HashPassword(password string) string {
-	salt := "bot_chat_salt_2024"
-	return MD5(password + salt)
+// PasswordCost bcrypt 计算强度，调高后旧哈希会在登录时自动升级
+var PasswordCost = bcrypt.DefaultCost
+
+// legacySalt 旧版 MD5 哈希使用的全局盐，仅用于校验存量账号
+const legacySalt = "bot_chat_salt_2024"
+
+// HashPassword 密码哈希，输出带算法前缀的 bcrypt 串（$2a$<cost>$...），盐随机生成
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), PasswordCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
 }
 
-// VerifyPassword 验证密码
-func VerifyPassword(password, hash string) bool {
-	return HashPassword(password) == hash
+// VerifyPassword 验证密码，兼容旧版 MD5 哈希。
+// needsRehash 为 true 时调用方应使用 HashPassword 重新计算并保存。
+func VerifyPassword(password, hash string) (ok bool, needsRehash bool) {
+	if !strings.HasPrefix(hash, "$2") {
+		legacy := MD5(password + legacySalt)
+		ok = subtle.ConstantTimeCompare([]byte(legacy), []byte(hash)) == 1
+		return ok, ok
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+		return false, false
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	return true, err != nil || cost < PasswordCost
 }
 
 // GetCurrentTimestamp 获取当前时间戳（毫秒）
@@ -104,8 +140,16 @@ const (
 	CodeUserExists     = 1001
 	CodeUserNotFound   = 1002
 	CodePasswordError  = 1003
+	CodeTokenInvalid   = 1004
+	CodeTokenExpired   = 1005
 	CodeRoomNotFound   = 2001
 	CodeRoomExists     = 2002
 	CodeAlreadyInRoom  = 2003
 	CodeNotInRoom      = 2004
+	CodeInviteInvalid  = 2005
+	CodeJoinPending    = 2006
+	CodeBannedFromRoom = 2007
+	CodeMsgNotFound    = 3001
+	CodeMsgExpired     = 3002
+	CodeMsgConflict    = 3003
 )
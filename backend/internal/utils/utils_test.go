@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestVerifyPassword(t *testing.T) {
+	PasswordCost = bcrypt.MinCost + 1
+	defer func() { PasswordCost = bcrypt.DefaultCost }()
+
+	current, err := HashPassword("secret")
+	if err != nil {
+		t.Fatalf("HashPassword: %v", err)
+	}
+	weak, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("GenerateFromPassword: %v", err)
+	}
+	legacy := MD5("secret" + legacySalt)
+
+	tests := []struct {
+		name       string
+		password   string
+		hash       string
+		wantOK     bool
+		wantRehash bool
+	}{
+		{"bcrypt", "secret", current, true, false},
+		{"bcrypt wrong password", "wrong", current, false, false},
+		{"bcrypt below cost", "secret", string(weak), true, true},
+		{"legacy md5", "secret", legacy, true, true},
+		{"legacy md5 wrong password", "wrong", legacy, false, false},
+		{"legacy md5 without salt", "secret", MD5("secret"), false, false},
+		{"empty hash", "secret", "", false, false},
+		{"corrupted bcrypt", "secret", current[:len(current)-4], false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, rehash := VerifyPassword(tt.password, tt.hash)
+			if ok != tt.wantOK || rehash != tt.wantRehash {
+				t.Fatalf("VerifyPassword = (%v, %v), want (%v, %v)", ok, rehash, tt.wantOK, tt.wantRehash)
+			}
+		})
+	}
+}
+
+func TestVerifyPasswordLegacyUpgrade(t *testing.T) {
+	PasswordCost = bcrypt.MinCost
+	defer func() { PasswordCost = bcrypt.DefaultCost }()
+
+	legacy := MD5("secret" + legacySalt)
+	ok, rehash := VerifyPassword("secret", legacy)
+	if !ok || !rehash {
+		t.Fatalf("legacy VerifyPassword = (%v, %v), want (true, true)", ok, rehash)
+	}
+
+	// 登录成功后按 needsRehash 重新计算，新哈希不再需要升级
+	upgraded, err := HashPassword("secret")
+	if err != nil {
+		t.Fatalf("HashPassword: %v", err)
+	}
+	if upgraded == legacy || upgraded[:2] != "$2" {
+		t.Fatalf("upgraded hash is not bcrypt: %q", upgraded)
+	}
+	ok, rehash = VerifyPassword("secret", upgraded)
+	if !ok || rehash {
+		t.Fatalf("upgraded VerifyPassword = (%v, %v), want (true, false)", ok, rehash)
+	}
+	if ok, _ := VerifyPassword("wrong", upgraded); ok {
+		t.Fatal("upgraded hash accepted wrong password")
+	}
+
+	// 调高强度后旧的 bcrypt 哈希也会被要求升级
+	PasswordCost = bcrypt.MinCost + 1
+	if ok, rehash := VerifyPassword("secret", upgraded); !ok || !rehash {
+		t.Fatalf("after raising cost VerifyPassword = (%v, %v), want (true, true)", ok, rehash)
+	}
+}
+
+func TestDirectRoomID(t *testing.T) {
+	id := DirectRoomID("u_alice", "u_bob")
+	if id != DirectRoomID("u_bob", "u_alice") {
+		t.Fatal("DirectRoomID depends on argument order")
+	}
+	if !strings.HasPrefix(id, "d_") || len(id) != 18 {
+		t.Fatalf("DirectRoomID = %q", id)
+	}
+	if id == DirectRoomID("u_alice", "u_carol") {
+		t.Fatal("different pairs share a room ID")
+	}
+}
@@ -1,5 +1,5 @@
 import axios from 'axios'
-import { User, Room, Message } from '../store'
+import { User, Room, Message, Reaction, ReadCursor, DirectChat, RoomVisibility, RoomInvite, JoinRequest, RoomRole, RoomMember, useUserStore } from '../store'
 
 // API 基础配置
 const api = axios.create({
@@ -12,7 +12,7 @@ const api = axios.create({
 
 // 请求拦截器 - 添加 token
 api.interceptors.request.use((config) => {
-  const token = localStorage.getItem('token')
+  const { token } = useUserStore.getState()
   if (token) {
     config.headers.Authorization = `Bearer ${token}`
   }
@@ -24,7 +24,7 @@ api.interceptors.response.use(
   (response) => response.data,
   (error) => {
     if (error.response?.status === 401) {
-      localStorage.removeItem('token')
+      useUserStore.getState().logout()
       window.location.href = '/login'
     }
     return Promise.reject(error)
@@ -57,6 +57,8 @@ export interface LoginReq {
 
 export interface LoginResp {
   token: string
+  refresh_token: string
+  expires_at: number
   user: User
 }
 
@@ -66,6 +68,8 @@ export const userApi = {
   
   login: (data: LoginReq) =>
     api.post<ApiResponse<LoginResp>>('/login', data),
+
+  logout: () => api.post<ApiResponse<void>>('/logout', {}),
 }
 
 // ==================== 房间相关 API ====================
@@ -73,6 +77,7 @@ export const userApi = {
 export interface CreateRoomReq {
   name: string
   description?: string
+  visibility?: RoomVisibility
 }
 
 export interface CreateRoomResp {
@@ -91,16 +96,34 @@ export interface ListRoomsResp {
 
 export interface JoinRoomReq {
   room_id: string
+  invite_code?: string
 }
 
 export interface JoinRoomResp {
   room: Room
+  request?: JoinRequest // 需审批的房间返回提交的申请
+}
+
+export interface CreateInviteReq {
+  max_uses?: number
+  expires_in?: number // 有效期（秒）
 }
 
 export interface LeaveRoomReq {
   room_id: string
 }
 
+export interface MarkReadResp {
+  last_read_seq: number
+  cursors: ReadCursor[]
+}
+
+export interface RoomUnread {
+  room_id: string
+  count: number
+  last_read_seq: number
+}
+
 export const roomApi = {
   create: (data: CreateRoomReq) =>
     api.post<ApiResponse<CreateRoomResp>>('/rooms', data),
@@ -109,10 +132,51 @@ export const roomApi = {
     api.get<ApiResponse<ListRoomsResp>>('/rooms', { params }),
   
   join: (data: JoinRoomReq) =>
-    api.post<ApiResponse<JoinRoomResp>>(`/rooms/${data.room_id}/join`, {}),
+    api.post<ApiResponse<JoinRoomResp>>(`/rooms/${data.room_id}/join`, { invite_code: data.invite_code }),
+
+  // 凭邀请码加入，房间由邀请链接确定
+  joinByInvite: (code: string) =>
+    api.post<ApiResponse<JoinRoomResp>>(`/invites/${code}`, {}),
+
+  createInvite: (roomId: string, data: CreateInviteReq) =>
+    api.post<ApiResponse<{ invite: RoomInvite }>>(`/rooms/${roomId}/invites`, data),
+
+  revokeInvite: (code: string) =>
+    api.delete<ApiResponse<void>>(`/invites/${code}`),
+
+  listJoinRequests: (roomId: string) =>
+    api.get<ApiResponse<{ requests: JoinRequest[] }>>(`/rooms/${roomId}/join-requests`),
+
+  reviewJoinRequest: (requestId: number, approve: boolean) =>
+    api.post<ApiResponse<{ request: JoinRequest }>>(`/join-requests/${requestId}`, { approve }),
+
+  // 提升成员角色，解除禁言即提升为 member
+  promote: (roomId: string, targetUserId: string, role: RoomRole) =>
+    api.post<ApiResponse<{ member: RoomMember }>>(`/rooms/${roomId}/promote`, { target_user_id: targetUserId, role }),
+
+  // 降低成员角色，禁言即降低为 muted
+  demote: (roomId: string, targetUserId: string, role: RoomRole) =>
+    api.post<ApiResponse<{ member: RoomMember }>>(`/rooms/${roomId}/demote`, { target_user_id: targetUserId, role }),
+
+  transferOwnership: (roomId: string, newOwnerId: string) =>
+    api.post<ApiResponse<{ members: RoomMember[] }>>(`/rooms/${roomId}/transfer`, { new_owner_id: newOwnerId }),
   
   leave: (data: LeaveRoomReq) =>
     api.post<ApiResponse<void>>(`/rooms/${data.room_id}/leave`, {}),
+
+  // seq 省略时标记到房间最新一条
+  markRead: (roomId: string, seq?: number) =>
+    api.post<ApiResponse<MarkReadResp>>(`/rooms/${roomId}/read`, { seq }),
+
+  getUnread: () =>
+    api.get<ApiResponse<{ unread: RoomUnread[] }>>('/unread'),
+
+  // 打开与某个用户的私聊，不存在时创建
+  openDirect: (peerUserId: string) =>
+    api.post<ApiResponse<{ chat: DirectChat }>>('/direct-chats', { peer_user_id: peerUserId }),
+
+  listDirect: () =>
+    api.get<ApiResponse<{ chats: DirectChat[] }>>('/direct-chats'),
 }
 
 // ==================== 消息相关 API ====================
@@ -121,15 +185,19 @@ export interface SendMessageReq {
   room_id: string
   content: string
   msg_type?: number
+  reply_to_msg_id?: string
+  thread_root_id?: string
 }
 
 export interface SendMessageResp {
-  msg: Message
+  msg?: Message
+  notice?: string
 }
 
 export interface GetHistoryReq {
   room_id: string
-  before_time?: number
+  before_seq?: number
+  with_revisions?: boolean
   limit?: number
 }
 
@@ -138,10 +206,34 @@ export interface GetHistoryResp {
   has_more: boolean
 }
 
+export interface GetThreadResp {
+  root: Message
+  replies: Message[]
+  has_more: boolean
+}
+
 export const messageApi = {
   send: (data: SendMessageReq) =>
     api.post<ApiResponse<SendMessageResp>>('/messages', data),
   
+  edit: (msgId: string, content: string) =>
+    api.put<ApiResponse<{ msg: Message }>>(`/messages/${msgId}`, { content }),
+
+  getThread: (msgId: string, params?: { after_seq?: number; limit?: number }) =>
+    api.get<ApiResponse<GetThreadResp>>(`/messages/${msgId}/thread`, { params }),
+
+  addReaction: (msgId: string, emoji: string) =>
+    api.post<ApiResponse<{ reactions: Reaction[] }>>(`/messages/${msgId}/reactions`, { emoji }),
+
+  removeReaction: (msgId: string, emoji: string) =>
+    api.delete<ApiResponse<{ reactions: Reaction[] }>>(`/messages/${msgId}/reactions`, { params: { emoji } }),
+
+  recall: (msgId: string) =>
+    api.post<ApiResponse<{ msg: Message }>>(`/messages/${msgId}/recall`, {}),
+
+  remove: (msgId: string) =>
+    api.delete<ApiResponse<{ msg: Message }>>(`/messages/${msgId}`),
+
   getHistory: (params: GetHistoryReq) =>
     api.get<ApiResponse<GetHistoryResp>>('/messages', { params }),
 }
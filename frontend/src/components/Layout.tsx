@@ -8,6 +8,7 @@ import {
   MessageOutlined,
 } from '@ant-design/icons'
 import { useUserStore } from '../store'
+import { userApi } from '../api'
 import { useNavigate, useLocation } from 'react-router-dom'
 
 const { Header, Sider, Content } = Layout
@@ -22,7 +23,12 @@ const AppLayout: React.FC<AppLayoutProps> = ({ children }) => {
   const location = useLocation()
   const { user, logout } = useUserStore()
 
-  const handleLogout = () => {
+  const handleLogout = async () => {
+    try {
+      await userApi.logout()
+    } catch (error) {
+      // 会话可能已失效，忽略
+    }
     logout()
     navigate('/login')
   }
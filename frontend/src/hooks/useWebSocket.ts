@@ -1,42 +1,214 @@
-import { useEffect, useRef, useCallback } from 'react'
-import { useUserStore, useMessageStore, Message } from '../store'
+import { useEffect, useRef, useCallback, useState } from 'react'
+import { message } from 'antd'
+import { useUserStore, useMessageStore, useRoomStore, Message } from '../store'
+import { messageApi } from '../api'
 
-const WS_URL = import.meta.env.VITE_WS_URL || 'ws://localhost:8888/ws'
+const WS_URL = import.meta.env.VITE_WS_URL || 'ws://localhost:8889/ws'
+
+// WebSocket 协议版本，需与后端 WSProtocolVersion 一致
+const PROTOCOL_VERSION = 1
+
+type WSOp = 'join' | 'leave' | 'subscribe' | 'resume' | 'unsubscribe' | 'focus' | 'send' | 'edit' | 'react' | 'unreact' | 'read' | 'typing_start' | 'typing_stop' | 'ack' | 'ping'
+
+interface WSReply {
+  code: number
+  message: string
+  data?: any
+}
+
+// 停止输入多久后发送 typing_stop
+const TYPING_IDLE = 3000
+
+let requestSeq = 0
+const nextRequestId = () => `${Date.now().toString(36)}-${++requestSeq}`
 
 export const useWebSocket = (roomId: string | undefined) => {
   const { user, token } = useUserStore()
-  const { addMessage } = useMessageStore()
+  const { addMessage, updateMessage, setMessages, setReadCursors, updateReadCursor } = useMessageStore()
+  const { setUnread, resetUnread } = useRoomStore()
   const wsRef = useRef<WebSocket | null>(null)
   const reconnectTimeoutRef = useRef<NodeJS.Timeout>()
+  // 等待 ack/error 的请求：id -> 回调
+  const pendingRef = useRef<Map<string, (reply: WSReply) => void>>(new Map())
+  // 房间内正在输入的其他用户及其本地过期定时器
+  const [typingUsers, setTypingUsers] = useState<string[]>([])
+  const typingTimersRef = useRef<Map<string, NodeJS.Timeout>>(new Map())
+  const typingIdleRef = useRef<NodeJS.Timeout>()
+  const typingSentRef = useRef(false)
+
+  const clearTyping = useCallback((userId: string) => {
+    const timer = typingTimersRef.current.get(userId)
+    if (timer) {
+      clearTimeout(timer)
+      typingTimersRef.current.delete(userId)
+    }
+    setTypingUsers((users) => users.filter((id) => id !== userId))
+  }, [])
+
+  // 服务端会在过期时发送 typing_stop，本地同样按 expires_in 兜底
+  const markTyping = useCallback((userId: string, expiresIn: number) => {
+    const timer = typingTimersRef.current.get(userId)
+    if (timer) {
+      clearTimeout(timer)
+    }
+    typingTimersRef.current.set(userId, setTimeout(() => clearTyping(userId), expiresIn))
+    setTypingUsers((users) => (users.includes(userId) ? users : [...users, userId]))
+  }, [clearTyping])
+
+  const request = useCallback((ws: WebSocket, op: WSOp, data?: any, onReply?: (reply: WSReply) => void, targetRoom: string | undefined = roomId) => {
+    const id = nextRequestId()
+    if (onReply) {
+      pendingRef.current.set(id, onReply)
+    }
+    ws.send(JSON.stringify({
+      v: PROTOCOL_VERSION,
+      op,
+      id,
+      room_id: targetRoom,
+      data,
+    }))
+    return id
+  }, [roomId])
+
+  // 标记当前房间已读到 seq，省略时为最新一条
+  const markRead = useCallback((ws: WebSocket, seq?: number) => {
+    request(ws, 'read', seq ? { seq } : undefined, (reply) => {
+      if (reply.code === 0) {
+        setReadCursors(reply.data?.cursors || [])
+      }
+    })
+  }, [request, setReadCursors])
 
   const connect = useCallback(() => {
     if (!roomId || !user) return
 
-    const wsUrl = `${WS_URL}?user_id=${user.user_id}&token=${token}`
+    const wsUrl = `${WS_URL}?token=${token}`
     const ws = new WebSocket(wsUrl)
 
     ws.onopen = () => {
       console.log('WebSocket connected')
-      // 发送加入房间消息
-      ws.send(JSON.stringify({
-        type: 'join',
-        room_id: roomId,
-      }))
+      const focus = () => {
+        request(ws, 'focus', undefined, (focusReply) => {
+          if (focusReply.code === 0) {
+            resetUnread(focusReply.data?.unread || {})
+          }
+        })
+        markRead(ws)
+      }
+
+      // 已有消息时为断线重连：补齐断开期间的消息后再恢复实时推送
+      const lastSeq = useMessageStore.getState().messages
+        .filter((m) => m.room_id === roomId)
+        .reduce((max, m) => Math.max(max, m.seq || 0), 0)
+      if (lastSeq > 0) {
+        request(ws, 'resume', { last_seq: lastSeq }, (reply) => {
+          if (reply.code !== 0) {
+            message.error(reply.message || '重新连接房间失败')
+            return
+          }
+          if (reply.data?.truncated) {
+            // 错过的消息过多，重新加载最新历史
+            messageApi.getHistory({ room_id: roomId!, limit: 50 }).then((res: any) => {
+              if (res.code === 0) {
+                setMessages(res.data.messages)
+              }
+            })
+          }
+          focus()
+        })
+        return
+      }
+
+      // 加入房间并订阅房间事件，再将其设为当前查看的房间
+      request(ws, 'join', undefined, (reply) => {
+        if (reply.code !== 0) {
+          message.error(reply.message || '加入房间失败')
+          return
+        }
+        focus()
+      })
     }
 
     ws.onmessage = (event) => {
       try {
         const data = JSON.parse(event.data)
-        
+
         switch (data.type) {
+          case 'hello':
+            if (data.data?.version !== PROTOCOL_VERSION) {
+              console.warn('WebSocket protocol version mismatch:', data.data?.version)
+            }
+            break
+          case 'ack':
+          case 'error': {
+            const reply = data.data as WSReply
+            const callback = data.id && pendingRef.current.get(data.id)
+            if (callback) {
+              pendingRef.current.delete(data.id)
+              callback(reply)
+            } else if (data.type === 'error') {
+              console.error('WebSocket error frame:', reply)
+            }
+            break
+          }
           case 'message':
-            // 新消息
-            addMessage(data.data as Message)
+            // 新消息，其他订阅房间的消息只计入未读
+            if (data.room_id === roomId) {
+              addMessage(data.data as Message)
+              clearTyping(data.data.sender?.user_id)
+              // 正在查看时直接标记已读
+              if (data.data.sender?.user_id !== user?.user_id && document.visibilityState === 'visible') {
+                markRead(ws, data.data.seq)
+              }
+            }
+            break
+          case 'message_edited':
+          case 'message_recalled':
+            // 撤回/删除的消息以墓碑替换原消息
+            if (data.room_id === roomId) {
+              updateMessage(data.data as Message)
+            }
+            break
+          case 'thread_reply':
+            // 参与的话题有新回复，更新根消息的回复数
+            if (data.room_id === roomId) {
+              updateMessage({
+                msg_id: data.data.root_msg_id,
+                reply_count: data.data.reply_count,
+                last_reply_at: data.data.last_reply_at,
+              })
+            }
+            break
+          case 'reaction_updated': {
+            // 按广播的最新计数更新表情，自己的回应状态以 user_id 判断
+            const { msg_id, emoji, count, added, user_id } = data.data
+            const target = useMessageStore.getState().messages.find((m) => m.msg_id === msg_id)
+            if (!target) break
+            const previous = target.reactions?.find((r) => r.emoji === emoji)
+            const reacted = user_id === user?.user_id ? added : !!previous?.reacted
+            const next = { emoji, count, reacted }
+            const reactions = previous
+              ? target.reactions!.map((r) => (r.emoji === emoji ? next : r))
+              : [...(target.reactions || []), next]
+            updateMessage({ msg_id, reactions: reactions.filter((r) => r.count > 0) })
+            break
+          }
+          case 'read_receipt':
+            if (data.room_id === roomId) {
+              updateReadCursor(data.data.user_id, data.data.last_read_seq)
+            }
+            break
+          case 'unread':
+            setUnread(data.room_id, data.data.count)
             break
           case 'join':
             // 用户加入
             console.log('User joined:', data.data)
             break
+          case 'join_request':
+            // 仅房主收到：有用户申请加入需审批的房间
+            message.info(`${data.data.user?.nickname || data.data.user?.user_id} 申请加入房间`)
+            break
           case 'leave':
             // 用户离开
             console.log('User left:', data.data)
@@ -45,6 +217,24 @@ export const useWebSocket = (roomId: string | undefined) => {
             // 在线人数更新
             console.log('Online count:', data.data.count)
             break
+          case 'typing_start':
+            if (data.room_id === roomId) {
+              markTyping(data.data.user_id, data.data.expires_in || 6000)
+            }
+            break
+          case 'typing_stop':
+            if (data.room_id === roomId) {
+              clearTyping(data.data.user_id)
+            }
+            break
+          case 'role_changed':
+            if (data.data.user_id === user?.user_id) {
+              message.info(data.data.role === 'muted' ? '你已被禁言' : `你的角色已变更为 ${data.data.role}`)
+            }
+            break
+          case 'topic':
+          case 'presence':
+            break
           default:
             console.log('Unknown message type:', data.type)
         }
@@ -55,6 +245,8 @@ export const useWebSocket = (roomId: string | undefined) => {
 
     ws.onclose = () => {
       console.log('WebSocket disconnected')
+      pendingRef.current.clear()
+      typingSentRef.current = false
       // 尝试重连
       reconnectTimeoutRef.current = setTimeout(() => {
         connect()
@@ -66,33 +258,118 @@ export const useWebSocket = (roomId: string | undefined) => {
     }
 
     wsRef.current = ws
-  }, [roomId, user, token, addMessage])
+  }, [roomId, user, token, addMessage, updateMessage, setMessages, setUnread, resetUnread, updateReadCursor, request, markRead, markTyping, clearTyping])
 
   const disconnect = useCallback(() => {
     if (reconnectTimeoutRef.current) {
       clearTimeout(reconnectTimeoutRef.current)
     }
     if (wsRef.current) {
+      wsRef.current.onclose = null
       wsRef.current.close()
       wsRef.current = null
     }
+    pendingRef.current.clear()
+    typingTimersRef.current.forEach((timer) => clearTimeout(timer))
+    typingTimersRef.current.clear()
+    setTypingUsers([])
+    if (typingIdleRef.current) {
+      clearTimeout(typingIdleRef.current)
+    }
+    typingSentRef.current = false
   }, [])
 
+  // 输入框内容变化时调用：首次输入发送 typing_start（服务端负责节流），停止输入一段时间后发送 typing_stop
+  const notifyTyping = useCallback(() => {
+    const ws = wsRef.current
+    if (!ws || ws.readyState !== WebSocket.OPEN) {
+      return
+    }
+
+    if (!typingSentRef.current) {
+      typingSentRef.current = true
+      request(ws, 'typing_start')
+    }
+    if (typingIdleRef.current) {
+      clearTimeout(typingIdleRef.current)
+    }
+    typingIdleRef.current = setTimeout(() => {
+      typingSentRef.current = false
+      if (wsRef.current?.readyState === WebSocket.OPEN) {
+        request(wsRef.current, 'typing_stop')
+      }
+    }, TYPING_IDLE)
+  }, [request])
+
   const sendMessage = useCallback((content: string, msgType: number = 1) => {
     if (!wsRef.current || wsRef.current.readyState !== WebSocket.OPEN) {
       console.error('WebSocket not connected')
       return false
     }
 
-    wsRef.current.send(JSON.stringify({
-      type: 'message',
-      room_id: roomId,
-      content,
-      msg_type: msgType,
-    }))
+    // 服务端在消息发出后清除输入状态
+    if (typingIdleRef.current) {
+      clearTimeout(typingIdleRef.current)
+    }
+    typingSentRef.current = false
+
+    request(wsRef.current, 'send', { content, msg_type: msgType }, (reply) => {
+      if (reply.code !== 0) {
+        message.error(reply.message || '发送失败')
+      } else if (reply.data?.notice) {
+        // 斜杠命令结果，仅发送者可见
+        message.info(reply.data.notice)
+      }
+    })
 
     return true
-  }, [roomId])
+  }, [request])
+
+  const editMessage = useCallback((msgId: string, content: string) => {
+    if (!wsRef.current || wsRef.current.readyState !== WebSocket.OPEN) {
+      return false
+    }
+
+    request(wsRef.current, 'edit', { msg_id: msgId, content }, (reply) => {
+      if (reply.code !== 0) {
+        message.error(reply.message || '编辑失败')
+      }
+    })
+
+    return true
+  }, [request])
+
+  // 切换表情回应
+  const toggleReaction = useCallback((msgId: string, emoji: string, reacted: boolean) => {
+    if (!wsRef.current || wsRef.current.readyState !== WebSocket.OPEN) {
+      return false
+    }
+
+    request(wsRef.current, reacted ? 'unreact' : 'react', { msg_id: msgId, emoji }, (reply) => {
+      if (reply.code !== 0) {
+        message.error(reply.message || '操作失败')
+      }
+    })
+
+    return true
+  }, [request])
+
+  // 订阅其他已加入房间，用于多房间未读角标
+  const subscribe = useCallback((targetRoom: string) => {
+    if (wsRef.current?.readyState === WebSocket.OPEN) {
+      request(wsRef.current, 'subscribe', undefined, undefined, targetRoom)
+    }
+  }, [request])
+
+  const unsubscribe = useCallback((targetRoom: string) => {
+    if (wsRef.current?.readyState === WebSocket.OPEN) {
+      request(wsRef.current, 'unsubscribe', undefined, (reply) => {
+        if (reply.code === 0) {
+          setUnread(targetRoom, 0)
+        }
+      }, targetRoom)
+    }
+  }, [request, setUnread])
 
   useEffect(() => {
     connect()
@@ -101,6 +378,12 @@ export const useWebSocket = (roomId: string | undefined) => {
 
   return {
     sendMessage,
+    editMessage,
+    toggleReaction,
+    notifyTyping,
+    typingUsers,
+    subscribe,
+    unsubscribe,
     isConnected: wsRef.current?.readyState === WebSocket.OPEN,
   }
 }
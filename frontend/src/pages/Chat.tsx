@@ -10,6 +10,7 @@ import {
   Typography,
   Tag,
   Empty,
+  Modal,
   message,
 } from 'antd'
 import {
@@ -17,9 +18,11 @@ import {
   UserOutlined,
   ArrowLeftOutlined,
   LoadingOutlined,
+  LinkOutlined,
+  SolutionOutlined,
 } from '@ant-design/icons'
-import { useRoomStore, useMessageStore, useUserStore } from '../store'
-import { messageApi } from '../api'
+import { useRoomStore, useMessageStore, useUserStore, JoinRequest } from '../store'
+import { messageApi, roomApi } from '../api'
 import { useWebSocket } from '../hooks/useWebSocket'
 import dayjs from 'dayjs'
 
@@ -30,14 +33,16 @@ const Chat: React.FC = () => {
   const navigate = useNavigate()
   const { user } = useUserStore()
   const { currentRoom, setCurrentRoom } = useRoomStore()
-  const { messages, addMessage, setMessages, hasMore } = useMessageStore()
+  const { messages, addMessage, setMessages, hasMore, readCursors } = useMessageStore()
   const [inputValue, setInputValue] = useState('')
   const [loading, setLoading] = useState(false)
   const [sending, setSending] = useState(false)
+  const [joinRequests, setJoinRequests] = useState<JoinRequest[]>([])
+  const [requestsVisible, setRequestsVisible] = useState(false)
   const messagesEndRef = useRef<HTMLDivElement>(null)
 
   // WebSocket 连接
-  const { sendMessage: sendWSMessage, isConnected } = useWebSocket(roomId)
+  const { sendMessage: sendWSMessage, toggleReaction, notifyTyping, typingUsers, isConnected } = useWebSocket(roomId)
 
   // 获取历史消息
   const fetchMessages = async () => {
@@ -92,7 +97,12 @@ const Chat: React.FC = () => {
       })
 
       if (res.code === 0) {
-        addMessage(res.data.msg)
+        if (res.data.msg) {
+          addMessage(res.data.msg)
+        }
+        if (res.data.notice) {
+          message.info(res.data.notice)
+        }
         setInputValue('')
       } else {
         message.error(res.message || '发送失败')
@@ -115,6 +125,84 @@ const Chat: React.FC = () => {
     return dayjs(timestamp).format('HH:mm')
   }
 
+  // 点击其他用户的头像打开与其的私聊
+  const openDirectChat = async (peer: { user_id: string; nickname: string }) => {
+    try {
+      const res: any = await roomApi.openDirect(peer.user_id)
+      if (res.code === 0) {
+        setCurrentRoom({ ...res.data.chat.room, name: peer.nickname })
+        navigate(`/chat/${res.data.chat.room.room_id}`)
+      } else {
+        message.error(res.message || '打开私聊失败')
+      }
+    } catch (error) {
+      message.error('网络错误')
+    }
+  }
+
+  // 房主管理群聊：邀请链接与入群审批，私聊不适用
+  const isOwner =
+    !!currentRoom && currentRoom.creator_id === user?.user_id && !currentRoom.room_id.startsWith('d_')
+
+  // 创建 24 小时内有效的邀请码
+  const handleCreateInvite = async () => {
+    if (!roomId) return
+    try {
+      const res: any = await roomApi.createInvite(roomId, { expires_in: 24 * 3600 })
+      if (res.code === 0) {
+        Modal.info({
+          title: '邀请码',
+          content: (
+            <Text copyable strong>
+              {res.data.invite.code}
+            </Text>
+          ),
+        })
+      } else {
+        message.error(res.message || '创建邀请失败')
+      }
+    } catch (error) {
+      message.error('网络错误')
+    }
+  }
+
+  const fetchJoinRequests = async () => {
+    if (!roomId) return
+    try {
+      const res: any = await roomApi.listJoinRequests(roomId)
+      if (res.code === 0) {
+        setJoinRequests(res.data.requests || [])
+        setRequestsVisible(true)
+      } else {
+        message.error(res.message || '获取入群申请失败')
+      }
+    } catch (error) {
+      message.error('网络错误')
+    }
+  }
+
+  const handleReview = async (request: JoinRequest, approve: boolean) => {
+    try {
+      const res: any = await roomApi.reviewJoinRequest(request.id, approve)
+      if (res.code === 0) {
+        setJoinRequests(joinRequests.filter((r) => r.id !== request.id))
+      } else {
+        message.error(res.message || '审批失败')
+      }
+    } catch (error) {
+      message.error('网络错误')
+    }
+  }
+
+  // 正在输入的用户昵称，取自消息列表中的发送者
+  const typingNames = typingUsers.map(
+    (id) => messages.find((m) => m.sender.user_id === id)?.sender.nickname || id
+  )
+
+  // 已读到该消息的其他成员数
+  const seenCount = (seq: number) =>
+    Object.entries(readCursors).filter(([userId, lastRead]) => userId !== user?.user_id && lastRead >= seq).length
+
   return (
     <Card
       title={
@@ -132,6 +220,18 @@ const Chat: React.FC = () => {
           </Tag>
         </Space>
       }
+      extra={
+        isOwner && (
+          <Space>
+            <Button icon={<LinkOutlined />} onClick={handleCreateInvite}>
+              邀请
+            </Button>
+            <Button icon={<SolutionOutlined />} onClick={fetchJoinRequests}>
+              入群申请
+            </Button>
+          </Space>
+        )
+      }
       bodyStyle={{ padding: 0, height: 'calc(100vh - 180px)' }}
     >
       {/* 消息列表 */}
@@ -170,6 +270,8 @@ const Chat: React.FC = () => {
                     <Avatar
                       icon={<UserOutlined />}
                       src={msg.sender.avatar}
+                      style={isMe ? undefined : { cursor: 'pointer' }}
+                      onClick={isMe ? undefined : () => openDirectChat(msg.sender)}
                     />
                     <div
                       style={{
@@ -182,19 +284,60 @@ const Chat: React.FC = () => {
                         <Text type="secondary" style={{ marginLeft: 8, fontSize: 12 }}>
                           {formatTime(msg.timestamp)}
                         </Text>
+                        {msg.edited_at ? (
+                          <Text type="secondary" style={{ marginLeft: 4, fontSize: 12 }}>
+                            (已编辑)
+                          </Text>
+                        ) : null}
                       </div>
-                      <div
-                        style={{
-                          background: isMe ? '#1677ff' : '#f0f0f0',
-                          color: isMe ? 'white' : 'inherit',
-                          padding: '8px 12px',
-                          borderRadius: 8,
-                          display: 'inline-block',
-                          wordBreak: 'break-word',
-                        }}
-                      >
-                        {msg.content}
-                      </div>
+                      {msg.reply_to && (
+                        <div style={{ marginBottom: 4, fontSize: 12, color: '#8c8c8c' }}>
+                          回复 {msg.reply_to.sender.nickname}：
+                          {msg.reply_to.recalled_at ? '消息已撤回' : msg.reply_to.content}
+                        </div>
+                      )}
+                      {msg.recalled_at ? (
+                        <Text type="secondary" italic>
+                          {msg.recalled_by === msg.sender.user_id ? '消息已撤回' : '消息已被删除'}
+                        </Text>
+                      ) : (
+                        <div
+                          style={{
+                            background: isMe ? '#1677ff' : '#f0f0f0',
+                            color: isMe ? 'white' : 'inherit',
+                            padding: '8px 12px',
+                            borderRadius: 8,
+                            display: 'inline-block',
+                            wordBreak: 'break-word',
+                          }}
+                        >
+                          {msg.content}
+                        </div>
+                      )}
+                      {msg.reactions && msg.reactions.length > 0 && (
+                        <div style={{ marginTop: 4 }}>
+                          {msg.reactions.map((r) => (
+                            <Tag
+                              key={r.emoji}
+                              color={r.reacted ? 'blue' : undefined}
+                              style={{ cursor: 'pointer' }}
+                              onClick={() => toggleReaction(msg.msg_id, r.emoji, r.reacted)}
+                            >
+                              {r.emoji} {r.count}
+                            </Tag>
+                          ))}
+                        </div>
+                      )}
+                      {isMe && !msg.recalled_at && seenCount(msg.seq) > 0 && (
+                        <div style={{ marginTop: 4, fontSize: 12 }}>
+                          <Text type="secondary">{seenCount(msg.seq)} 人已读</Text>
+                        </div>
+                      )}
+                      {msg.reply_count ? (
+                        <div style={{ marginTop: 4, fontSize: 12 }}>
+                          <Text type="secondary">{msg.reply_count} 条回复</Text>
+                        </div>
+                      ) : null}
                     </div>
                   </Space>
                 </List.Item>
@@ -205,6 +348,12 @@ const Chat: React.FC = () => {
         <div ref={messagesEndRef} />
       </div>
 
+      {typingNames.length > 0 && (
+        <div style={{ padding: '0 16px', fontSize: 12 }}>
+          <Text type="secondary">{typingNames.join('、')} 正在输入...</Text>
+        </div>
+      )}
+
       {/* 输入框 */}
       <div
         style={{
@@ -216,7 +365,10 @@ const Chat: React.FC = () => {
       >
         <Input.TextArea
           value={inputValue}
-          onChange={(e) => setInputValue(e.target.value)}
+          onChange={(e) => {
+            setInputValue(e.target.value)
+            notifyTyping()
+          }}
           onKeyDown={handleKeyDown}
           placeholder="输入消息..."
           autoSize={{ minRows: 1, maxRows: 4 }}
@@ -232,6 +384,36 @@ const Chat: React.FC = () => {
           发送
         </Button>
       </div>
+
+      <Modal
+        title="入群申请"
+        open={requestsVisible}
+        onCancel={() => setRequestsVisible(false)}
+        footer={null}
+      >
+        <List
+          dataSource={joinRequests}
+          locale={{ emptyText: <Empty description="暂无待审批的申请" /> }}
+          renderItem={(request) => (
+            <List.Item
+              actions={[
+                <Button type="link" onClick={() => handleReview(request, true)}>
+                  批准
+                </Button>,
+                <Button type="link" danger onClick={() => handleReview(request, false)}>
+                  拒绝
+                </Button>,
+              ]}
+            >
+              <List.Item.Meta
+                avatar={<Avatar icon={<UserOutlined />} src={request.user.avatar} />}
+                title={request.user.nickname || request.user.user_id}
+                description={dayjs(request.created_at).format('MM-DD HH:mm')}
+              />
+            </List.Item>
+          )}
+        />
+      </Modal>
     </Card>
   )
 }
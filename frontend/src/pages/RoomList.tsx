@@ -11,26 +11,32 @@ import {
   Space,
   Empty,
   Pagination,
+  Badge,
+  Select,
 } from 'antd'
 import {
   PlusOutlined,
   TeamOutlined,
   EnterOutlined,
   LogoutOutlined,
+  MessageOutlined,
+  LinkOutlined,
 } from '@ant-design/icons'
 import { roomApi } from '../api'
-import { useRoomStore, useUserStore } from '../store'
+import { useRoomStore, useUserStore, DirectChat, RoomVisibility } from '../store'
 import { useNavigate } from 'react-router-dom'
 
 const RoomList: React.FC = () => {
   const navigate = useNavigate()
   const { user } = useUserStore()
-  const { rooms, setRooms, setCurrentRoom } = useRoomStore()
+  const { rooms, setRooms, setCurrentRoom, unread } = useRoomStore()
   const [loading, setLoading] = useState(false)
   const [modalVisible, setModalVisible] = useState(false)
   const [form] = Form.useForm()
   const [page, setPage] = useState(1)
   const [total, setTotal] = useState(0)
+  const [directChats, setDirectChats] = useState<DirectChat[]>([])
+  const [inviteCode, setInviteCode] = useState('')
 
   const fetchRooms = async (pageNum = 1) => {
     setLoading(true)
@@ -51,9 +57,31 @@ const RoomList: React.FC = () => {
     fetchRooms(page)
   }, [page])
 
+  const fetchDirectChats = async () => {
+    try {
+      const res: any = await roomApi.listDirect()
+      if (res.code === 0) {
+        setDirectChats(res.data.chats || [])
+      }
+    } catch (error) {
+      message.error('获取私聊列表失败')
+    }
+  }
+
+  useEffect(() => {
+    fetchDirectChats()
+  }, [])
+
+  // 私聊房间没有名称，以对方昵称作为标题
+  const handleOpenDirect = (chat: DirectChat) => {
+    setCurrentRoom({ ...chat.room, name: chat.peer?.nickname || '私聊' })
+    navigate(`/chat/${chat.room.room_id}`)
+  }
+
   const handleCreateRoom = async (values: {
     name: string
     description?: string
+    visibility?: RoomVisibility
   }) => {
     try {
       const res: any = await roomApi.create(values)
@@ -77,6 +105,8 @@ const RoomList: React.FC = () => {
         message.success('加入成功')
         setCurrentRoom(res.data.room)
         navigate(`/chat/${roomId}`)
+      } else if (res.code === 2006) {
+        message.info('已提交加入申请，等待管理员审批')
       } else {
         message.error(res.message || '加入失败')
       }
@@ -85,91 +115,170 @@ const RoomList: React.FC = () => {
     }
   }
 
-  return (
-    <Card
-      title="房间列表"
-      extra={
-        <Button
-          type="primary"
-          icon={<PlusOutlined />}
-          onClick={() => setModalVisible(true)}
-        >
-          创建房间
-        </Button>
+  const handleJoinByInvite = async () => {
+    const code = inviteCode.trim()
+    if (!code) return
+    try {
+      const res: any = await roomApi.joinByInvite(code)
+      if (res.code === 0) {
+        message.success('加入成功')
+        setInviteCode('')
+        setCurrentRoom(res.data.room)
+        navigate(`/chat/${res.data.room.room_id}`)
+      } else {
+        message.error(res.message || '邀请码无效')
       }
-    >
-      <List
-        loading={loading}
-        dataSource={rooms}
-        locale={{
-          emptyText: <Empty description="暂无房间" />,
-        }}
-        renderItem={(room) => (
-          <List.Item
-            actions={[
-              <Button
-                type="primary"
-                icon={<EnterOutlined />}
-                onClick={() => handleJoinRoom(room.room_id)}
+    } catch (error) {
+      message.error('网络错误')
+    }
+  }
+
+  return (
+    <Space direction="vertical" style={{ width: '100%' }}>
+      {directChats.length > 0 && (
+        <Card title="私聊">
+          <List
+            dataSource={directChats}
+            renderItem={(chat) => (
+              <List.Item
+                style={{ cursor: 'pointer' }}
+                onClick={() => handleOpenDirect(chat)}
+                actions={[<MessageOutlined />]}
               >
-                加入
-              </Button>,
-            ]}
-          >
-            <List.Item.Meta
-              title={room.name}
-              description={
-                <Space direction="vertical" size="small">
-                  <span>{room.description || '暂无描述'}</span>
-                  <Space>
-                    <Tag icon={<TeamOutlined />}>
-                      {room.user_count} 人在线
-                    </Tag>
-                  </Space>
-                </Space>
-              }
+                <List.Item.Meta
+                  title={
+                    <Badge count={chat.unread_count} offset={[12, 0]}>
+                      {chat.peer?.nickname || chat.room.room_id}
+                    </Badge>
+                  }
+                  description={
+                    chat.last_message
+                      ? chat.last_message.recalled_at
+                        ? '消息已撤回'
+                        : chat.last_message.content
+                      : '暂无消息'
+                  }
+                />
+              </List.Item>
+            )}
+          />
+        </Card>
+      )}
+      <Card
+        title="房间列表"
+        extra={
+          <Space>
+            <Input.Search
+              placeholder="邀请码"
+              enterButton={<LinkOutlined />}
+              value={inviteCode}
+              onChange={(e) => setInviteCode(e.target.value)}
+              onSearch={handleJoinByInvite}
+              style={{ width: 200 }}
             />
-          </List.Item>
-        )}
-      />
-
-      {total > 10 && (
-        <Pagination
-          current={page}
-          total={total}
-          pageSize={10}
-          onChange={setPage}
-          style={{ marginTop: 16, textAlign: 'right' }}
+            <Button
+              type="primary"
+              icon={<PlusOutlined />}
+              onClick={() => setModalVisible(true)}
+            >
+              创建房间
+            </Button>
+          </Space>
+        }
+      >
+        <List
+          loading={loading}
+          dataSource={rooms}
+          locale={{
+            emptyText: <Empty description="暂无房间" />,
+          }}
+          renderItem={(room) => (
+            <List.Item
+              actions={[
+                <Button
+                  type="primary"
+                  icon={<EnterOutlined />}
+                  onClick={() => handleJoinRoom(room.room_id)}
+                >
+                  加入
+                </Button>,
+              ]}
+            >
+              <List.Item.Meta
+                title={
+                  <Badge count={unread[room.room_id] || 0} offset={[12, 0]}>
+                    {room.name}
+                  </Badge>
+                }
+                description={
+                  <Space direction="vertical" size="small">
+                    <span>{room.description || '暂无描述'}</span>
+                    <Space>
+                      <Tag icon={<TeamOutlined />}>
+                        {room.user_count} 人在线
+                      </Tag>
+                      {room.visibility === 'invite' && (
+                        <Tag color="orange">需审批</Tag>
+                      )}
+                      {room.visibility === 'private' && (
+                        <Tag color="red">私密</Tag>
+                      )}
+                    </Space>
+                  </Space>
+                }
+              />
+            </List.Item>
+          )}
         />
-      )}
 
-      <Modal
-        title="创建房间"
-        open={modalVisible}
-        onCancel={() => setModalVisible(false)}
-        footer={null}
-      >
-        <Form form={form} onFinish={handleCreateRoom} layout="vertical">
-          <Form.Item
-            name="name"
-            label="房间名称"
-            rules={[{ required: true, message: '请输入房间名称' }]}
-          >
-            <Input placeholder="房间名称" />
-          </Form.Item>
-
-          <Form.Item name="description" label="房间描述">
-            <Input.TextArea placeholder="房间描述（可选）" rows={3} />
-          </Form.Item>
-
-          <Form.Item>
-            <Button type="primary" htmlType="submit" block>
-              创建
-            </Button>
-          </Form.Item>
-        </Form>
-      </Modal>
-    </Card>
+        {total > 10 && (
+          <Pagination
+            current={page}
+            total={total}
+            pageSize={10}
+            onChange={setPage}
+            style={{ marginTop: 16, textAlign: 'right' }}
+          />
+        )}
+
+        <Modal
+          title="创建房间"
+          open={modalVisible}
+          onCancel={() => setModalVisible(false)}
+          footer={null}
+        >
+          <Form form={form} onFinish={handleCreateRoom} layout="vertical">
+            <Form.Item
+              name="name"
+              label="房间名称"
+              rules={[{ required: true, message: '请输入房间名称' }]}
+            >
+              <Input placeholder="房间名称" />
+            </Form.Item>
+
+            <Form.Item name="description" label="房间描述">
+              <Input.TextArea placeholder="房间描述（可选）" rows={3} />
+            </Form.Item>
+
+            <Form.Item name="visibility" label="可见性" initialValue="public">
+              <Select
+                options={[
+                  { value: 'public', label: '公开：任何人可加入' },
+                  { value: 'invite', label: '审批：需邀请链接或管理员审批' },
+                  { value: 'private', label: '私密：仅成员可见，凭邀请链接加入' },
+                ]}
+              />
+            </Form.Item>
+
+            <Form.Item>
+              <Button type="primary" htmlType="submit" block>
+                创建
+              </Button>
+            </Form.Item>
+          </Form>
+        </Modal>
+      </Card>
+    </Space>
   )
 }
 
@@ -17,6 +17,47 @@ export interface Room {
   creator_id: string
   user_count: number
   created_at: number
+  visibility?: RoomVisibility
+}
+
+// 房间可见性：public 公开，invite 需邀请或审批，private 仅成员可见
+export type RoomVisibility = 'public' | 'invite' | 'private'
+
+// 房间成员角色，由高到低
+export type RoomRole = 'owner' | 'admin' | 'moderator' | 'member' | 'muted'
+
+export interface RoomMember {
+  room_id: string
+  user_id: string
+  role: RoomRole
+}
+
+// 房间邀请链接
+export interface RoomInvite {
+  code: string
+  room_id: string
+  creator_id: string
+  max_uses: number // 0 表示不限次数
+  uses: number
+  expires_at: number // 0 表示永不过期
+  created_at: number
+}
+
+// 入群申请
+export interface JoinRequest {
+  id: number
+  room_id: string
+  user: User
+  status: 'pending' | 'approved' | 'rejected'
+  created_at: number
+}
+
+// 私聊会话
+export interface DirectChat {
+  room: Room
+  peer?: User // 对方
+  last_message?: Message
+  unread_count: number
 }
 
 // 消息
@@ -27,6 +68,38 @@ export interface Message {
   content: string
   msg_type: number
   timestamp: number
+  seq: number // 房间内单调递增的序号
+  edited_at?: number // 最后一次编辑时间
+  revisions?: MessageRevision[]
+  recalled_at?: number // 非 0 时为已撤回/删除的墓碑
+  recalled_by?: string
+  reply_to_msg_id?: string
+  reply_to?: Message // 被引用的消息
+  thread_root_id?: string // 所属话题的根消息
+  reply_count?: number // 根消息的话题回复数
+  last_reply_at?: number
+  reactions?: Reaction[]
+}
+
+// 消息上某个表情的回应汇总
+export interface Reaction {
+  emoji: string
+  count: number
+  reacted: boolean // 自己是否回应过
+}
+
+// 成员在房间的已读游标
+export interface ReadCursor {
+  user_id: string
+  last_read_seq: number
+  last_read_at: number
+}
+
+// 消息被编辑前的版本
+export interface MessageRevision {
+  content: string
+  editor_id: string
+  replaced_at: number
 }
 
 // 用户状态
@@ -60,6 +133,10 @@ interface RoomState {
   setRooms: (rooms: Room[]) => void
   setCurrentRoom: (room: Room | null) => void
   updateRoomUserCount: (roomId: string, count: number) => void
+  // 已订阅但未在查看的房间的未读数
+  unread: Record<string, number>
+  setUnread: (roomId: string, count: number) => void
+  resetUnread: (unread: Record<string, number>) => void
 }
 
 export const useRoomStore = create<RoomState>((set) => ({
@@ -73,6 +150,10 @@ export const useRoomStore = create<RoomState>((set) => ({
         r.room_id === roomId ? { ...r, user_count: count } : r
       ),
     })),
+  unread: {},
+  setUnread: (roomId, count) =>
+    set((state) => ({ unread: { ...state.unread, [roomId]: count } })),
+  resetUnread: (unread) => set({ unread }),
 }))
 
 // 消息状态
@@ -80,18 +161,47 @@ interface MessageState {
   messages: Message[]
   hasMore: boolean
   addMessage: (message: Message) => void
+  updateMessage: (message: Partial<Message> & { msg_id: string }) => void
   setMessages: (messages: Message[]) => void
   appendMessages: (messages: Message[]) => void
   clearMessages: () => void
+  // 当前房间成员的已读游标：user_id -> last_read_seq
+  readCursors: Record<string, number>
+  setReadCursors: (cursors: ReadCursor[]) => void
+  updateReadCursor: (userId: string, seq: number) => void
 }
 
 export const useMessageStore = create<MessageState>((set) => ({
   messages: [],
   hasMore: false,
+  // 断线重连补发的消息可能已在列表中，按 msg_id 去重
   addMessage: (message) =>
-    set((state) => ({ messages: [...state.messages, message] })),
+    set((state) =>
+      state.messages.some((m) => m.msg_id === message.msg_id)
+        ? state
+        : { messages: [...state.messages, message] }
+    ),
+  updateMessage: (message) =>
+    set((state) => ({
+      messages: state.messages.map((m) =>
+        m.msg_id === message.msg_id ? { ...m, ...message } : m
+      ),
+    })),
   setMessages: (messages) => set({ messages }),
   appendMessages: (messages) =>
     set((state) => ({ messages: [...messages, ...state.messages] })),
-  clearMessages: () => set({ messages: [], hasMore: false }),
+  clearMessages: () => set({ messages: [], hasMore: false, readCursors: {} }),
+  readCursors: {},
+  setReadCursors: (cursors) =>
+    set({
+      readCursors: Object.fromEntries(cursors.map((c) => [c.user_id, c.last_read_seq])),
+    }),
+  // 游标只前进不后退
+  updateReadCursor: (userId, seq) =>
+    set((state) => ({
+      readCursors: {
+        ...state.readCursors,
+        [userId]: Math.max(state.readCursors[userId] || 0, seq),
+      },
+    })),
 }))
@@ -8,9 +8,13 @@ export default defineConfig({
     port: 3000,
     proxy: {
       '/api': {
-        target: 'http://localhost:8888',
+        target: 'http://localhost:8889',
         changeOrigin: true,
       },
+      '/ws': {
+        target: 'ws://localhost:8889',
+        ws: true,
+      },
     },
   },
 })